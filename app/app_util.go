@@ -6,73 +6,102 @@ package app
 import (
 	"flag"
 	"fmt"
-	"os"
 
+	"github.com/pkg/errors"             // 异常
 	"github.com/zpab123/sco/config"     // 配置管理
+	"github.com/zpab123/sco/discovery"  // 服务发现
+	"github.com/zpab123/sco/master"     // master
 	"github.com/zpab123/sco/netservice" // 网络服务
 	"github.com/zpab123/sco/network"    // 网络
+	"github.com/zpab123/sco/rpc"        // rpc
+	"github.com/zpab123/sco/session"    // 会话
 	"github.com/zpab123/zaplog"         // log
 )
 
+// 内置组件名字：由 createComponent 创建，自定义组件不能使用
+var builtinComponents = []string{
+	netservice.C_CMPT_NAME,
+	rpc.C_CMPT_NAME,
+	master.C_CMPT_NAME,
+	master.C_CMPT_NAME_CLIENT,
+	discovery.C_CMPT_NAME,
+	C_CMPT_NAME_FORWARDER,
+}
+
+// 命令行参数：只定义1次，同一进程中的多个 app 共享
+var (
+	nameFlag = flag.String("name", "gate_1", "server name") // 服务器名字
+)
+
 // 完成 app 的默认设置
-func defaultConfig(app *Application) {
+func defaultConfig(app *Application) error {
 	// 解析启动参数
 	parseArgs(app)
 
+	// 加载配置
+	if err := config.Load(); nil != err {
+		return err
+	}
+
 	// 获取服务器信息
-	getServerJson(app)
+	if err := getServerJson(app); nil != err {
+		return err
+	}
 
 	// 设置 log 信息
 	configLogger(app)
 
 	// 设置 app 默认参数
 	setdDfaultOpt(app)
+
+	// rpc
+	app.rpcServer = rpc.NewRpcServer(rpc.GetRpcAddr(app.serverInfo))
+	app.rpcClient = rpc.NewRpcClient(app.baseInfo.Name, app.Option.RpcOpt, app.router)
+
+	// 组管理
+	app.groupMgr = session.NewGroupManager(app.baseInfo.Name)
+	app.groupMgr.SetRemote(&groupRemote{app: app})
+	app.backendMgr.AddCloseHandler(app.groupMgr.OnBackendSessionClose)
+	app.rpcServer.Handle(C_ROUTE_GROUP_PUSH, app.handleGroupPush)
+
+	return nil
 }
 
-// 解析 命令行参数
+// 解析 命令行参数：app 名字未通过 SetName 设置时，使用 -name 参数
 func parseArgs(app *Application) {
-	// 参数定义
-	//serverType := flag.String("type", "serverType", "server type") // 服务器类型，例如 gate connect area ...等
-	//gid := flag.Uint("gid", 0, "gid")                                       // 服务器进程id
-	name := flag.String("name", "gate_1", "server name") // 服务器名字
-	//frontend := flag.Bool("frontend", false, "is frontend server")          // 是否是前端服务器
-	//host := flag.String("host", "127.0.0.1", "server host")                 // 服务器IP地址
-	//port := flag.Uint("port", 0, "server port")                             // 服务器端口
-	//clientHost := flag.String("clientHost", "127.0.0.1", "for client host") // 面向客户端的 IP地址
-	//cTcpPort := flag.Uint("cTcpPort", 0, "tcp port")                        // tcp 连接端口
-	//cWsPort := flag.Uint("cWsPort", 0, "websocket port")                    // websocket 连接端口
+	if "" != app.baseInfo.Name {
+		return
+	}
 
 	// 解析参数
-	flag.Parse()
-
-	// 赋值
-	//cmdParam := &cmd.CmdParam{
-	//ServerType: *serverType,
-	//Gid:        *gid,
-	//Name: *name,
-	//Frontend:   *frontend,
-	//Host:       *host,
-	//Port:       *port,
-	//ClientHost: *clientHost,
-	//CTcpPort:   *cTcpPort,
-	//CWsPort:    *cWsPort,
-	//}
+	if !flag.Parsed() {
+		flag.Parse()
+	}
 
 	// 设置 app 名字
-	app.baseInfo.Name = *name
+	app.baseInfo.Name = *nameFlag
 }
 
 // 获取 server.json 信息
-func getServerJson(app *Application) {
+func getServerJson(app *Application) error {
 	// 根据 AppType 和 Name 获取 服务器配置参数
 	appType := app.baseInfo.AppType
 	name := app.baseInfo.Name
+
+	// master 服务器：使用 master.json 中的配置
+	if config.C_SERVER_TYPE_MASTER == appType {
+		app.serverInfo = config.GetMasterInfo()
+		if nil == app.serverInfo {
+			return errors.Errorf("app 获取 master.json 信息失败。 appName=%s", name)
+		}
+
+		return nil
+	}
+
 	list, ok := config.GetServerMap()[appType]
 
 	if nil == list || len(list) <= 0 || !ok {
-		zaplog.Fatalf("app 获取 appType 信息失败。 appType=%s", appType)
-
-		os.Exit(1)
+		return errors.Errorf("app 获取 appType 信息失败。 appType=%s", appType)
 	}
 
 	// 获取服务器信息
@@ -85,14 +114,18 @@ func getServerJson(app *Application) {
 	}
 
 	if app.serverInfo == nil {
-		zaplog.Fatal("app 获取 server.json 信息失败。 appName=%s", app.baseInfo.Name)
-
-		os.Exit(1)
+		return errors.Errorf("app 获取 server.json 信息失败。 appName=%s", app.baseInfo.Name)
 	}
+
+	return nil
 }
 
-// 设置 log 信息
+// 设置 log 信息：嵌入模式下，log 由调用方设置
 func configLogger(app *Application) {
+	if app.embedded {
+		return
+	}
+
 	// 模块名字
 	zaplog.SetSource(app.baseInfo.Name)
 
@@ -111,18 +144,97 @@ func configLogger(app *Application) {
 	zaplog.SetOutput(outputs)
 }
 
+// 是否是内置组件名字
+func isBuiltinComponent(name string) bool {
+	for _, n := range builtinComponents {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 // 创建默认组件
-func createComponent(app *Application) {
+func createComponent(app *Application) error {
 	// 网络服务
 	nsOpt := app.Option.NetServiceOpt
 	if nil != nsOpt && nsOpt.Enable {
-		newNetService(app)
+		if err := newNetService(app); nil != err {
+			return err
+		}
+	}
+
+	// master
+	needRpc := app.Option.RpcOpt.Enable
+	if config.C_SERVER_TYPE_MASTER == app.baseInfo.AppType {
+		if err := newMaster(app); nil != err {
+			return err
+		}
+		needRpc = true
+	} else if app.Option.MasterOpt.Enable {
+		if err := newMasterClient(app); nil != err {
+			return err
+		}
+		needRpc = true
+	}
+
+	// 服务发现
+	if err := newDiscovery(app); nil != err {
+		return err
 	}
+
+	// rpc 服务：与消息处理使用同1个 panic 策略
+	app.rpcServer.SetPanicPolicy(app.Option.PanicPolicy)
+	if needRpc {
+		if err := checkRpcAddr(app); nil != err {
+			return err
+		}
+
+		app.componentMgr.Add(app.rpcServer)
+	}
+
+	// 消息转发：前端服务器
+	if nil != app.netService && nsOpt.ForClient {
+		app.forwarder.SetSource(app.baseInfo.Name)
+		app.forwarder.SetDiscovery(app.discovery)
+		app.forwarder.SetClientSessionManager(app.netService.GetSessionManager(), nsOpt.ServerSesOpt)
+		app.componentMgr.Add(app.forwarder, app.discovery.Name())
+	}
+
+	// 网络服务：最后启动，其他组件就绪后才接收连接
+	if nil != app.netService {
+		deps := []string{app.discovery.Name()}
+		if nsOpt.ForClient {
+			deps = append(deps, C_CMPT_NAME_FORWARDER)
+		}
+		if needRpc {
+			deps = append(deps, rpc.C_CMPT_NAME)
+		}
+
+		app.componentMgr.Add(app.netService, deps...)
+	}
+
+	return nil
 }
 
-// 创建 NetService 组件
-func newNetService(app *Application) {
-	// 创建地址
+// 检查 rpc 地址：未设置 RpcPort 时使用 Port，与网络服务的侦听地址相同时无法启动
+func checkRpcAddr(app *Application) error {
+	if nil == app.netService {
+		return nil
+	}
+
+	// 网络服务目前只侦听 websocket 地址
+	addr := rpc.GetRpcAddr(app.serverInfo)
+	if addr == netServiceAddr(app).WsAddr {
+		return errors.Errorf("app 创建 rpc 服务失败：rpc 地址与网络服务侦听地址相同。addr=%s，请为服务器 %s 设置 RpcPort", addr, app.baseInfo.Name)
+	}
+
+	return nil
+}
+
+// 获取网络服务的侦听地址
+func netServiceAddr(app *Application) *network.TLaddr {
 	serverInfo := app.serverInfo
 	opt := app.Option.NetServiceOpt
 
@@ -145,13 +257,117 @@ func newNetService(app *Application) {
 		WsAddr:  wsAddr,
 	}
 
+	return laddr
+}
+
+// 创建 NetService 组件（依赖关系确定后，由 createComponent 添加）
+func newNetService(app *Application) error {
+	// 创建地址
+	laddr := netServiceAddr(app)
+	opt := app.Option.NetServiceOpt
+
+	// panic 策略：session 与 app 使用同1个设置
+	if nil != opt.ClientSesOpt {
+		opt.ClientSesOpt.PanicPolicy = app.Option.PanicPolicy
+	}
+	if nil != opt.ServerSesOpt {
+		opt.ServerSesOpt.PanicPolicy = app.Option.PanicPolicy
+	}
+
 	// 创建 NetServer
 	ns, err := netservice.NewNetService(laddr, app, opt)
 	if nil != err {
-		zaplog.Fatal("app 创建 NetService 失败: %s", err.Error())
+		return errors.Wrap(err, "app 创建 NetService 失败")
+	}
+
+	app.netService = ns
+
+	// 组管理：session 关闭时，从所有组中移除
+	app.groupMgr.SetSessionManager(ns.GetSessionManager())
+	ns.GetSessionManager().AddCloseHandler(app.groupMgr.OnSessionClose)
+
+	// 客户端 session 代理：前端服务器连接关闭时，移除其所有代理
+	if !opt.ForClient {
+		ns.GetSessionManager().AddCloseHandler(app.backendMgr.OnSessionClose)
+	}
+
+	// session 代理
+	if d, ok := app.delegate.(ISessionDelegate); ok {
+		mgr := ns.GetSessionManager()
+		mgr.AddOpenHandler(d.OnSessionOpen)
+		mgr.AddCloseHandler(d.OnSessionClose)
+	}
+
+	return nil
+}
+
+// 创建服务发现组件
+func newDiscovery(app *Application) error {
+	if nil == app.discovery {
+		if nil != app.masterClient {
+			app.discovery = app.masterClient
+		} else {
+			fd, err := discovery.NewFileDiscovery("", discovery.C_WATCH_INTERVAL)
+			if nil != err {
+				return errors.Wrap(err, "app 创建服务发现失败")
+			}
+
+			app.discovery = fd
+		}
+	}
+
+	// master 客户端已作为组件添加
+	if app.discovery != app.masterClient {
+		app.componentMgr.Add(app.discovery)
+	}
+
+	for _, fn := range app.clusterListeners {
+		app.discovery.AddListener(fn)
+	}
+
+	// 集群代理
+	if d, ok := app.delegate.(IClusterDelegate); ok {
+		app.discovery.AddListener(func(evt *discovery.TEvent) {
+			switch evt.Type {
+			case discovery.C_EVENT_ADD:
+				d.OnServerAdded(evt.ServerType, evt.Info)
+			case discovery.C_EVENT_REMOVE:
+				d.OnServerRemoved(evt.ServerType, evt.Info)
+			}
+		})
+	}
+
+	app.rpcClient.SetDiscovery(app.discovery)
+
+	return nil
+}
+
+// 创建 Master 组件
+func newMaster(app *Application) error {
+	m, err := master.NewMaster(app.rpcServer, app.rpcClient, app.Option.MasterOpt)
+	if nil != err {
+		return errors.Wrap(err, "app 创建 Master 失败")
+	}
+
+	app.componentMgr.Add(m, rpc.C_CMPT_NAME)
 
-		os.Exit(1)
+	return nil
+}
+
+// 创建 master 客户端组件
+func newMasterClient(app *Application) error {
+	masterInfo := config.GetMasterInfo()
+	if nil == masterInfo {
+		return errors.New("app 创建 master 客户端失败: master.json 不存在或没有当前环境的配置")
 	}
 
-	app.componentMgr.Add(ns)
+	mc, err := master.NewClient(app.baseInfo.AppType, app.serverInfo, masterInfo, app.rpcServer, app.rpcClient, app.Option.MasterOpt)
+	if nil != err {
+		return errors.Wrap(err, "app 创建 master 客户端失败")
+	}
+
+	app.masterClient = mc
+	app.componentMgr.Add(mc, rpc.C_CMPT_NAME)
+
+	return nil
 }
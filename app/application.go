@@ -6,18 +6,24 @@ package app
 import (
 	"context"
 	"math/rand"
-	"os"
-	"os/signal"
-	"sync"
-	"syscall"
+	"runtime/debug"
 	"time"
 
-	"github.com/zpab123/sco/config"  // 配置管理
-	"github.com/zpab123/sco/network" // 网络
-	"github.com/zpab123/sco/path"    // 路径
-	"github.com/zpab123/sco/session" // 会话
-	"github.com/zpab123/sco/state"   // 状态管理
-	"github.com/zpab123/zaplog"      // log
+	"github.com/pkg/errors"             // 异常
+	"github.com/zpab123/sco/config"     // 配置管理
+	"github.com/zpab123/sco/discovery"  // 服务发现
+	"github.com/zpab123/sco/master"     // master
+	"github.com/zpab123/sco/model"      // 全局模型
+	"github.com/zpab123/sco/netservice" // 网络服务
+	"github.com/zpab123/sco/network"    // 网络
+	"github.com/zpab123/sco/path"       // 路径
+	"github.com/zpab123/sco/protocol"   // 通信协议
+	"github.com/zpab123/sco/route"      // 路由
+	"github.com/zpab123/sco/rpc"        // rpc
+	"github.com/zpab123/sco/session"    // 会话
+	"github.com/zpab123/sco/state"      // 状态管理
+	"github.com/zpab123/syncutil"       // 原子变量
+	"github.com/zpab123/zaplog"         // log
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -25,81 +31,104 @@ import (
 
 // 1个通用服务器对象
 type Application struct {
-	Option       *Option                // 配置参数
-	stateMgr     *state.StateManager    // 状态管理
-	baseInfo     TBaseInfo              // 基础信息
-	delegate     IDelegate              // 代理对象
-	stopGroup    sync.WaitGroup         // stop 等待组
-	serverInfo   *config.TServerInfo    // 配置信息
-	signalChan   chan os.Signal         // 操作系统信号
-	ctx          context.Context        // 上下文
-	cancel       context.CancelFunc     // 退出通知函数
-	componentMgr *ComponentManager      // 组件管理
-	handlerChan  chan session.ClientMsg // handler 消息通道
+	Option           *Option                        // 配置参数
+	stateMgr         *state.StateManager            // 状态管理
+	baseInfo         TBaseInfo                      // 基础信息
+	delegate         IDelegate                      // 代理对象
+	serverInfo       *config.TServerInfo            // 配置信息
+	ctx              context.Context                // 上下文
+	cancel           context.CancelFunc             // 退出通知函数
+	componentMgr     *ComponentManager              // 组件管理
+	filterMgr        *FilterManager                 // 过滤器管理
+	dispatcher       *Dispatcher                    // 消息分发
+	forwarder        *Forwarder                     // 消息转发
+	router           *route.Router                  // 服务器路由
+	netService       netservice.INetService         // 网络服务
+	rpcServer        *rpc.RpcServer                 // rpc 服务
+	rpcClient        *rpc.RpcClient                 // rpc 客户端
+	masterClient     *master.Client                 // master 客户端
+	discovery        discovery.IDiscovery           // 服务发现
+	clusterListeners []discovery.EventFunc          // 集群事件监听
+	groupMgr         *session.GroupManager          // 组管理
+	backendMgr       *session.BackendSessionManager // 客户端 session 代理管理（后端服务器）
+	panicCount       syncutil.AtomicInt64           // handler 处理消息出现 panic 的次数
+	embedded         bool                           // 是否为嵌入模式
 	// remoteChan	// handler rpc消息通道
 }
 
 // 创建1个新的 Application 对象
-func NewApplication(appType string, delegate IDelegate) *Application {
+func NewApplication(appType string, delegate IDelegate) (*Application, error) {
 	// 参数验证
 	if "" == appType {
-		zaplog.Error("app 创建失败: 参数 appType 为空")
-
-		os.Exit(1)
+		return nil, errors.New("app 创建失败: 参数 appType 为空")
 	}
 
 	if nil == delegate {
-		zaplog.Error("app 创建失败: 参数 delegate=nil")
-
-		os.Exit(1)
+		return nil, errors.New("app 创建失败: 参数 delegate=nil")
 	}
 
 	// 创建对象
 	st := state.NewStateManager()
-	sigChan := make(chan os.Signal, 1)
 	ctx, cancel := context.WithCancel(context.Background())
 	cmptMgr := NewComponentManager()
+	filterMgr := NewFilterManager()
 
 	// 创建 app
 	app := &Application{
 		stateMgr:     st,
 		delegate:     delegate,
-		signalChan:   sigChan,
 		ctx:          ctx,
 		cancel:       cancel,
 		componentMgr: cmptMgr,
+		filterMgr:    filterMgr,
 	}
 
 	// 设置类型
 	app.baseInfo.AppType = appType
 
+	// 消息转发
+	app.router = route.NewRouter()
+	app.forwarder = NewForwarder(appType, app, app.router)
+	app.backendMgr = session.NewBackendSessionManager()
+
 	// 设置为无效状态
 	app.stateMgr.SetState(state.C_INVALID)
 
-	return app
+	return app, nil
+}
+
+// 设置 app 名字，需在 Init 之前调用；未设置时，使用命令行参数 -name
+func (this *Application) SetName(name string) {
+	this.baseInfo.Name = name
+}
+
+// 设置为嵌入模式，需在 Init 之前调用
+//
+// 嵌入模式下不设置 log 输出，由调用方设置；用于在1个进程中运行多个 app（例如测试）
+func (this *Application) SetEmbedded(embedded bool) {
+	this.embedded = embedded
 }
 
 // 初始化 Application
-func (this *Application) Init() {
+func (this *Application) Init() error {
 	// 状态效验
 	if this.stateMgr.GetState() != state.C_INVALID {
 		st := this.stateMgr.GetState()
-		zaplog.Fatal("app Init 失败，状态错误。当前状态=%d，正确状态=%d", st, state.C_INVALID)
 
-		os.Exit(1)
+		return errors.Errorf("app Init 失败，状态错误。当前状态=%d，正确状态=%d", st, state.C_INVALID)
 	}
 
 	// 获取主程序路径
 	dir, err := path.GetMainPath()
 	if err != nil {
-		zaplog.Fatal("app Init 失败。读取 main 根目录失败")
-
-		os.Exit(1)
+		return errors.Wrap(err, "app Init 失败。读取 main 根目录失败")
 	}
 	this.baseInfo.MainPath = dir
 
 	// 默认设置
-	defaultConfig(this)
+	if err = defaultConfig(this); nil != err {
+		return errors.Wrap(err, "app Init 失败")
+	}
 
 	// 通知代理
 	this.delegate.Init(this)
@@ -108,18 +137,22 @@ func (this *Application) Init() {
 	this.stateMgr.SetState(state.C_INIT)
 
 	zaplog.Infof("app 状态：init完成 ...")
+
+	return nil
 }
 
-// 启动 app
-func (this *Application) Run() {
+// 启动 app：所有组件启动完成后返回，不阻塞
+//
+// 只能启动1次：停止（或启动失败）后，内置组件、服务发现监听、rpc 客户端等均已关闭，需创建新的 Application
+func (this *Application) Run() error {
 	// 状态效验
 	if !this.stateMgr.CompareAndSwap(state.C_INIT, state.C_RUNING) {
-		if !this.stateMgr.CompareAndSwap(state.C_STOPED, state.C_RUNING) {
-			st := this.stateMgr.GetState()
-			zaplog.Fatalf("app 启动失败，状态错误。当前状态=%d，正确状态=%d或%d", st, state.C_INIT, state.C_STOPED)
-
-			os.Exit(1)
+		st := this.stateMgr.GetState()
+		if st == state.C_STOPED {
+			return errors.New("app 启动失败：app 已停止，不能再次启动，请创建新的 Application")
 		}
+
+		return errors.Errorf("app 启动失败，状态错误。当前状态=%d，正确状态=%d", st, state.C_INIT)
 	}
 
 	zaplog.Infof("app 状态：正在启动中 ...")
@@ -130,141 +163,395 @@ func (this *Application) Run() {
 	// 记录启动时间
 	this.baseInfo.RunTime = time.Now()
 
-	// 消息通道
-	this.handlerChan = make(chan session.ClientMsg, this.Option.ClentMsgChanSize)
+	// 消息分发
+	opt := this.Option
+	this.dispatcher = NewDispatcher(opt.DispatchMode, opt.WorkerNum, opt.ClentMsgChanSize, opt.ServerMsgChanSize, this.handleClientMsg, this.handleServerMsg)
+	this.dispatcher.Run(this.ctx)
 
-	// 创建组件
-	createComponent(this)
+	// 通知代理：启动之前
+	var err error
+	if d, ok := this.delegate.(IStartDelegate); ok {
+		err = d.BeforeStart(this)
+	}
 
-	// 启动所有组件
-	this.runComponent()
+	// 创建组件，并按依赖关系启动
+	if nil == err {
+		err = createComponent(this)
+	}
+
+	if nil == err {
+		err = this.componentMgr.Run(this.ctx)
+	}
 
-	// 侦听系统信号
-	//this.waitSysSignal()
+	if nil != err {
+		this.cancel()
+		this.dispatcher.Wait()
+		this.dispatcher.Discard()
+		this.stateMgr.SetState(state.C_STOPED)
+
+		return errors.Wrap(err, "app 启动失败")
+	}
 
 	// 状态：工作中
 	this.stateMgr.SetState(state.C_WORKING)
 
 	zaplog.Infof("app 状态：启动成功，工作中 ...")
 
-	// 侦听结束信号
-	this.waitStopSignal()
+	// 通知代理：启动完成
+	if d, ok := this.delegate.(IStartDelegate); ok {
+		d.AfterStart(this)
+	}
+
+	return nil
 }
 
-// 停止 app
-func (this *Application) Stop() {
+// 优雅停止 app：停止接收新连接并通知客户端，等待已收到的消息处理完成、发送队列刷新后关闭连接
+//
+// 超过 Option.StopTimeout 后强制关闭连接；所有组件停止后返回
+func (this *Application) Stop() error {
 	// 状态效验
 	if !this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_STOPING) {
-		zaplog.Fatalf("app 启动失败，状态错误。当前状态=%d，正确状态=%d", this.stateMgr.GetState(), state.C_WORKING)
+		return errors.Errorf("app 停止失败，状态错误。当前状态=%d，正确状态=%d", this.stateMgr.GetState(), state.C_WORKING)
+	}
+
+	// 通知代理：停止之前
+	stopDelegate, _ := this.delegate.(IStopDelegate)
+	if nil != stopDelegate {
+		stopDelegate.BeforeStop(this)
+	}
+
+	deadline := time.Now().Add(this.Option.StopTimeout)
+
+	// 停止接收新连接，通知客户端服务器即将关闭
+	if nil != this.netService {
+		if err := this.netService.Shutdown(); nil != err {
+			zaplog.Warnf("%s 服务器，停止接收新连接失败。err=%s", this.baseInfo.Name, err)
+		}
 	}
 
-	// 停止所有组件
-	this.stopComponent()
+	// 不再接收新消息，等待已收到的消息处理完成
+	this.dispatcher.Drain(deadline)
+
+	// 等待发送队列刷新后关闭连接，超时后强制关闭
+	if nil != this.netService {
+		this.netService.Drain(deadline)
+	}
+
+	// 按启动顺序的逆序停止所有组件：rpc 服务超过 deadline 后强制关闭
+	this.rpcServer.SetStopDeadline(deadline)
+	this.componentMgr.Stop()
+
+	// 停止消息分发，释放超时未处理的消息
+	this.cancel()
+	this.dispatcher.Wait()
+	this.dispatcher.Discard()
+
+	// 关闭 rpc 连接
+	this.rpcClient.Close()
 
-	// 等待完成
-	this.stopGroup.Wait()
 	this.stateMgr.SetState(state.C_STOPED)
 
 	zaplog.Infof("%s 服务器，优雅退出", this.baseInfo.Name)
 
-	os.Exit(0)
+	// 通知代理：停止完成
+	if nil != stopDelegate {
+		stopDelegate.AfterStop(this)
+	}
+
+	return nil
+}
+
+// 获取 app 名字
+func (this *Application) GetName() string {
+	return this.baseInfo.Name
+}
+
+// 获取网络服务的 session 管理对象，可按 id 或 uid 查找 session
+//
+// 返回 nil=未启用网络服务或 app 尚未启动
+func (this *Application) GetSessionManager() *session.SessionManager {
+	if nil == this.netService {
+		return nil
+	}
+
+	return this.netService.GetSessionManager()
 }
 
-// 启动所有组件
-func (this *Application) runComponent() {
-	for _, cpt := range this.componentMgr.componentMap {
-		this.stopGroup.Add(1)
+// 获取组管理对象：创建/销毁组，向组成员组播消息
+//
+// 本地 session 关闭时自动从所有组中移除；其他前端服务器上的成员通过 rpc 推送，需要前端服务器启用 rpc
+func (this *Application) GetGroupManager() *session.GroupManager {
+	return this.groupMgr
+}
+
+// 获取客户端 session 代理管理对象（后端服务器）：前端服务器转发的客户端消息，通过 ServerMsg.GetBackendSession 获取代理
+//
+// 代理保存前端服务器同步的 uid 与属性，推送/踢下线经由来源前端服务器送达客户端
+func (this *Application) GetBackendSessionManager() *session.BackendSessionManager {
+	return this.backendMgr
+}
 
-		go func() {
-			defer this.stopGroup.Done()
+// 注册1个自定义组件，与 app 共享生命周期：按依赖关系启动，app 停止时按启动顺序的逆序停止
+//
+// 需在组件启动之前调用（IDelegate.Init 或 IStartDelegate.BeforeStart 中）；depends=依赖的组件名字，可以是内置组件
+func (this *Application) Register(cmpt model.IComponent, depends ...string) error {
+	if nil == cmpt {
+		return errors.New("app 注册组件失败：参数 cmpt=nil")
+	}
+
+	// 状态效验
+	st := this.stateMgr.GetState()
+	if st != state.C_INVALID && st != state.C_INIT && st != state.C_RUNING {
+		return errors.Errorf("app 注册组件失败，状态错误。当前状态=%d", st)
+	}
 
-			cpt.Run(this.ctx)
-		}()
+	name := cmpt.Name()
+	if isBuiltinComponent(name) {
+		return errors.Errorf("app 注册组件失败：%s 是内置组件名字", name)
 	}
+
+	if nil != this.componentMgr.GetComponentByName(name) {
+		return errors.Errorf("app 注册组件失败：组件 %s 已存在", name)
+	}
+
+	this.componentMgr.Add(cmpt, depends...)
+
+	return nil
+}
+
+// 根据名字获取组件（包括内置组件）
+//
+// 返回 nil=不存在
+func (this *Application) GetComponent(name string) model.IComponent {
+	return this.componentMgr.GetComponentByName(name)
 }
 
-// 停止所有组件
-func (this *Application) stopComponent() {
-	for _, cpt := range this.componentMgr.componentMap {
-		go cpt.Stop()
+// 按类型查找组件，找到后保存到 target
+//
+// target=指向组件类型（结构体指针或接口）的指针，例如 var pool *DBPool; app.FindComponent(&pool)；返回 false=不存在
+func (this *Application) FindComponent(target interface{}) bool {
+	return this.componentMgr.Find(target)
+}
+
+// 添加1个消息过滤器
+//
+// filter 需实现 IBeforeFilter 或 IAfterFilter；mids 为空=全局过滤器，否则只过滤这些消息id。
+// 本地处理与需要转发给后端服务器的消息，都在分发 goroutine 中执行过滤器；worker、direct 分发模式下，过滤器需支持并发调用
+func (this *Application) AddFilter(filter interface{}, mids ...uint16) {
+	this.filterMgr.Add(filter, mids...)
+}
+
+// 设置消息路由：[minMid, maxMid] 区间的客户端消息，由 serverType 类型的服务器处理
+//
+// 前端服务器收到不属于本服务器类型的消息时，会将其转发给对应类型的后端服务器
+func (this *Application) SetRoute(serverType string, minMid uint16, maxMid uint16) {
+	this.forwarder.AddRoute(serverType, minMid, maxMid)
+}
+
+// 设置需要同步到后端服务器的客户端 session 属性（前端服务器），需在 app 启动前调用
+//
+// 转发消息前，uid 或这些属性有变化时，先同步给后端服务器；属性值为 string 时原样同步，其他使用 json 编码
+func (this *Application) SetSyncKeys(keys ...string) {
+	this.forwarder.SetSyncKeys(keys...)
+}
+
+// 获取集群中的服务器信息集合：服务器类型 -> 服务器信息列表
+//
+// 为服务发现中的服务器；app 启动前为 servers.json 中的配置
+func (this *Application) GetServerMap() config.TServerMap {
+	if nil != this.discovery {
+		return this.discovery.GetServerMap()
 	}
+
+	return config.GetServerMap()
 }
 
-// 侦听系统信号
-func (this *Application) waitSysSignal() {
-	// 排除信号
-	signal.Ignore(syscall.Signal(10), syscall.Signal(12), syscall.SIGPIPE, syscall.SIGHUP)
-	signal.Notify(this.signalChan, syscall.SIGINT, syscall.SIGTERM)
+// 设置服务发现，需在 Run 之前调用
+//
+// 未设置时：启用 master 则使用 master 客户端，否则使用 servers.json（文件变化时自动重新加载）
+func (this *Application) SetDiscovery(d discovery.IDiscovery) {
+	this.discovery = d
+}
 
-	go func() {
-		for {
-			sig := <-this.signalChan
-			if syscall.SIGINT == sig || syscall.SIGTERM == sig {
-				zaplog.Infof("%s 服务器，正在停止中，请等待 ...", this.baseInfo.Name)
+// 添加1个集群事件监听函数，需在 Run 之前调用
+func (this *Application) AddClusterListener(fn discovery.EventFunc) {
+	this.clusterListeners = append(this.clusterListeners, fn)
+}
 
-				this.Stop()
+// 设置 serverType 类型服务器的选择器，消息转发和 rpc 调用都将使用该选择器选择服务器
+//
+// 未设置的服务器类型使用轮询选择器
+func (this *Application) SetSelector(serverType string, sel route.ISelector) {
+	this.router.SetSelector(serverType, sel)
+}
 
-				time.Sleep(C_STOP_OUT_TIME)
-				zaplog.Warnf("%s 服务器，超过 %v 秒未优雅关闭，强制关闭", this.baseInfo.Name, C_STOP_OUT_TIME)
+// 注册1个远程方法，供集群中其他服务器通过 rpc 调用
+//
+// route=路由，格式：服务器类型.服务名.方法名
+func (this *Application) HandleRpc(route string, fn rpc.HandlerFunc) {
+	this.rpcServer.Handle(route, fn)
+}
 
-				os.Exit(1)
-			} else {
-				zaplog.Errorf("异常的操作系统信号=%s", sig)
-			}
-		}
-	}()
+// 注册1个远程服务对象，供集群中其他服务器通过 rpc 调用
+//
+// svc 中签名为 func(ctx context.Context, req *Req) (*Resp, error) 的导出方法，
+// 将注册为远程方法，路由为：服务器类型.结构体名.方法名，例如 room.RoomRemote.Join
+func (this *Application) RegisterRemote(svc interface{}) error {
+	routes, err := this.rpcServer.Register(this.baseInfo.AppType, svc)
+	if nil != err {
+		zaplog.Errorf("app %s", err)
+
+		return err
+	}
+
+	zaplog.Debugf("app 注册远程服务成功。routes=%v", routes)
+
+	return nil
 }
 
-// 侦听结束信号
-func (this *Application) waitStopSignal() {
-	// 排除信号
-	signal.Ignore(syscall.Signal(10), syscall.Signal(12), syscall.SIGPIPE, syscall.SIGHUP)
-	signal.Notify(this.signalChan, syscall.SIGINT, syscall.SIGTERM)
+// 调用集群中其他服务器的远程方法，并完成请求/回复的编解码
+//
+// route=路由，格式：服务器类型.服务名.方法名；req=请求结构体指针；res=回复结构体指针
+func (this *Application) RemoteCall(ctx context.Context, route string, req interface{}, res interface{}) error {
+	return this.rpcClient.Invoke(ctx, route, req, res)
+}
 
-	for {
-		sig := <-this.signalChan
-		if syscall.SIGINT == sig || syscall.SIGTERM == sig {
-			zaplog.Infof("%s 服务器，正在停止中，请等待 ...", this.baseInfo.Name)
+// 调用集群中其他服务器的远程方法
+//
+// route=路由，格式：服务器类型.服务名.方法名；payload=请求数据
+func (this *Application) RpcCall(ctx context.Context, route string, payload []byte) ([]byte, error) {
+	return this.rpcClient.Call(ctx, route, payload)
+}
 
-			this.Stop()
+// 获取消息处理出现 panic 的总次数（handler + session + rpc 处理函数）
+func (this *Application) GetPanicCount() int64 {
+	count := this.panicCount.Load() + session.GetPanicCount()
+	if nil != this.rpcServer {
+		count += this.rpcServer.GetPanicCount()
+	}
 
-			time.Sleep(C_STOP_OUT_TIME)
-			zaplog.Warnf("%s 服务器，超过 %v 秒未优雅关闭，强制关闭", this.baseInfo.Name, C_STOP_OUT_TIME)
+	return count
+}
 
-			os.Exit(1)
-		} else {
-			zaplog.Errorf("异常的操作系统信号=%s", sig)
-		}
+// 获取网络服务上所有 session 的心跳延迟统计
+//
+// 前端服务器为客户端连接，后端服务器为前端服务器的连接；未启用网络服务时返回零值
+func (this *Application) GetLatencyStats() session.TLatencyStats {
+	if nil == this.netService {
+		return session.TLatencyStats{}
 	}
+
+	return this.netService.GetSessionManager().GetLatencyStats()
 }
 
-// 主循环
-func (this *Application) mainLoop() {
-	for {
-		select {
-		case cm := <-this.handlerChan:
-			this.delegate.OnClentMsg(cm)
-		}
+// 处理1个客户端消息：执行过滤器后，转发给后端服务器或交给 delegate 处理
+func (this *Application) handleClientMsg(msg session.ClientMsg) {
+	defer this.recoverClientMsg(msg)
+
+	// 主id不同 -- 转发给后端服务器
+	if this.forwarder.IsRemote(msg.Packet.GetMid()) {
+		this.filterMgr.Handle(msg, this.forwarder.Forward)
+
+		return
+	}
+
+	// 主id相同 -- 本地处理
+	this.filterMgr.Handle(msg, this.delegate.OnClentMsg)
+}
+
+// 捕获客户端消息处理过程中出现的 panic
+func (this *Application) recoverClientMsg(msg session.ClientMsg) {
+	err := recover()
+	if nil == err {
+		return
+	}
+
+	this.panicCount.Add(1)
+	zaplog.Errorf("app 处理客户端消息出现 panic。mid=%d，err=%v\n%s", msg.Packet.GetMid(), err, debug.Stack())
+
+	switch this.Option.PanicPolicy {
+	case model.C_PANIC_CLOSE:
+		msg.Session.Stop()
+	case model.C_PANIC_CRASH:
+		zaplog.Errorf("%s 服务器，消息处理异常，panic 策略为 crash，重新抛出 panic", this.baseInfo.Name)
+		panic(&session.CrashPanic{Value: err})
 	}
 }
 
 // 收到1个新的客户端消息
 func (this *Application) OnClientMessage(ses *session.ClientSession, packet *network.Packet) {
-	// 主id相同 -- 加入本地chan
+	msg := session.ClientMsg{
+		Session:  ses,
+		Packet:   packet,
+		RecvTime: time.Now(),
+	}
+
+	// 本地处理与转发的消息，都按分发模式交给 handleClientMsg
+	this.dispatcher.Post(msg)
+}
 
-	// 主id不同 -- 加入远程chan
+// 处理1个服务器消息
+func (this *Application) handleServerMsg(msg *session.ServerMsg) {
+	defer this.recoverServerMsg(msg)
 
-	msg := session.ClientMsg{
-		Session: ses,
-		Packet:  packet,
+	if d, ok := this.delegate.(IServerMsgDelegate); ok {
+		d.OnServerMsg(msg)
+	} else {
+		zaplog.Warnf("app 收到服务器消息，但 delegate 未实现 IServerMsgDelegate，消息丢弃。mid=%d", msg.GetPacket().GetMid())
+	}
+}
+
+// 捕获服务器消息处理过程中出现的 panic
+func (this *Application) recoverServerMsg(msg *session.ServerMsg) {
+	err := recover()
+	if nil == err {
+		return
 	}
 
-	this.handlerChan <- msg
+	this.panicCount.Add(1)
+	zaplog.Errorf("app 处理服务器消息出现 panic。mid=%d，err=%v\n%s", msg.GetPacket().GetMid(), err, debug.Stack())
+
+	switch this.Option.PanicPolicy {
+	case model.C_PANIC_CLOSE:
+		msg.GetSession().Stop()
+	case model.C_PANIC_CRASH:
+		zaplog.Errorf("%s 服务器，消息处理异常，panic 策略为 crash，重新抛出 panic", this.baseInfo.Name)
+		panic(&session.CrashPanic{Value: err})
+	}
 }
 
 // 收到1个新的服务器消息
 func (this *Application) OnServerMessage(ses *session.ServerSession, packet *network.Packet) {
-	// 主id相同 -- 加入本地chan
+	var msg *session.ServerMsg
+
+	switch packet.GetMid() {
+	case protocol.C_PKT_ID_FORWARD: // 前端服务器转发的客户端消息
+		sesId, pkt, err := session.UnwrapPacket(packet)
+		packet.Release()
+		if nil != err {
+			zaplog.Errorf("app 解析转发消息失败：%s", err)
+
+			return
+		}
+
+		msg = session.NewForwardMsg(ses, sesId, pkt, this.backendMgr.Get(ses, sesId))
+	case protocol.C_PKT_ID_SESSION_SYNC: // 前端服务器同步客户端 session
+		if err := this.backendMgr.OnSync(ses, packet); nil != err {
+			zaplog.Errorf("app %s", err)
+		}
+		packet.Release()
+
+		return
+	case protocol.C_PKT_ID_SESSION_CLOSE: // 客户端 session 已关闭
+		if err := this.backendMgr.OnClose(ses, packet); nil != err {
+			zaplog.Errorf("app %s", err)
+		}
+		packet.Release()
+
+		return
+	default:
+		msg = session.NewServerMsg(ses, packet)
+	}
 
-	// 主id不同 -- 加入远程chan
+	this.dispatcher.PostServer(msg)
 }
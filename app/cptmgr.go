@@ -4,6 +4,11 @@
 package app
 
 import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"        // 异常
 	"github.com/zpab123/sco/model" // 全局模型
 	"github.com/zpab123/zaplog"    // log
 )
@@ -14,9 +19,13 @@ import (
 // /////////////////////////////////////////////////////////////////////////////
 // ComponentManager 对象
 
-// app 组件管理
+// app 组件管理：按依赖关系顺序启动组件，按启动顺序的逆序停止组件
 type ComponentManager struct {
+	mutex        sync.RWMutex                // 读写锁
 	componentMap map[string]model.IComponent // 名字-> 组件 集合
+	names        []string                    // 按添加顺序保存的组件名字
+	depends      map[string][]string         // 名字 -> 添加时声明的依赖组件名字
+	started      []model.IComponent          // 已启动的组件，按启动顺序保存
 }
 
 // 新建1个 ComponentManager
@@ -24,6 +33,7 @@ func NewComponentManager() *ComponentManager {
 	// 组件
 	cptMgr := &ComponentManager{
 		componentMap: map[string]model.IComponent{},
+		depends:      map[string][]string{},
 	}
 
 	// 返回
@@ -31,21 +41,32 @@ func NewComponentManager() *ComponentManager {
 }
 
 // 添加1个 Component 组件
-func (this *ComponentManager) Add(cmpt model.IComponent) {
+//
+// depends=依赖的组件名字，与组件实现的 IDependent 接口合并；该组件在依赖的组件之后启动，之前停止
+func (this *ComponentManager) Add(cmpt model.IComponent, depends ...string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
 	// 获取名字
 	name := cmpt.Name()
 
 	// 组件已经存在
 	if _, ok := this.componentMap[name]; ok {
-		zaplog.Warnf("组件[*s]重复添加，新组件将覆盖旧组件", name)
+		zaplog.Warnf("组件[%s]重复添加，新组件将覆盖旧组件", name)
+	} else {
+		this.names = append(this.names, name)
 	}
 
 	// 保存组件
 	this.componentMap[name] = cmpt
+	this.depends[name] = depends
 }
 
 // 根据名字获取组件
 func (this *ComponentManager) GetComponentByName(name string) model.IComponent {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
 	cpt, ok := this.componentMap[name]
 
 	if ok {
@@ -54,3 +75,122 @@ func (this *ComponentManager) GetComponentByName(name string) model.IComponent {
 		return nil
 	}
 }
+
+// 按添加顺序查找第1个类型与 target 指向的类型匹配的组件，找到后保存到 target
+//
+// target=指向组件类型（结构体指针或接口）的指针；返回 false=不存在
+func (this *ComponentManager) Find(target interface{}) bool {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return false
+	}
+
+	t := v.Elem().Type()
+
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	for _, name := range this.names {
+		cpt := this.componentMap[name]
+		if reflect.TypeOf(cpt).AssignableTo(t) {
+			v.Elem().Set(reflect.ValueOf(cpt))
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// 按依赖关系顺序启动所有组件
+//
+// 依赖的组件不存在、存在循环依赖，或某个组件启动失败时，停止已启动的组件并返回错误
+func (this *ComponentManager) Run(ctx context.Context) error {
+	this.mutex.RLock()
+	list, err := this.sort()
+	this.mutex.RUnlock()
+
+	if nil != err {
+		return err
+	}
+
+	this.started = make([]model.IComponent, 0, len(list))
+	for _, cpt := range list {
+		if err = cpt.Run(ctx); nil != err {
+			this.Stop()
+
+			return errors.Wrapf(err, "组件[%s]启动失败", cpt.Name())
+		}
+
+		this.started = append(this.started, cpt)
+	}
+
+	return nil
+}
+
+// 按启动顺序的逆序停止所有已启动的组件
+func (this *ComponentManager) Stop() {
+	for i := len(this.started) - 1; i >= 0; i-- {
+		this.started[i].Stop()
+	}
+
+	this.started = nil
+}
+
+// 获取组件依赖的组件名字
+func (this *ComponentManager) getDepends(name string) []string {
+	deps := this.depends[name]
+
+	if d, ok := this.componentMap[name].(model.IDependent); ok {
+		deps = append(append([]string{}, deps...), d.Depends()...)
+	}
+
+	return deps
+}
+
+// 按依赖关系对组件排序（深度优先），没有依赖关系的组件保持添加顺序（需持有读锁）
+func (this *ComponentManager) sort() ([]model.IComponent, error) {
+	const (
+		visiting = 1 // 正在访问
+		visited  = 2 // 访问完成
+	)
+
+	marks := map[string]int{}
+	list := make([]model.IComponent, 0, len(this.names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("组件存在循环依赖：%v", append(path, name))
+		}
+
+		marks[name] = visiting
+		path = append(path, name)
+
+		for _, dep := range this.getDepends(name) {
+			if _, ok := this.componentMap[dep]; !ok {
+				return errors.Errorf("组件[%s]依赖的组件[%s]不存在", name, dep)
+			}
+
+			if err := visit(dep, path); nil != err {
+				return err
+			}
+		}
+
+		marks[name] = visited
+		list = append(list, this.componentMap[name])
+
+		return nil
+	}
+
+	for _, name := range this.names {
+		if err := visit(name, nil); nil != err {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
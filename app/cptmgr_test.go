@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用组件
+
+// 记录启动、停止顺序的组件
+type testComponent struct {
+	name    string    // 名字
+	depends []string  // 通过 IDependent 接口声明的依赖
+	fail    bool      // 启动是否失败
+	log     *[]string // 启动、停止记录
+}
+
+func (this *testComponent) Run(ctx context.Context) error {
+	if this.fail {
+		return errors.New("run failed")
+	}
+
+	*this.log = append(*this.log, "run:"+this.name)
+
+	return nil
+}
+
+func (this *testComponent) Stop() {
+	*this.log = append(*this.log, "stop:"+this.name)
+}
+
+func (this *testComponent) Name() string {
+	return this.name
+}
+
+func (this *testComponent) Depends() []string {
+	return this.depends
+}
+
+// 添加到 ComponentManager 的组件
+type testCptDef struct {
+	name    string   // 名字
+	adds    []string // Add 时声明的依赖
+	depends []string // IDependent 接口声明的依赖
+	fail    bool     // 启动是否失败
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 按依赖关系排序启动，逆序停止；循环依赖、依赖不存在、启动失败时返回错误并停止已启动的组件
+func TestComponentOrder(t *testing.T) {
+	tests := []struct {
+		name string       // 用例名字
+		cpts []testCptDef // 按添加顺序的组件
+		err  string       // 错误信息包含的内容，空=成功
+		want []string     // 启动、停止记录（成功时包含 Stop 的记录）
+	}{
+		{
+			name: "no depends keeps add order",
+			cpts: []testCptDef{{name: "a"}, {name: "b"}, {name: "c"}},
+			want: []string{"run:a", "run:b", "run:c", "stop:c", "stop:b", "stop:a"},
+		},
+		{
+			name: "add depends",
+			cpts: []testCptDef{{name: "net", adds: []string{"fwd"}}, {name: "fwd", adds: []string{"disc"}}, {name: "disc"}},
+			want: []string{"run:disc", "run:fwd", "run:net", "stop:net", "stop:fwd", "stop:disc"},
+		},
+		{
+			name: "interface depends merged",
+			cpts: []testCptDef{{name: "pool", depends: []string{"db"}, adds: []string{"log"}}, {name: "db"}, {name: "log"}},
+			want: []string{"run:log", "run:db", "run:pool", "stop:pool", "stop:db", "stop:log"},
+		},
+		{
+			name: "diamond",
+			cpts: []testCptDef{{name: "top", adds: []string{"l", "r"}}, {name: "l", adds: []string{"base"}}, {name: "r", adds: []string{"base"}}, {name: "base"}},
+			want: []string{"run:base", "run:l", "run:r", "run:top", "stop:top", "stop:r", "stop:l", "stop:base"},
+		},
+		{
+			name: "cycle",
+			cpts: []testCptDef{{name: "a", adds: []string{"b"}}, {name: "b", depends: []string{"c"}}, {name: "c", adds: []string{"a"}}},
+			err:  "循环依赖",
+		},
+		{
+			name: "self cycle",
+			cpts: []testCptDef{{name: "a", adds: []string{"a"}}},
+			err:  "循环依赖",
+		},
+		{
+			name: "missing depend",
+			cpts: []testCptDef{{name: "a", adds: []string{"none"}}},
+			err:  "不存在",
+		},
+		{
+			name: "run failed stops started",
+			cpts: []testCptDef{{name: "a"}, {name: "b", adds: []string{"a"}, fail: true}, {name: "c"}},
+			err:  "启动失败",
+			want: []string{"run:a", "stop:a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			m := NewComponentManager()
+			for _, def := range tt.cpts {
+				m.Add(&testComponent{name: def.name, depends: def.depends, fail: def.fail, log: &log}, def.adds...)
+			}
+
+			err := m.Run(context.Background())
+			if "" == tt.err {
+				if nil != err {
+					t.Fatal(err)
+				}
+
+				m.Stop()
+			} else if nil == err || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("错误不符合预期。got=%v，want=%q", err, tt.err)
+			}
+
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("启动、停止顺序错误。got=%v，want=%v", log, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// /////////////////////////////////////////////////////////////////////////////
+// app 消息分发
+
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zpab123/sco/session" // 会话
+	"github.com/zpab123/syncutil"    // 原子变量
+	"github.com/zpab123/zaplog"      // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Dispatcher 对象
+
+// 消息分发器：根据分发模式，将消息交给 handler 处理
+type Dispatcher struct {
+	mode          uint32                    // 分发模式
+	clientHandler func(session.ClientMsg)   // 客户端消息处理函数
+	serverHandler func(*session.ServerMsg)  // 服务器消息处理函数
+	clientChans   []chan session.ClientMsg  // 客户端消息通道（单线程模式=1个，worker 模式=每个 worker 1个）
+	serverChans   []chan *session.ServerMsg // 服务器消息通道（与 clientChans 一一对应）
+	stopGroup     sync.WaitGroup            // 停止等待组
+	pending       syncutil.AtomicInt64      // 已分发、尚未处理完成的消息数量
+	draining      syncutil.AtomicUint32     // 是否正在排空：1=不再接收新消息
+}
+
+// 新建1个 Dispatcher
+//
+// mode=分发模式；workerNum=worker 模式下的 worker 数量；clientSize/serverSize=每个消息通道长度
+func NewDispatcher(mode uint32, workerNum int, clientSize int, serverSize int, clientHandler func(session.ClientMsg), serverHandler func(*session.ServerMsg)) *Dispatcher {
+	// 通道数量
+	var num int
+	switch mode {
+	case C_DISPATCH_WORKER:
+		num = workerNum
+		if num <= 0 {
+			num = C_WORKER_NUM
+		}
+	case C_DISPATCH_DIRECT:
+		num = 0
+	default:
+		mode = C_DISPATCH_SINGLE
+		num = 1
+	}
+
+	// 创建对象
+	dp := &Dispatcher{
+		mode:          mode,
+		clientHandler: clientHandler,
+		serverHandler: serverHandler,
+		clientChans:   make([]chan session.ClientMsg, num),
+		serverChans:   make([]chan *session.ServerMsg, num),
+	}
+
+	for i := 0; i < num; i++ {
+		dp.clientChans[i] = make(chan session.ClientMsg, clientSize)
+		dp.serverChans[i] = make(chan *session.ServerMsg, serverSize)
+	}
+
+	return dp
+}
+
+// 启动所有分发 goroutine，ctx 结束后退出
+func (this *Dispatcher) Run(ctx context.Context) {
+	for i := range this.clientChans {
+		this.stopGroup.Add(1)
+
+		go this.loop(ctx, this.clientChans[i], this.serverChans[i])
+	}
+
+	zaplog.Debugf("Dispatcher 启动成功。模式=%d，goroutine 数量=%d", this.mode, len(this.clientChans))
+}
+
+// 等待所有分发 goroutine 退出
+func (this *Dispatcher) Wait() {
+	this.stopGroup.Wait()
+}
+
+// 停止接收新消息，并等待已分发的消息处理完成
+//
+// 超过 deadline 仍未处理完成，返回 false
+func (this *Dispatcher) Drain(deadline time.Time) bool {
+	this.draining.Store(1)
+
+	for this.pending.Load() > 0 {
+		if !time.Now().Before(deadline) {
+			zaplog.Warnf("Dispatcher 等待消息处理超时。剩余数量=%d", this.pending.Load())
+
+			return false
+		}
+
+		time.Sleep(C_DRAIN_INTERVAL)
+	}
+
+	return true
+}
+
+// 分发1个客户端消息
+//
+// worker 模式下，同一个 session 的消息总是交给同一个 worker，保证消息顺序
+func (this *Dispatcher) Post(msg session.ClientMsg) {
+	if !this.accept() {
+		zaplog.Debugf("Dispatcher 正在关闭，客户端消息丢弃。mid=%d", msg.Packet.GetMid())
+		msg.Packet.Release()
+
+		return
+	}
+
+	switch this.mode {
+	case C_DISPATCH_DIRECT:
+		this.handleClient(msg)
+	case C_DISPATCH_WORKER:
+		this.clientChans[this.index(msg.Session.GetId())] <- msg
+	default:
+		this.clientChans[0] <- msg
+	}
+}
+
+// 分发1个服务器消息
+//
+// worker 模式下，同一个 session 的消息总是交给同一个 worker，保证消息顺序；前端服务器转发的消息，按客户端 session id 分配 worker
+func (this *Dispatcher) PostServer(msg *session.ServerMsg) {
+	if !this.accept() {
+		zaplog.Debugf("Dispatcher 正在关闭，服务器消息丢弃。mid=%d", msg.GetPacket().GetMid())
+		msg.GetPacket().Release()
+
+		return
+	}
+
+	switch this.mode {
+	case C_DISPATCH_DIRECT:
+		this.handleServer(msg)
+	case C_DISPATCH_WORKER:
+		this.serverChans[this.index(serverMsgKey(msg))] <- msg
+	default:
+		this.serverChans[0] <- msg
+	}
+}
+
+// 释放通道中尚未处理的消息：Drain 超时后，需在 Wait 返回之后调用
+//
+// 返回释放的消息数量
+func (this *Dispatcher) Discard() int {
+	count := 0
+
+	for i := range this.clientChans {
+		for len(this.clientChans[i]) > 0 {
+			cm := <-this.clientChans[i]
+			cm.Packet.Release()
+			this.pending.Add(-1)
+			count++
+		}
+
+		for len(this.serverChans[i]) > 0 {
+			sm := <-this.serverChans[i]
+			sm.GetPacket().Release()
+			this.pending.Add(-1)
+			count++
+		}
+	}
+
+	if count > 0 {
+		zaplog.Warnf("Dispatcher 释放未处理的消息。数量=%d", count)
+	}
+
+	return count
+}
+
+// 记录1个待处理消息；正在排空时返回 false
+//
+// 先计数后检查，保证 Drain 看到计数为0之后，不会再有消息被处理
+func (this *Dispatcher) accept() bool {
+	this.pending.Add(1)
+
+	if this.draining.Load() == 1 {
+		this.pending.Add(-1)
+
+		return false
+	}
+
+	return true
+}
+
+// 处理1个客户端消息，并减少待处理计数
+func (this *Dispatcher) handleClient(msg session.ClientMsg) {
+	defer this.pending.Add(-1)
+
+	this.clientHandler(msg)
+}
+
+// 处理1个服务器消息，并减少待处理计数
+func (this *Dispatcher) handleServer(msg *session.ServerMsg) {
+	defer this.pending.Add(-1)
+
+	this.serverHandler(msg)
+}
+
+// 根据 session id 计算 worker 索引
+func (this *Dispatcher) index(sesId int64) uint64 {
+	return uint64(sesId) % uint64(len(this.clientChans))
+}
+
+// 服务器消息的 worker 索引依据：转发消息=客户端 session id，其他=ServerSession id
+//
+// 同一个前端服务器转发的所有客户端消息，都经由同1个 ServerSession，按其 id 分配将集中到1个 worker
+func serverMsgKey(msg *session.ServerMsg) int64 {
+	if msg.IsForward() {
+		return msg.GetClientSessionId()
+	}
+
+	return msg.GetSession().GetId()
+}
+
+// 分发循环
+func (this *Dispatcher) loop(ctx context.Context, clientChan chan session.ClientMsg, serverChan chan *session.ServerMsg) {
+	defer this.stopGroup.Done()
+
+	for {
+		select {
+		case cm := <-clientChan:
+			this.handleClient(cm)
+		case sm := <-serverChan:
+			this.handleServer(sm)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/network" // 网络
+	"github.com/zpab123/sco/session" // 会话
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 各分发模式下，所有消息都被处理，且同一 session 的消息保持顺序
+func TestDispatcherModes(t *testing.T) {
+	tests := []struct {
+		name string // 用例名字
+		mode uint32 // 分发模式
+	}{
+		{"single", C_DISPATCH_SINGLE},
+		{"worker", C_DISPATCH_WORKER},
+		{"direct", C_DISPATCH_DIRECT},
+	}
+
+	const sesNum, msgNum = 4, 50
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var wg sync.WaitGroup
+			got := map[int64][]uint16{} // session id -> 收到的消息 id
+
+			handler := func(msg session.ClientMsg) {
+				defer wg.Done()
+				defer msg.Packet.Release()
+
+				mutex.Lock()
+				got[msg.Session.GetId()] = append(got[msg.Session.GetId()], msg.Packet.GetMid())
+				mutex.Unlock()
+			}
+
+			dp := NewDispatcher(tt.mode, 3, 8, 8, handler, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			dp.Run(ctx)
+			defer func() {
+				cancel()
+				dp.Wait()
+			}()
+
+			sessions := make([]*session.ClientSession, sesNum)
+			for i := range sessions {
+				sessions[i] = newTestClientSession(t)
+				sessions[i].SetId(int64(i + 1))
+			}
+
+			wg.Add(sesNum * msgNum)
+			for m := 0; m < msgNum; m++ {
+				for _, ses := range sessions {
+					dp.Post(session.ClientMsg{Session: ses, Packet: network.NewPacket(uint16(1000 + m))})
+				}
+			}
+			wg.Wait()
+
+			for _, ses := range sessions {
+				mids := got[ses.GetId()]
+				if len(mids) != msgNum {
+					t.Fatalf("消息数量错误。session=%d，got=%d，want=%d", ses.GetId(), len(mids), msgNum)
+				}
+
+				for i, mid := range mids {
+					if mid != uint16(1000+i) {
+						t.Fatalf("消息顺序错误。session=%d，index=%d，got=%d", ses.GetId(), i, mid)
+					}
+				}
+			}
+		})
+	}
+}
+
+// 排空超时后，释放通道中尚未处理的消息；排空期间不再接收新消息
+func TestDispatcherDiscard(t *testing.T) {
+	dp := NewDispatcher(C_DISPATCH_SINGLE, 0, 8, 8, func(session.ClientMsg) {}, nil)
+	ses := newTestClientSession(t)
+
+	// 未启动分发 goroutine：消息留在通道中
+	var pkts []*network.Packet
+	for i := 0; i < 3; i++ {
+		pkt := network.NewPacket(1000)
+		pkt.AppendString("hello")
+		pkts = append(pkts, pkt)
+		dp.Post(session.ClientMsg{Session: ses, Packet: pkt})
+	}
+
+	if dp.Drain(time.Now()) {
+		t.Fatal("有未处理的消息时，Drain 应返回 false")
+	}
+
+	// 排空期间收到的消息直接释放
+	late := network.NewPacket(1000)
+	late.AppendString("hello")
+	dp.Post(session.ClientMsg{Session: ses, Packet: late})
+	if 0 != late.GetBodyLen() {
+		t.Error("排空期间收到的消息未释放")
+	}
+
+	if n := dp.Discard(); 3 != n {
+		t.Errorf("释放的消息数量错误。got=%d，want=3", n)
+	}
+
+	for i, pkt := range pkts {
+		if 0 != pkt.GetBodyLen() {
+			t.Errorf("未处理的消息未释放。index=%d", i)
+		}
+	}
+
+	if !dp.Drain(time.Now()) {
+		t.Error("释放后仍有待处理的消息")
+	}
+}
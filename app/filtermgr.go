@@ -0,0 +1,165 @@
+// /////////////////////////////////////////////////////////////////////////////
+// app 消息过滤器管理
+
+package app
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/sco/scoerr"   // 异常
+	"github.com/zpab123/sco/session"  // 会话
+	"github.com/zpab123/zaplog"       // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// FilterManager 对象
+
+// 消息过滤器管理
+type FilterManager struct {
+	mutex        sync.RWMutex               // 过滤器读写锁
+	befores      []IBeforeFilter            // 全局前置过滤器
+	afters       []IAfterFilter             // 全局后置过滤器
+	routeBefores map[uint16][]IBeforeFilter // 消息id -> 前置过滤器
+	routeAfters  map[uint16][]IAfterFilter  // 消息id -> 后置过滤器
+}
+
+// 新建1个 FilterManager
+func NewFilterManager() *FilterManager {
+	fm := &FilterManager{
+		routeBefores: map[uint16][]IBeforeFilter{},
+		routeAfters:  map[uint16][]IAfterFilter{},
+	}
+
+	return fm
+}
+
+// 添加1个过滤器
+//
+// filter 需实现 IBeforeFilter 或 IAfterFilter（或者全部实现）；mids 为空=全局过滤器，否则只过滤这些消息id
+func (this *FilterManager) Add(filter interface{}, mids ...uint16) {
+	bf, isBefore := filter.(IBeforeFilter)
+	af, isAfter := filter.(IAfterFilter)
+
+	if !isBefore && !isAfter {
+		zaplog.Warnf("添加过滤器失败：%T 未实现 IBeforeFilter 或 IAfterFilter", filter)
+
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	// 全局
+	if len(mids) == 0 {
+		if isBefore {
+			this.befores = append(this.befores, bf)
+		}
+
+		if isAfter {
+			this.afters = append(this.afters, af)
+		}
+
+		return
+	}
+
+	// 路由
+	for _, mid := range mids {
+		if isBefore {
+			this.routeBefores[mid] = append(this.routeBefores[mid], bf)
+		}
+
+		if isAfter {
+			this.routeAfters[mid] = append(this.routeAfters[mid], af)
+		}
+	}
+}
+
+// 执行过滤器，并调用 handler 处理消息
+//
+// 任意前置过滤器返回 error 时，中断处理，向客户端返回错误消息，并释放 msg.Packet；否则 msg.Packet 交由 handler 持有。
+// 后置过滤器总是会执行，执行期间 msg.Packet 不会被回收
+func (this *FilterManager) Handle(msg session.ClientMsg, handler func(session.ClientMsg)) {
+	mid := msg.Packet.GetMid()
+
+	this.mutex.RLock()
+	befores, routeBefores := this.befores, this.routeBefores[mid]
+	afters, routeAfters := this.afters, this.routeAfters[mid]
+	this.mutex.RUnlock()
+
+	// 前置过滤器
+	err := this.before(befores, msg)
+	if nil == err {
+		err = this.before(routeBefores, msg)
+	}
+
+	// 消息被中断：handler 不再处理，后置过滤器执行完成后释放
+	if nil != err {
+		defer msg.Packet.Release()
+
+		this.sendError(msg, err)
+	} else {
+		// handler 可能释放 packet：后置过滤器执行期间额外持有1次引用
+		msg.Packet.Retain()
+		defer msg.Packet.Release()
+
+		handler(msg)
+	}
+
+	// 后置过滤器
+	this.after(routeAfters, msg, err)
+	this.after(afters, msg, err)
+}
+
+// 执行前置过滤器
+func (this *FilterManager) before(filters []IBeforeFilter, msg session.ClientMsg) error {
+	for _, f := range filters {
+		if err := f.Before(msg); nil != err {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 执行后置过滤器
+func (this *FilterManager) after(filters []IAfterFilter, msg session.ClientMsg, err error) {
+	for _, f := range filters {
+		f.After(msg, err)
+	}
+}
+
+// 向客户端返回错误消息
+func (this *FilterManager) sendError(msg session.ClientMsg, err error) {
+	pkt, e := newErrorPacket(msg.Packet.GetMid(), err)
+	if nil != e {
+		zaplog.Errorf("过滤器中断消息，但编码错误消息失败：%s", e)
+
+		return
+	}
+
+	if nil != msg.Session.SendPacket(pkt) {
+		pkt.Release()
+	}
+}
+
+// 创建1个错误消息：err 为 *scoerr.CodeError 时使用其错误码，否则为 C_CODE_ERROR
+func newErrorPacket(mid uint16, err error) (*network.Packet, error) {
+	res := &protocol.ErrorRes{
+		Mid:  mid,
+		Code: scoerr.GetErrorCode(err, protocol.C_CODE_ERROR),
+		Msg:  err.Error(),
+	}
+
+	data, e := json.Marshal(res)
+	if nil != e {
+		return nil, e
+	}
+
+	pkt := network.NewPacket(protocol.C_PKT_ID_ERROR)
+	pkt.AppendBytes(data)
+
+	return pkt, nil
+}
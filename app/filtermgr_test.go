@@ -0,0 +1,186 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/sco/scoerr"   // 异常
+	"github.com/zpab123/sco/session"  // 会话
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用过滤器
+
+// 记录调用顺序的过滤器
+type testFilter struct {
+	name  string    // 名字
+	log   *[]string // 调用记录
+	err   error     // Before 返回的错误
+	bodys *[]string // After 中读取到的消息内容
+}
+
+func (this *testFilter) Before(msg session.ClientMsg) error {
+	*this.log = append(*this.log, "before:"+this.name)
+
+	return this.err
+}
+
+func (this *testFilter) After(msg session.ClientMsg, err error) {
+	*this.log = append(*this.log, "after:"+this.name)
+
+	if nil != this.bodys {
+		*this.bodys = append(*this.bodys, string(msg.Packet.GetBody()))
+	}
+}
+
+// 丢弃所有消息
+type testClientHandler struct{}
+
+func (testClientHandler) OnClientMessage(ses *session.ClientSession, packet *network.Packet) {}
+
+// 创建1个未启动的 ClientSession：发送的消息全部失败
+func newTestClientSession(t *testing.T) *session.ClientSession {
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+
+	ses, err := session.NewClientSession(&network.Socket{Conn: c1}, session.NewSessionManager(), testClientHandler{}, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	return ses.(*session.ClientSession)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 过滤器执行顺序与中断
+func TestFilterOrder(t *testing.T) {
+	reject := errors.New("reject")
+
+	tests := []struct {
+		name      string   // 用例名字
+		mid       uint16   // 消息id
+		globalErr error    // 全局前置过滤器返回的错误
+		routeErr  error    // 路由前置过滤器返回的错误
+		want      []string // 调用记录
+	}{
+		{
+			name: "pass",
+			mid:  1000,
+			want: []string{"before:g", "before:r", "handler", "after:r", "after:g"},
+		},
+		{
+			name: "other mid",
+			mid:  1001,
+			want: []string{"before:g", "handler", "after:g"},
+		},
+		{
+			name:      "global reject",
+			mid:       1000,
+			globalErr: reject,
+			want:      []string{"before:g", "after:r", "after:g"},
+		},
+		{
+			name:     "route reject",
+			mid:      1000,
+			routeErr: reject,
+			want:     []string{"before:g", "before:r", "after:r", "after:g"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			fm := NewFilterManager()
+			fm.Add(&testFilter{name: "g", log: &log, err: tt.globalErr})
+			fm.Add(&testFilter{name: "r", log: &log, err: tt.routeErr}, 1000)
+
+			pkt := network.NewPacket(tt.mid)
+			pkt.AppendString("hello")
+			msg := session.ClientMsg{Session: newTestClientSession(t), Packet: pkt}
+
+			fm.Handle(msg, func(session.ClientMsg) {
+				log = append(log, "handler")
+			})
+
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("调用顺序错误。got=%v，want=%v", log, tt.want)
+			}
+
+			// 中断时释放 packet；否则 packet 由 handler 持有
+			rejected := nil != tt.globalErr || nil != tt.routeErr
+			if released := 0 == pkt.GetBodyLen(); released != rejected {
+				t.Errorf("packet 释放错误。released=%v，rejected=%v", released, rejected)
+			}
+		})
+	}
+}
+
+// handler 释放 packet 后，后置过滤器仍能读取消息内容
+func TestFilterAfterKeepsPacket(t *testing.T) {
+	var log, bodys []string
+	fm := NewFilterManager()
+	fm.Add(&testFilter{name: "g", log: &log, bodys: &bodys})
+
+	pkt := network.NewPacket(1000)
+	pkt.AppendString("hello")
+	msg := session.ClientMsg{Session: newTestClientSession(t), Packet: pkt}
+	want := string(pkt.GetBody())
+
+	fm.Handle(msg, func(msg session.ClientMsg) {
+		msg.Packet.Release()
+	})
+
+	if len(bodys) != 1 || bodys[0] != want {
+		t.Errorf("后置过滤器读取的消息内容错误。got=%q，want=%q", bodys, want)
+	}
+
+	if 0 != pkt.GetBodyLen() {
+		t.Error("后置过滤器执行完成后，packet 未释放")
+	}
+}
+
+// 前置过滤器中断时，返回给客户端的错误消息
+func TestFilterErrorPacket(t *testing.T) {
+	tests := []struct {
+		name string // 用例名字
+		err  error  // 前置过滤器返回的错误
+		code uint32 // 错误码
+	}{
+		{"plain error", errors.New("denied"), protocol.C_CODE_ERROR},
+		{"code error", scoerr.NewCodeError(403, "forbidden"), 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := newErrorPacket(1000, tt.err)
+			if nil != err {
+				t.Fatal(err)
+			}
+			defer pkt.Release()
+
+			if pkt.GetMid() != protocol.C_PKT_ID_ERROR {
+				t.Fatalf("错误消息 id 错误。got=%d，want=%d", pkt.GetMid(), protocol.C_PKT_ID_ERROR)
+			}
+
+			res := protocol.ErrorRes{}
+			if err = json.Unmarshal(pkt.GetBody(), &res); nil != err {
+				t.Fatal(err)
+			}
+
+			want := protocol.ErrorRes{Mid: 1000, Code: tt.code, Msg: tt.err.Error()}
+			if res != want {
+				t.Errorf("错误消息内容错误。got=%+v，want=%+v", res, want)
+			}
+		})
+	}
+}
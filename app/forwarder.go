@@ -0,0 +1,489 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 前端服务器 -> 后端服务器 消息转发组件
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"            // 异常
+	"github.com/zpab123/sco/config"    // 配置管理
+	"github.com/zpab123/sco/discovery" // 服务发现
+	"github.com/zpab123/sco/network"   // 网络
+	"github.com/zpab123/sco/protocol"  // 通信协议
+	"github.com/zpab123/sco/route"     // 路由
+	"github.com/zpab123/sco/session"   // 会话
+	"github.com/zpab123/zaplog"        // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Forwarder 对象
+
+// 消息路由：消息id 区间 -> 服务器类型
+type tRoute struct {
+	minMid     uint16 // 最小消息id（包含）
+	maxMid     uint16 // 最大消息id（包含）
+	serverType string // 服务器类型
+}
+
+// 后端服务器连接
+type tLink struct {
+	info config.TServerInfo     // 建立连接时的服务器信息
+	ses  *session.ServerSession // 连接
+}
+
+// 正在进行的后端服务器连接：同一服务器同时只有1次连接，其他转发等待其结果
+type tDial struct {
+	done chan struct{}          // 连接完成通知
+	ses  *session.ServerSession // 连接成功后的连接
+	err  error                  // 连接失败的错误
+}
+
+// 消息转发组件：将不属于本服务器类型的客户端消息，转发给对应类型的后端服务器
+type Forwarder struct {
+	*session.SessionManager                                             // 后端服务器连接管理
+	cmptName                string                                      // 组件名字
+	appType                 string                                      // 本服务器类型
+	source                  string                                      // 本服务器名字
+	routes                  []tRoute                                    // 消息路由表
+	mutex                   sync.Mutex                                  // links、dials 互斥锁
+	links                   map[string]*tLink                           // 服务器名字 -> 后端服务器连接
+	dials                   map[config.TServerInfo]*tDial               // 服务器信息 -> 正在进行的连接
+	clientMgr               *session.SessionManager                     // 客户端 session 管理
+	handler                 session.IServerMsgHandler                   // 非转发类服务器消息处理
+	sesOpt                  *session.TServerSessionOpt                  // 后端服务器连接配置参数
+	router                  *route.Router                               // 服务器路由
+	discovery               discovery.IDiscovery                        // 服务发现，nil=使用 servers.json
+	bindMutex               sync.Mutex                                  // binds 互斥锁
+	binds                   map[int64]map[string]*config.TServerInfo    // 客户端 sesId -> 服务器类型 -> 绑定的服务器
+	syncKeys                []string                                    // 需要同步到后端服务器的客户端 session 属性
+	syncMutex               sync.Mutex                                  // synced 互斥锁
+	synced                  map[int64]map[*session.ServerSession]uint64 // 客户端 sesId -> 后端服务器连接 -> 已同步的版本
+}
+
+// 新建1个 Forwarder
+//
+// appType=本服务器类型；handler=后端服务器发来的非转发类消息处理；router=服务器路由，nil=使用轮询选择
+func NewForwarder(appType string, handler session.IServerMsgHandler, router *route.Router) *Forwarder {
+	if nil == router {
+		router = route.NewRouter()
+	}
+
+	fw := &Forwarder{
+		SessionManager: session.NewSessionManager(),
+		cmptName:       C_CMPT_NAME_FORWARDER,
+		appType:        appType,
+		links:          map[string]*tLink{},
+		dials:          map[config.TServerInfo]*tDial{},
+		handler:        handler,
+		router:         router,
+		binds:          map[int64]map[string]*config.TServerInfo{},
+		synced:         map[int64]map[*session.ServerSession]uint64{},
+	}
+
+	return fw
+}
+
+// 启动组件 [IComponent 接口]
+func (this *Forwarder) Run(ctx context.Context) error {
+	zaplog.Infof("Forwarder 组件启动成功。路由数量=%d", len(this.routes))
+
+	return nil
+}
+
+// 停止组件：关闭所有后端服务器连接 [IComponent 接口]
+func (this *Forwarder) Stop() {
+	this.CloseAllSession()
+
+	zaplog.Infof("Forwarder 组件停止成功")
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *Forwarder) Name() string {
+	return this.cmptName
+}
+
+// 添加1条路由：[minMid, maxMid] 区间的消息，由 serverType 类型的服务器处理
+func (this *Forwarder) AddRoute(serverType string, minMid uint16, maxMid uint16) {
+	r := tRoute{
+		minMid:     minMid,
+		maxMid:     maxMid,
+		serverType: serverType,
+	}
+
+	this.routes = append(this.routes, r)
+}
+
+// 设置客户端 session 管理对象，以及后端服务器连接配置参数
+func (this *Forwarder) SetClientSessionManager(mgr *session.SessionManager, opt *session.TServerSessionOpt) {
+	this.clientMgr = mgr
+	this.sesOpt = opt
+
+	if nil != mgr {
+		mgr.AddCloseHandler(this.unbind)
+	}
+}
+
+// 设置本服务器名字：同步给后端服务器，用于将推送/踢下线送回本服务器（需在组件启动前设置）
+func (this *Forwarder) SetSource(name string) {
+	this.source = name
+}
+
+// 设置需要同步到后端服务器的客户端 session 属性（需在组件启动前设置）
+//
+// 转发消息前，uid 或属性有变化时，先向后端服务器同步1次
+func (this *Forwarder) SetSyncKeys(keys ...string) {
+	this.syncKeys = keys
+}
+
+// 设置服务发现：转发时，从服务发现中选择后端服务器（需在组件启动前设置）
+func (this *Forwarder) SetDiscovery(d discovery.IDiscovery) {
+	this.discovery = d
+}
+
+// 获取消息对应的服务器类型
+//
+// 返回 ""=没有对应路由
+func (this *Forwarder) GetServerType(mid uint16) string {
+	for _, r := range this.routes {
+		if mid >= r.minMid && mid <= r.maxMid {
+			return r.serverType
+		}
+	}
+
+	return ""
+}
+
+// 消息是否需要转发给其他类型的服务器
+//
+// 返回 false=消息属于本服务器，需要本地处理
+func (this *Forwarder) IsRemote(mid uint16) bool {
+	serverType := this.GetServerType(mid)
+
+	return "" != serverType && serverType != this.appType
+}
+
+// 转发1个客户端消息，完成后释放 msg.Packet
+//
+// 作为过滤器链的最终处理函数，只转发 IsRemote 返回 true 的消息
+func (this *Forwarder) Forward(msg session.ClientMsg) {
+	ses := msg.Session
+	pkt := msg.Packet
+	defer pkt.Release()
+	serverType := this.GetServerType(pkt.GetMid())
+
+	link, err := this.getLink(serverType, ses)
+	if nil != err {
+		zaplog.Errorf("Forwarder 转发消息失败。mid=%d，serverType=%s，err=%s", pkt.GetMid(), serverType, err)
+
+		return
+	}
+
+	this.sync(link, ses)
+
+	wp := session.WrapPacket(protocol.C_PKT_ID_FORWARD, ses.GetId(), pkt)
+	if err = link.SendPacket(wp); nil != err {
+		wp.Release()
+		zaplog.Errorf("Forwarder 转发消息失败。mid=%d，serverType=%s，err=%s", pkt.GetMid(), serverType, err)
+	}
+}
+
+// 某个后端服务器连接关闭 [ISessionManage 接口]
+func (this *Forwarder) OnSessionClose(ses session.ISession, reason session.CloseReason) {
+	this.mutex.Lock()
+	for name, l := range this.links {
+		if l.ses == ses {
+			delete(this.links, name)
+			zaplog.Infof("Forwarder 后端服务器连接关闭。name=%s，reason=%s", name, reason)
+
+			break
+		}
+	}
+	this.mutex.Unlock()
+
+	// 移除该连接的同步记录：重新连接后需要重新同步
+	if link, ok := ses.(*session.ServerSession); ok {
+		this.syncMutex.Lock()
+		for _, links := range this.synced {
+			delete(links, link)
+		}
+		this.syncMutex.Unlock()
+	}
+
+	this.SessionManager.OnSessionClose(ses, reason)
+}
+
+// 收到后端服务器消息 [IServerMsgHandler 接口]
+func (this *Forwarder) OnServerMessage(ses *session.ServerSession, pkt *network.Packet) {
+	switch pkt.GetMid() {
+	case protocol.C_PKT_ID_BACKWARD: // 回复/推送给客户端
+		this.backward(pkt)
+	case protocol.C_PKT_ID_SESSION_KICK: // 踢客户端下线
+		this.kick(pkt)
+	case protocol.C_PKT_ID_SHUTDOWN: // 后端服务器即将关闭：保留连接，等待已转发消息的回复
+		zaplog.Infof("Forwarder 后端服务器即将关闭。sesId=%d", ses.GetId())
+		pkt.Release()
+	default:
+		this.handler.OnServerMessage(ses, pkt)
+	}
+}
+
+// 将后端服务器的回复/推送，转交给客户端
+func (this *Forwarder) backward(wp *network.Packet) {
+	defer wp.Release()
+
+	sesId, pkt, err := session.UnwrapPacket(wp)
+	if nil != err {
+		zaplog.Errorf("Forwarder 解析后端服务器消息失败：%s", err)
+
+		return
+	}
+
+	var cs *session.ClientSession
+	if nil != this.clientMgr {
+		cs, _ = this.clientMgr.GetSession(sesId).(*session.ClientSession)
+	}
+
+	if nil == cs {
+		zaplog.Debugf("Forwarder 客户端 session 不存在，消息丢弃。sesId=%d，mid=%d", sesId, pkt.GetMid())
+		pkt.Release()
+
+		return
+	}
+
+	if err = cs.SendPacket(pkt); nil != err {
+		zaplog.Debugf("Forwarder 转交消息给客户端失败。sesId=%d，mid=%d，err=%s", sesId, pkt.GetMid(), err)
+		pkt.Release()
+	}
+}
+
+// 后端服务器通知将客户端踢下线
+func (this *Forwarder) kick(pkt *network.Packet) {
+	defer pkt.Release()
+
+	var kick protocol.SessionKick
+	if err := json.Unmarshal(pkt.GetBody(), &kick); nil != err {
+		zaplog.Errorf("Forwarder 解析踢下线消息失败：%s", err)
+
+		return
+	}
+
+	if nil == this.clientMgr {
+		return
+	}
+
+	if err := this.clientMgr.Kick(kick.SesId, kick.Reason); nil != err {
+		zaplog.Debugf("Forwarder 踢客户端下线失败。sesId=%d，err=%s", kick.SesId, err)
+	}
+}
+
+// 客户端 session 的 uid 或属性有变化时，向后端服务器同步
+func (this *Forwarder) sync(link *session.ServerSession, ses *session.ClientSession) {
+	uid, attrs, ver := ses.GetSyncInfo(this.syncKeys)
+	sesId := ses.GetId()
+
+	this.syncMutex.Lock()
+	old, ok := this.synced[sesId][link]
+	this.syncMutex.Unlock()
+
+	if ok && old == ver {
+		return
+	}
+
+	info := protocol.SessionSync{
+		Frontend: this.source,
+		SesId:    sesId,
+		Uid:      uid,
+		Attrs:    attrs,
+	}
+
+	if err := this.notify(link, protocol.C_PKT_ID_SESSION_SYNC, &info); nil != err {
+		zaplog.Errorf("Forwarder 同步客户端 session 失败。sesId=%d，err=%s", sesId, err)
+
+		return
+	}
+
+	// 发送成功后记录已同步的版本：发送失败时，下次转发重新同步
+	this.syncMutex.Lock()
+	links, ok := this.synced[sesId]
+	if !ok {
+		links = map[*session.ServerSession]uint64{}
+		this.synced[sesId] = links
+	}
+	links[link] = ver
+	this.syncMutex.Unlock()
+}
+
+// 向后端服务器发送1个 json 编码的通知
+func (this *Forwarder) notify(link *session.ServerSession, mid uint16, v interface{}) error {
+	data, err := json.Marshal(v)
+	if nil != err {
+		return err
+	}
+
+	pkt := network.NewPacket(mid)
+	pkt.AppendBytes(data)
+
+	if err = link.SendPacket(pkt); nil != err {
+		pkt.Release()
+
+		return err
+	}
+
+	return nil
+}
+
+// 获取1个 serverType 类型后端服务器的连接，不存在则创建
+func (this *Forwarder) getLink(serverType string, ses *session.ClientSession) (*session.ServerSession, error) {
+	// 选择服务器
+	var list []*config.TServerInfo
+	if nil != this.discovery {
+		list = this.discovery.GetServers(serverType)
+	} else {
+		list = config.GetServerMap()[serverType]
+	}
+
+	if len(list) == 0 {
+		return nil, errors.Errorf("没有 %s 类型的服务器", serverType)
+	}
+
+	bound, err := this.bind(serverType, ses, list)
+	if nil != err {
+		return nil, err
+	}
+	info := *bound
+
+	// 已连接
+	this.mutex.Lock()
+	if l, ok := this.links[info.Name]; ok && l.info == info {
+		this.mutex.Unlock()
+
+		return l.ses, nil
+	}
+
+	// 正在连接：等待其结果
+	if d, ok := this.dials[info]; ok {
+		this.mutex.Unlock()
+		<-d.done
+
+		return d.ses, d.err
+	}
+
+	d := &tDial{
+		done: make(chan struct{}),
+	}
+	this.dials[info] = d
+	this.mutex.Unlock()
+
+	// 连接：不持有锁，1个服务器无响应时，不影响其他服务器的转发
+	d.ses, d.err = this.dial(&info)
+
+	// 保存连接：服务器信息已变化（例如地址变化）时，关闭旧连接
+	var stale *session.ServerSession
+	this.mutex.Lock()
+	delete(this.dials, info)
+	if nil == d.err {
+		if l, ok := this.links[info.Name]; ok {
+			stale = l.ses
+		}
+
+		this.links[info.Name] = &tLink{
+			info: info,
+			ses:  d.ses,
+		}
+	}
+	this.mutex.Unlock()
+	close(d.done)
+
+	if nil != stale {
+		zaplog.Infof("Forwarder 后端服务器信息已变化，关闭旧连接。name=%s", info.Name)
+		stale.Stop()
+	}
+
+	return d.ses, d.err
+}
+
+// 连接1个后端服务器
+func (this *Forwarder) dial(info *config.TServerInfo) (*session.ServerSession, error) {
+	addr := fmt.Sprintf("%s:%d", info.Host, info.Port)
+	link, err := session.DialServerSession(addr, this, this, this.sesOpt)
+	if nil != err {
+		return nil, err
+	}
+
+	zaplog.Infof("Forwarder 连接后端服务器成功。name=%s，addr=%s", info.Name, addr)
+
+	return link, nil
+}
+
+// 获取客户端 session 绑定的 serverType 类型服务器，未绑定、绑定的服务器已不存在或信息已变化时，通过路由重新选择并绑定
+func (this *Forwarder) bind(serverType string, ses *session.ClientSession, list []*config.TServerInfo) (*config.TServerInfo, error) {
+	this.bindMutex.Lock()
+	defer this.bindMutex.Unlock()
+
+	sesId := ses.GetId()
+	types, ok := this.binds[sesId]
+	if !ok {
+		types = map[string]*config.TServerInfo{}
+		this.binds[sesId] = types
+	}
+
+	// 已绑定：比较全部信息，同名服务器地址变化时重新选择
+	if old, ok := types[serverType]; ok {
+		for _, info := range list {
+			if *info == *old {
+				return old, nil
+			}
+		}
+
+		this.router.Release(serverType, old)
+		delete(types, serverType)
+	}
+
+	// 重新选择
+	rctx := &route.TRouteCtx{
+		Session:   ses,
+		SessionId: sesId,
+	}
+
+	selected := this.router.Select(serverType, list, rctx)
+	if nil == selected {
+		return nil, errors.Errorf("%s 类型的服务器选择器没有选择服务器", serverType)
+	}
+
+	// 保存副本：服务发现原地更新服务器信息时，仍能检测到变化
+	info := *selected
+	types[serverType] = &info
+
+	return &info, nil
+}
+
+// 客户端 session 关闭，解除其绑定的所有服务器
+func (this *Forwarder) unbind(ses session.ISession, reason session.CloseReason) {
+	this.bindMutex.Lock()
+	defer this.bindMutex.Unlock()
+
+	sesId := ses.GetId()
+	for serverType, info := range this.binds[sesId] {
+		this.router.Release(serverType, info)
+	}
+
+	delete(this.binds, sesId)
+
+	// 通知已同步过的后端服务器，移除客户端 session 代理
+	this.syncMutex.Lock()
+	links := this.synced[sesId]
+	delete(this.synced, sesId)
+	this.syncMutex.Unlock()
+
+	cls := protocol.SessionClose{
+		SesId: sesId,
+	}
+
+	for link := range links {
+		this.notify(link, protocol.C_PKT_ID_SESSION_CLOSE, &cls)
+	}
+}
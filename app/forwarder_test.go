@@ -0,0 +1,169 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/config"   // 配置管理
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/sco/route"    // 路由
+	"github.com/zpab123/sco/session"  // 会话
+	"golang.org/x/net/websocket"      // websocket
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用后端服务器
+
+// 后端服务器：记录收到的消息 id
+type testBackend struct {
+	mids chan uint16 // 收到的消息 id
+}
+
+func (this *testBackend) OnNewWsConn(wsconn *websocket.Conn) {
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	ses, err := session.NewServerSession(&network.Socket{Conn: wsconn}, session.NewSessionManager(), this, nil)
+	if nil != err {
+		wsconn.Close()
+
+		return
+	}
+
+	ses.Run()
+}
+
+func (this *testBackend) OnServerMessage(ses *session.ServerSession, packet *network.Packet) {
+	this.mids <- packet.GetMid()
+	packet.Release()
+}
+
+// 在 addr 上启动后端服务器，并建立1个到它的连接
+func newTestLink(t *testing.T, addr string) (*session.ServerSession, chan uint16) {
+	backend := &testBackend{mids: make(chan uint16, 8)}
+	acceptor, err := network.NewWsAcceptor(addr, backend)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if err = acceptor.Run(); nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		acceptor.Stop()
+	})
+
+	time.Sleep(50 * time.Millisecond)
+
+	link, err := session.DialServerSession(addr, session.NewSessionManager(), testServerHandler{}, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		link.Stop()
+	})
+
+	return link, backend.mids
+}
+
+// 不选择任何服务器的选择器
+type testNilSelector struct{}
+
+func (testNilSelector) Select(servers []*config.TServerInfo, ctx *route.TRouteCtx) *config.TServerInfo {
+	return nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 绑定后端服务器：同名服务器地址变化时重新绑定到最新地址，之后不再重新选择；选择器没有选择服务器时返回错误
+func TestForwarderBind(t *testing.T) {
+	fw := NewForwarder("gate", testServerHandler{}, nil)
+	fw.router.SetSelector("game", route.NewHashSelector(nil))
+	fw.router.SetSelector("chat", testNilSelector{})
+
+	ses := newTestClientSession(t)
+	ses.SetId(1)
+
+	game := &config.TServerInfo{Name: "game_1", Host: "127.0.0.1", Port: 7001}
+	moved := &config.TServerInfo{Name: "game_1", Host: "127.0.0.2", Port: 7101}
+
+	tests := []struct {
+		name       string                // 用例名字
+		serverType string                // 服务器类型
+		list       []*config.TServerInfo // 服务器列表
+		want       *config.TServerInfo   // 绑定的服务器，nil=返回错误
+	}{
+		{"first", "game", []*config.TServerInfo{game}, game},
+		{"address changed", "game", []*config.TServerInfo{moved}, moved},
+		{"unchanged", "game", []*config.TServerInfo{moved}, moved},
+		{"nil selector", "chat", []*config.TServerInfo{game}, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := fw.bind(tt.serverType, ses, tt.list)
+		if nil == tt.want {
+			if nil == err {
+				t.Errorf("%s：选择器没有选择服务器时应返回错误", tt.name)
+			}
+
+			continue
+		}
+
+		if nil != err {
+			t.Fatalf("%s：%s", tt.name, err)
+		}
+
+		if *got != *tt.want {
+			t.Errorf("%s：绑定的服务器错误。got=%+v，want=%+v", tt.name, *got, *tt.want)
+		}
+	}
+}
+
+// 同步通知发送成功后才记录版本：发送失败时下次重新同步，成功后版本不变不再同步
+func TestForwarderSync(t *testing.T) {
+	fw := NewForwarder("gate", testServerHandler{}, nil)
+	ses := newTestClientSession(t)
+	ses.SetId(1)
+
+	// 发送失败：未启动的连接
+	down := newTestServerSession(t)
+	fw.sync(down, ses)
+	if _, ok := fw.synced[ses.GetId()][down]; ok {
+		t.Fatal("同步通知发送失败，不应记录已同步的版本")
+	}
+
+	// 发送成功
+	link, mids := newTestLink(t, "127.0.0.1:18642")
+	tests := []struct {
+		name string // 用例名字
+		uid  string // 同步前绑定的 uid，空=不修改
+		send bool   // 是否发送同步通知
+	}{
+		{"first", "", true},
+		{"unchanged", "", false},
+		{"uid changed", "u1", true},
+		{"unchanged again", "", false},
+	}
+
+	for _, tt := range tests {
+		if "" != tt.uid {
+			ses.Bind(tt.uid)
+		}
+
+		fw.sync(link, ses)
+
+		select {
+		case mid := <-mids:
+			if !tt.send {
+				t.Errorf("%s：不应发送同步通知", tt.name)
+			} else if protocol.C_PKT_ID_SESSION_SYNC != mid {
+				t.Errorf("%s：消息 id 错误。got=%d，want=%d", tt.name, mid, protocol.C_PKT_ID_SESSION_SYNC)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if tt.send {
+				t.Errorf("%s：等待同步通知超时", tt.name)
+			}
+		}
+	}
+}
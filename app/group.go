@@ -0,0 +1,83 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 组播：通过 rpc 向其他前端服务器上的组成员推送消息
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/config"   // 配置管理
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/zaplog"       // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// groupRemote 对象
+
+// 组远程推送：通过 rpc 调用前端服务器的 C_ROUTE_GROUP_PUSH
+type groupRemote struct {
+	app *Application // 所属 app
+}
+
+// 向前端服务器上的 session 推送消息 [IGroupRemote 接口]
+func (this *groupRemote) PushRemote(frontend string, sesIds []int64, mid uint16, data []byte) ([]int64, error) {
+	info := this.app.findServer(frontend)
+	if nil == info {
+		// 前端服务器已离开集群，其上的 session 已全部断开
+		zaplog.Debugf("app 组播消息：前端服务器 %s 不存在，移除其上的组成员", frontend)
+
+		return sesIds, nil
+	}
+
+	req := &protocol.GroupPushReq{
+		SesIds: sesIds,
+		Mid:    mid,
+		Data:   data,
+	}
+	res := &protocol.GroupPushRes{}
+
+	if err := this.app.rpcClient.InvokeServer(context.Background(), info, C_ROUTE_GROUP_PUSH, req, res); nil != err {
+		return nil, err
+	}
+
+	return res.Missing, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 处理其他服务器发来的组播消息：推送给本地 session
+func (this *Application) handleGroupPush(ctx context.Context, payload []byte) ([]byte, error) {
+	req := &protocol.GroupPushReq{}
+	if err := json.Unmarshal(payload, req); nil != err {
+		return nil, errors.Wrap(err, "解码组播消息失败")
+	}
+
+	res := &protocol.GroupPushRes{}
+
+	mgr := this.GetSessionManager()
+	if nil == mgr {
+		res.Missing = req.SesIds
+	} else {
+		res.Missing, _ = mgr.Multicast(req.SesIds, req.Mid, req.Data)
+	}
+
+	return json.Marshal(res)
+}
+
+// 根据名字在集群中查找服务器
+//
+// 返回 nil=不存在
+func (this *Application) findServer(name string) *config.TServerInfo {
+	for _, list := range this.GetServerMap() {
+		for _, info := range list {
+			if info.Name == name {
+				return info
+			}
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ package app
 import (
 	"time"
 
+	"github.com/zpab123/sco/config"  // 配置管理
 	"github.com/zpab123/sco/session" // session 管理
 )
 
@@ -13,9 +14,28 @@ import (
 // 常量
 
 const (
-	C_STOP_OUT_TIME        = 30 * time.Second // 关闭app的时候，超过此时间，就会强制关闭
-	C_CLIENT_MSG_CHAN_SIZE = 10000            // 消息 chan 默认长度
-	C_SERVER_MSG_CHAN_SIZE = 10000            // 消息 chan 默认长度
+	C_STOP_OUT_TIME        = 30 * time.Second      // 关闭app的时候，超过此时间，就会强制关闭
+	C_CLIENT_MSG_CHAN_SIZE = 10000                 // 消息 chan 默认长度
+	C_SERVER_MSG_CHAN_SIZE = 10000                 // 消息 chan 默认长度
+	C_WORKER_NUM           = 8                     // worker 分发模式下，默认 worker 数量
+	C_DRAIN_INTERVAL       = 10 * time.Millisecond // 关闭app的时候，检查消息是否处理完成的间隔
+)
+
+// 组件名字
+const (
+	C_CMPT_NAME_FORWARDER = "forwarder" // 消息转发组件
+)
+
+// rpc 路由
+const (
+	C_ROUTE_GROUP_PUSH = "sco.Group.Push" // 向前端服务器上的组成员推送消息
+)
+
+// 消息分发模式
+const (
+	C_DISPATCH_SINGLE uint32 = iota // 单线程逻辑循环：所有消息在1个 goroutine 中顺序处理
+	C_DISPATCH_WORKER               // 多 worker：根据 session id 哈希到 N 个 worker，保证同一 session 消息顺序
+	C_DISPATCH_DIRECT               // 直接分发：在 session 的接收 goroutine 中直接处理
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -27,6 +47,47 @@ type IDelegate interface {
 	OnClentMsg(session.ClientMsg) // 收到1个客户端消息
 }
 
+// 服务器消息代理：IDelegate 实现此接口后，可以收到其他服务器发来的消息
+type IServerMsgDelegate interface {
+	OnServerMsg(msg *session.ServerMsg) // 收到1个服务器消息
+}
+
+// 启动代理：IDelegate 实现此接口后，在 app 启动前后收到通知
+type IStartDelegate interface {
+	BeforeStart(app *Application) error // 组件启动之前调用，返回 error 将中断启动
+	AfterStart(app *Application)        // 所有组件启动完成后调用
+}
+
+// 停止代理：IDelegate 实现此接口后，在 app 停止前后收到通知
+type IStopDelegate interface {
+	BeforeStop(app *Application) // 开始停止之前调用，此时仍可收发消息
+	AfterStop(app *Application)  // 所有组件停止完成后调用
+}
+
+// session 代理：IDelegate 实现此接口后，网络服务中的 session 创建与关闭时收到通知
+type ISessionDelegate interface {
+	OnSessionOpen(ses session.ISession)                              // 收到1个新连接，session 已分配 id
+	OnSessionClose(ses session.ISession, reason session.CloseReason) // session 已关闭
+}
+
+// 集群代理：IDelegate 实现此接口后，服务发现中的服务器加入与离开时收到通知
+type IClusterDelegate interface {
+	OnServerAdded(serverType string, info *config.TServerInfo)   // 1个服务器加入集群
+	OnServerRemoved(serverType string, info *config.TServerInfo) // 1个服务器离开集群
+}
+
+// 前置过滤器：在消息处理之前调用
+type IBeforeFilter interface {
+	Before(msg session.ClientMsg) error // 返回 error 将中断消息处理，并向客户端返回错误消息
+}
+
+// 后置过滤器：在消息处理之后调用
+//
+// 调用时 msg.Packet 仍然有效，但 handler 可能已经读取过消息内容
+type IAfterFilter interface {
+	After(msg session.ClientMsg, err error) // err=前置过滤器返回的错误
+}
+
 // /////////////////////////////////////////////////////////////////////////////
 // TBaseInfo 对象
 
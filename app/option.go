@@ -4,7 +4,12 @@
 package app
 
 import (
+	"time"
+
+	"github.com/zpab123/sco/master"     // master
+	"github.com/zpab123/sco/model"      // 全局模型
 	"github.com/zpab123/sco/netservice" // 网络服务
+	"github.com/zpab123/sco/rpc"        // rpc
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -13,19 +18,32 @@ import (
 // app 配置参数
 type Option struct {
 	NetServiceOpt     *netservice.TNetServiceOpt // 网络服务参数
+	RpcOpt            *rpc.TRpcOpt               // rpc 参数
+	MasterOpt         *master.TMasterOpt         // master 参数
 	ClentMsgChanSize  int                        // 客户端消息通道长度
 	ServerMsgChanSize int                        // 服务器消息长度
+	PanicPolicy       uint32                     // 消息处理出现 panic 时的处理策略（网络服务的 session 使用同1个策略）
+	DispatchMode      uint32                     // 消息分发模式
+	WorkerNum         int                        // worker 分发模式下的 worker 数量
+	StopTimeout       time.Duration              // 优雅关闭超时时间：超过此时间，强制关闭所有连接
 }
 
 // 设置 app 的默认参数
 func setdDfaultOpt(app *Application) {
 	// 网络服务
 	nsOpt := netservice.NewTNetServiceOpt()
+	rpcOpt := rpc.NewTRpcOpt()
 
 	opt := &Option{
 		NetServiceOpt:     nsOpt,
+		RpcOpt:            rpcOpt,
+		MasterOpt:         master.NewTMasterOpt(),
 		ClentMsgChanSize:  C_CLIENT_MSG_CHAN_SIZE,
 		ServerMsgChanSize: C_SERVER_MSG_CHAN_SIZE,
+		PanicPolicy:       model.C_PANIC_DROP,
+		DispatchMode:      C_DISPATCH_SINGLE,
+		WorkerNum:         C_WORKER_NUM,
+		StopTimeout:       C_STOP_OUT_TIME,
 	}
 
 	app.Option = opt
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"net"
+	"testing"
+
+	"github.com/zpab123/sco/config"  // 配置管理
+	"github.com/zpab123/sco/model"   // 全局模型
+	"github.com/zpab123/sco/network" // 网络
+	"github.com/zpab123/sco/session" // 会话
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用 app 代理
+
+// 处理任何消息都以 value 抛出 panic
+type testPanicDelegate struct {
+	value interface{} // panic 值
+}
+
+func (this *testPanicDelegate) Init(a *Application) {}
+
+func (this *testPanicDelegate) OnClentMsg(msg session.ClientMsg) {
+	panic(this.value)
+}
+
+func (this *testPanicDelegate) OnServerMsg(msg *session.ServerMsg) {
+	panic(this.value)
+}
+
+// 丢弃所有服务器消息
+type testServerHandler struct{}
+
+func (testServerHandler) OnServerMessage(ses *session.ServerSession, packet *network.Packet) {}
+
+// 创建1个未启动的 ServerSession
+func newTestServerSession(t *testing.T) *session.ServerSession {
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+
+	ses, err := session.NewServerSession(&network.Socket{Conn: c1}, session.NewSessionManager(), testServerHandler{}, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	return ses.(*session.ServerSession)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// handler 出现 panic 时，按 Option.PanicPolicy 处理：crash 策略以 *session.CrashPanic 重新抛出，且只统计1次
+func TestAppPanicPolicy(t *testing.T) {
+	tests := []struct {
+		name    string // 用例名字
+		server  bool   // 是否为服务器消息
+		policy  uint32 // panic 策略
+		rethrow bool   // 是否重新抛出 panic
+	}{
+		{"client drop", false, model.C_PANIC_DROP, false},
+		{"client close", false, model.C_PANIC_CLOSE, false},
+		{"client crash", false, model.C_PANIC_CRASH, true},
+		{"server drop", true, model.C_PANIC_DROP, false},
+		{"server close", true, model.C_PANIC_CLOSE, false},
+		{"server crash", true, model.C_PANIC_CRASH, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewApplication("gate", &testPanicDelegate{value: "boom"})
+			if nil != err {
+				t.Fatal(err)
+			}
+			setdDfaultOpt(a)
+			a.Option.PanicPolicy = tt.policy
+
+			pkt := network.NewPacket(1000)
+			defer pkt.Release()
+
+			r := func() (r interface{}) {
+				defer func() {
+					r = recover()
+				}()
+
+				if tt.server {
+					a.handleServerMsg(session.NewServerMsg(newTestServerSession(t), pkt))
+				} else {
+					a.handleClientMsg(session.ClientMsg{Session: newTestClientSession(t), Packet: pkt})
+				}
+
+				return nil
+			}()
+
+			if tt.rethrow {
+				if cp, ok := r.(*session.CrashPanic); !ok || "boom" != cp.Value {
+					t.Errorf("crash 策略应以 *session.CrashPanic 重新抛出。got=%#v", r)
+				}
+			} else if nil != r {
+				t.Errorf("panic 未被捕获。got=%v", r)
+			}
+
+			if got := a.panicCount.Load(); 1 != got {
+				t.Errorf("panic 统计次数错误。got=%d，want=1", got)
+			}
+		})
+	}
+}
+
+// 网络服务的 session 使用 app 的 panic 策略
+func TestAppPanicPolicyShared(t *testing.T) {
+	a, err := NewApplication("gate", &testPanicDelegate{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	setdDfaultOpt(a)
+	a.serverInfo = &config.TServerInfo{Name: "gate_t1", Host: "127.0.0.1", Port: 18641}
+	a.groupMgr = session.NewGroupManager("gate_t1")
+	a.Option.PanicPolicy = model.C_PANIC_CRASH
+
+	if err = newNetService(a); nil != err {
+		t.Fatal(err)
+	}
+
+	opt := a.Option.NetServiceOpt
+	if opt.ClientSesOpt.PanicPolicy != model.C_PANIC_CRASH || opt.ServerSesOpt.PanicPolicy != model.C_PANIC_CRASH {
+		t.Errorf("session panic 策略错误。client=%d，server=%d，want=%d", opt.ClientSesOpt.PanicPolicy, opt.ServerSesOpt.PanicPolicy, model.C_PANIC_CRASH)
+	}
+}
@@ -0,0 +1,41 @@
+// /////////////////////////////////////////////////////////////////////////////
+// scolaunch：根据 servers.json 启动整个集群
+//
+// 用法：scolaunch [-dir 可执行文件目录] [-cmd 服务器类型=可执行文件路径,...]
+//
+// 每个服务器以 “可执行文件 -name 服务器名字” 的方式启动；未通过 -cmd 指定的服务器类型，使用 -dir 目录下与服务器类型同名的可执行文件
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/zpab123/sco/launcher" // 集群启动器
+	"github.com/zpab123/zaplog"       // log
+)
+
+func main() {
+	opt := launcher.NewTLauncherOpt()
+
+	dir := flag.String("dir", opt.Dir, "directory of server executables, named by server type")
+	cmds := flag.String("cmd", "", "server type to executable, e.g. gate=./bin/gate,area=./bin/area")
+	flag.Parse()
+
+	opt.Dir = *dir
+	for _, kv := range strings.Split(*cmds, ",") {
+		if idx := strings.Index(kv, "="); idx > 0 {
+			opt.Commands[kv[:idx]] = kv[idx+1:]
+		}
+	}
+
+	// 透传的参数
+	opt.Args = flag.Args()
+
+	if err := launcher.Launch(opt); nil != err {
+		zaplog.Errorf("scolaunch 启动集群失败：%s", err)
+
+		os.Exit(1)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/pkg/errors"       // 异常
 	"github.com/zpab123/sco/path" // 路径库
 	"github.com/zpab123/zaplog"   // log 库
 )
@@ -17,81 +18,211 @@ import (
 
 // 变量
 var (
-	configMutex sync.Mutex                // 进程互斥锁
-	mainPath    string                    // 程序启动目录
-	scoIni      *TScoIni     = &TScoIni{} // sco 引擎配置信息
-	serverJSon  *TServerJson              // server.json 配置表
-	serverMap   TServerMap                // servers.json 中// 服务器 type -> *[]ServerInfo 信息集合
+	configMutex sync.Mutex   // 进程互斥锁
+	mainPath    string       // 程序启动目录
+	scoIni      *TScoIni     // sco 引擎配置信息
+	serverJSon  *TServerJson // server.json 配置表
+	masterJson  *TMasterJson // master.json 配置表
+	scoIniErr   error        // 读取 sco.ini 出现的错误
+	serverErr   error        // 读取 servers.json 出现的错误
 )
 
-// 初始化
-func init() {
-	// 获取mainPath
-	dir, err := path.GetMainPath()
-	if nil == err {
-		mainPath = dir
-	}
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
 
-	// 读取 sco.ini 配置
-	readScoIni()
+// 读取 sco.ini 与 servers.json（已通过 Set 设置的配置不再读取）
+//
+// 返回读取过程中出现的错误；出现错误时，对应配置为空的默认配置
+func Load() error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
 
-	// 读取 servers.json 配置信息
 	readServerJson()
-}
 
-// /////////////////////////////////////////////////////////////////////////////
-// 对外 api
+	if nil != scoIniErr {
+		return scoIniErr
+	}
 
-// 获取 sco.ini 配置对象
+	return serverErr
+}
+
+// 获取 sco.ini 配置对象：首次调用时读取文件
 func GetScoIni() *TScoIni {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	readScoIni()
+
 	return scoIni
 }
 
-// 获取 servers.json 配置信息
+// 获取 servers.json 配置信息：首次调用时读取文件
 func GetServerJson() *TServerJson {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	readServerJson()
+
 	return serverJSon
 }
 
 // 获取 当前环境的 服务器信息集合
 func GetServerMap() TServerMap {
-	return serverMap
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	readServerJson()
+
+	if C_ENV_DEV == scoIni.Env {
+		return serverJSon.Development
+	}
+
+	return serverJSon.Production
 }
 
-// /////////////////////////////////////////////////////////////////////////////
-// 私有 api
+// 设置 sco.ini 配置对象，设置后不再读取文件（需在使用配置前调用）
+func SetScoIni(ini *TScoIni) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
 
-// 读取 servers.json 配置信息
-func readServerJson() {
-	// 锁住线程
+	scoIni = ini
+	scoIniErr = nil
+}
+
+// 设置 servers.json 配置信息，设置后不再读取文件（需在使用配置前调用）
+func SetServerJson(sj *TServerJson) {
 	configMutex.Lock()
+	defer configMutex.Unlock()
 
-	// retun 后，解锁
+	serverJSon = sj
+	serverErr = nil
+}
+
+// 设置 master.json 配置信息，设置后不再读取文件（需在使用配置前调用）
+func SetMasterJson(mj *TMasterJson) {
+	configMutex.Lock()
 	defer configMutex.Unlock()
 
-	// 读取文件
-	if nil == serverJSon {
-		// 获取 main 路径
-		if "" == mainPath {
-			zaplog.Fatal("读取 main 路径失败")
+	masterJson = mj
+}
 
-			os.Exit(1)
-		}
+// 设置程序启动目录：配置文件从该目录下的 config 目录读取（需在使用配置前调用）
+func SetMainPath(dir string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	mainPath = dir
+}
+
+// 获取程序启动目录
+func GetMainPath() string {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	readMainPath()
+
+	return mainPath
+}
+
+// 获取 master.json 配置表
+//
+// 返回 nil=master.json 不存在
+func GetMasterJson() *TMasterJson {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	readMasterJson()
+
+	return masterJson
+}
+
+// 获取当前环境的 master 服务器信息
+//
+// 返回 nil=master.json 不存在，或没有当前环境的配置
+func GetMasterInfo() *TServerInfo {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	readMasterJson()
+	if nil == masterJson {
+		return nil
+	}
+
+	readScoIni()
+	if C_ENV_DEV == scoIni.Env {
+		return masterJson.Development
+	}
+
+	return masterJson.Production
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 获取程序启动目录（需持有 configMutex）
+func readMainPath() {
+	if "" != mainPath {
+		return
+	}
+
+	dir, err := path.GetMainPath()
+	if nil == err {
+		mainPath = dir
+	}
+}
+
+// 读取 servers.json 配置信息（需持有 configMutex）
+func readServerJson() {
+	readScoIni()
 
+	// 读取文件
+	if nil == serverJSon {
 		// 创建对象
 		serverJSon = &TServerJson{
 			Development: TServerMap{},
 			Production:  TServerMap{},
 		}
 
+		// 获取 main 路径
+		readMainPath()
+		if "" == mainPath {
+			serverErr = errors.New("读取 servers.json 失败：读取 main 路径失败")
+			zaplog.Error(serverErr.Error())
+
+			return
+		}
+
 		// 加载文件
 		fPath := filepath.Join(mainPath, C_PATH_SERVER)
-		LoadJsonToMap(fPath, serverJSon)
-
-		// 根据运行环境赋值
-		if C_ENV_DEV == scoIni.Env {
-			serverMap = serverJSon.Development
-		} else {
-			serverMap = serverJSon.Production
+		if err := LoadJsonToMap(fPath, serverJSon); nil != err {
+			serverErr = errors.Wrap(err, "读取 servers.json 失败")
+			zaplog.Error(serverErr.Error())
 		}
 	}
 }
+
+// 读取 master.json 配置信息：文件不存在时，不做处理（需持有 configMutex）
+func readMasterJson() {
+	if nil != masterJson {
+		return
+	}
+
+	readMainPath()
+	if "" == mainPath {
+		return
+	}
+
+	fPath := filepath.Join(mainPath, C_PATH_MASTER)
+	if _, err := os.Stat(fPath); nil != err {
+		return
+	}
+
+	mj := &TMasterJson{}
+	if err := LoadJsonToSruct(fPath, mj); nil != err {
+		zaplog.Errorf("读取 master.json 失败：%s", err)
+
+		return
+	}
+
+	masterJson = mj
+}
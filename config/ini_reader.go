@@ -4,24 +4,25 @@
 package config
 
 import (
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/go-ini/ini"     // ini 库
+	"github.com/pkg/errors"     // 异常
 	"github.com/zpab123/zaplog" // log 工具
 )
 
 // /////////////////////////////////////////////////////////////////////////////
 // 私有 api
 
-// 读取 sco.ini 配置文件
+// 读取 sco.ini 配置文件（需持有 configMutex）
 func readScoIni() {
-	// 锁住线程
-	configMutex.Lock()
+	if nil != scoIni {
+		return
+	}
 
-	// retun 后，解锁
-	defer configMutex.Unlock()
+	readMainPath()
+	scoIni = &TScoIni{}
 
 	// 读取配置文件
 	fPath := filepath.Join(mainPath, C_PATH_SCO_INI)
@@ -29,7 +30,13 @@ func readScoIni() {
 	iniFile, err := ini.Load(fPath)
 
 	// 错误检查
-	checkConfigError(err, "")
+	if nil != err {
+		scoIni.Env = C_ENV_DEV
+		scoIniErr = errors.Wrap(err, "读取 sco.ini 出现错误")
+		zaplog.Error(scoIniErr.Error())
+
+		return
+	}
 
 	// 获取配置
 	for _, sec := range iniFile.Sections() {
@@ -44,19 +51,6 @@ func readScoIni() {
 	}
 }
 
-// 检查 ini 读取错误
-func checkConfigError(err error, msg string) {
-	if err != nil {
-		if msg == "" {
-			msg = err.Error()
-		}
-
-		zaplog.Fatalf("读取 sco.ini 出现错误。 err=%s", msg)
-
-		os.Exit(1)
-	}
-}
-
 // 读取 sco 配置数据
 func readSco(sec *ini.Section, conf *TScoIni) {
 	// 设置默认
@@ -69,7 +63,7 @@ func readSco(sec *ini.Section, conf *TScoIni) {
 			conf.Env = key.MustString(conf.Env)
 			if conf.Env != C_ENV_DEV && conf.Env != C_ENV_PRO {
 				conf.Env = C_ENV_DEV
-				zaplog.Fatal("sco.ini 中 [sco]-env 参数配置错误。修改为 development")
+				zaplog.Warn("sco.ini 中 [sco]-env 参数配置错误。修改为 development")
 			}
 		} else if "log_level" == name {
 			conf.LogLevel = key.MustString(conf.LogLevel)
@@ -55,6 +55,7 @@ type TServerInfo struct {
 	ClientHost string // 面向客户端的 IP地址
 	CTcpPort   uint   // 面向客户端的 tcp端口
 	CWsPort    uint   // 面向客户端的 websocket端口
+	RpcPort    uint   // rpc 服务端口，0=使用 Port（Port 已被面向服务器的网络服务使用时，必须设置）
 }
 
 // 服务器 type -> *[]ServerInfo 信息集合
@@ -65,3 +66,12 @@ type TServerJson struct {
 	Development TServerMap // 开发环境 配置信息
 	Production  TServerMap // 运营环境 配置信息
 }
+
+// /////////////////////////////////////////////////////////////////////////////
+// master.json 配置文件
+
+// master.json 配置表
+type TMasterJson struct {
+	Development *TServerInfo // 开发环境 master 服务器信息
+	Production  *TServerInfo // 运营环境 master 服务器信息
+}
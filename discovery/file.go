@@ -0,0 +1,142 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 基于 servers.json 的服务发现
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"         // 异常
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/zaplog"     // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// FileDiscovery 对象
+
+// 基于 servers.json 的服务发现：定时检查文件修改时间，文件变化后重新加载当前环境的服务器信息
+type FileDiscovery struct {
+	*ServerStore                    // 服务器信息集合
+	cmptName     string             // 组件名字
+	fpath        string             // 文件路径
+	interval     time.Duration      // 检查间隔，<=0 不检查
+	modTime      time.Time          // 最后1次加载时的文件修改时间
+	stopGroup    sync.WaitGroup     // 停止等待组
+	cancel       context.CancelFunc // 停止检查
+}
+
+// 新建1个 FileDiscovery，并立即加载1次文件
+//
+// fpath=servers.json 路径，""=程序启动目录下的 config/servers.json；interval=检查间隔，<=0 不检查
+func NewFileDiscovery(fpath string, interval time.Duration) (*FileDiscovery, error) {
+	if "" == fpath {
+		fpath = filepath.Join(config.GetMainPath(), config.C_PATH_SERVER)
+	}
+
+	fd := &FileDiscovery{
+		ServerStore: NewServerStore(),
+		cmptName:    C_CMPT_NAME,
+		fpath:       fpath,
+		interval:    interval,
+	}
+
+	if err := fd.Reload(); nil != err {
+		return nil, err
+	}
+
+	return fd, nil
+}
+
+// 启动组件：开始检查文件变化 [IComponent 接口]
+func (this *FileDiscovery) Run(ctx context.Context) error {
+	if this.interval <= 0 {
+		return nil
+	}
+
+	ctx, this.cancel = context.WithCancel(ctx)
+
+	this.stopGroup.Add(1)
+	go this.watchLoop(ctx)
+
+	zaplog.Infof("FileDiscovery 组件启动成功。path=%s", this.fpath)
+
+	return nil
+}
+
+// 停止组件 [IComponent 接口]
+func (this *FileDiscovery) Stop() {
+	if nil != this.cancel {
+		this.cancel()
+	}
+
+	this.stopGroup.Wait()
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *FileDiscovery) Name() string {
+	return this.cmptName
+}
+
+// 重新加载文件
+func (this *FileDiscovery) Reload() error {
+	fi, err := os.Stat(this.fpath)
+	if nil != err {
+		return errors.Wrapf(err, "读取 %s 失败", this.fpath)
+	}
+
+	bytes, err := ioutil.ReadFile(this.fpath)
+	if nil != err {
+		return errors.Wrapf(err, "读取 %s 失败", this.fpath)
+	}
+
+	sj := &config.TServerJson{}
+	if err = json.Unmarshal(bytes, sj); nil != err {
+		return errors.Wrapf(err, "解析 %s 失败", this.fpath)
+	}
+
+	// 根据运行环境赋值
+	if config.C_ENV_DEV == config.GetScoIni().Env {
+		this.Set(sj.Development)
+	} else {
+		this.Set(sj.Production)
+	}
+
+	this.modTime = fi.ModTime()
+
+	return nil
+}
+
+// 定时检查文件变化
+func (this *FileDiscovery) watchLoop(ctx context.Context) {
+	defer this.stopGroup.Done()
+
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(this.fpath)
+			if nil != err || fi.ModTime().Equal(this.modTime) {
+				continue
+			}
+
+			// 文件编辑过程中可能暂时无效，保留旧的服务器信息，下次检查时重试
+			if err = this.Reload(); nil != err {
+				zaplog.Warnf("FileDiscovery 重新加载失败：%s", err)
+
+				continue
+			}
+
+			zaplog.Infof("FileDiscovery 重新加载成功。path=%s", this.fpath)
+		}
+	}
+}
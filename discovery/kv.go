@@ -0,0 +1,308 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 内嵌 kv 存储：支持 TTL 租约与前缀监听，用于本地测试集群（无需 etcd 等外部服务）
+
+package discovery
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors" // 异常
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 包初始化
+
+// 变量
+var (
+	defaultStore     *KVStore  // 进程内默认 kv 存储
+	defaultStoreOnce sync.Once // defaultStore 创建锁
+)
+
+// 获取进程内默认的 kv 存储：同一进程中的多个 app 共享，可组成本地测试集群
+func GetKVStore() *KVStore {
+	defaultStoreOnce.Do(func() {
+		defaultStore = NewKVStore()
+	})
+
+	return defaultStore
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// KVStore 对象
+
+// 租约 id，0=无租约
+type LeaseId int64
+
+// 1个租约
+type tLease struct {
+	ttl      time.Duration       // 有效期
+	deadline time.Time           // 过期时间
+	keys     map[string]struct{} // 绑定到该租约的 key
+}
+
+// 1个 kv 记录
+type tEntry struct {
+	value []byte  // 值
+	lease LeaseId // 绑定的租约
+}
+
+// 1个前缀监听
+type tWatcher struct {
+	prefix string    // 监听的 key 前缀
+	fn     WatchFunc // 监听函数
+}
+
+// 内嵌 kv 存储：key 可绑定租约，租约过期或撤销时，绑定的 key 被删除
+type KVStore struct {
+	mutex      sync.Mutex          // 互斥锁
+	data       map[string]*tEntry  // key -> 记录
+	leases     map[LeaseId]*tLease // 租约 id -> 租约
+	leaseIdGen LeaseId             // 租约 id 生成器
+	watchers   map[int64]*tWatcher // 监听 id -> 监听
+	watchIdGen int64               // 监听 id 生成器
+	stopChan   chan struct{}       // 停止过期检查
+	stopOnce   sync.Once           // stopChan 关闭锁
+}
+
+// 新建1个 KVStore，并开始租约过期检查
+func NewKVStore() *KVStore {
+	kv := &KVStore{
+		data:     map[string]*tEntry{},
+		leases:   map[LeaseId]*tLease{},
+		watchers: map[int64]*tWatcher{},
+		stopChan: make(chan struct{}),
+	}
+
+	go kv.expireLoop()
+
+	return kv
+}
+
+// 停止租约过期检查
+func (this *KVStore) Close() {
+	this.stopOnce.Do(func() {
+		close(this.stopChan)
+	})
+}
+
+// 创建1个租约
+func (this *KVStore) Grant(ttl time.Duration) LeaseId {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.leaseIdGen++
+	this.leases[this.leaseIdGen] = &tLease{
+		ttl:      ttl,
+		deadline: time.Now().Add(ttl),
+		keys:     map[string]struct{}{},
+	}
+
+	return this.leaseIdGen
+}
+
+// 续约：过期时间延长为当前时间+ttl
+func (this *KVStore) KeepAlive(id LeaseId) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	l, ok := this.leases[id]
+	if !ok {
+		return errors.Errorf("租约不存在或已过期。leaseId=%d", id)
+	}
+
+	l.deadline = time.Now().Add(l.ttl)
+
+	return nil
+}
+
+// 撤销租约，并删除绑定到该租约的 key
+func (this *KVStore) Revoke(id LeaseId) {
+	this.mutex.Lock()
+	events := this.revoke(id)
+	this.mutex.Unlock()
+
+	this.notify(events)
+}
+
+// 写入1个 key
+//
+// lease=绑定的租约，0=不绑定
+func (this *KVStore) Put(key string, value []byte, lease LeaseId) error {
+	this.mutex.Lock()
+
+	if 0 != lease {
+		l, ok := this.leases[lease]
+		if !ok {
+			this.mutex.Unlock()
+
+			return errors.Errorf("写入 key 失败：租约不存在或已过期。key=%s，leaseId=%d", key, lease)
+		}
+
+		l.keys[key] = struct{}{}
+	}
+
+	if old, ok := this.data[key]; ok && old.lease != lease {
+		if l, ok := this.leases[old.lease]; ok {
+			delete(l.keys, key)
+		}
+	}
+
+	this.data[key] = &tEntry{
+		value: value,
+		lease: lease,
+	}
+
+	this.mutex.Unlock()
+
+	this.notify([]*TKvEvent{{Type: C_KV_PUT, Key: key, Value: value}})
+
+	return nil
+}
+
+// 删除1个 key
+func (this *KVStore) Delete(key string) {
+	this.mutex.Lock()
+	evt := this.delete(key)
+	this.mutex.Unlock()
+
+	if nil != evt {
+		this.notify([]*TKvEvent{evt})
+	}
+}
+
+// 获取1个 key 的值
+func (this *KVStore) Get(key string) ([]byte, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if e, ok := this.data[key]; ok {
+		return e.value, true
+	}
+
+	return nil, false
+}
+
+// 获取所有以 prefix 开头的 key 和值
+func (this *KVStore) List(prefix string) map[string][]byte {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	m := map[string][]byte{}
+	for key, e := range this.data {
+		if strings.HasPrefix(key, prefix) {
+			m[key] = e.value
+		}
+	}
+
+	return m
+}
+
+// 监听所有以 prefix 开头的 key 的变化
+//
+// 返回取消监听的函数
+func (this *KVStore) Watch(prefix string, fn WatchFunc) func() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.watchIdGen++
+	id := this.watchIdGen
+	this.watchers[id] = &tWatcher{
+		prefix: prefix,
+		fn:     fn,
+	}
+
+	return func() {
+		this.mutex.Lock()
+		defer this.mutex.Unlock()
+
+		delete(this.watchers, id)
+	}
+}
+
+// 撤销租约（需持有锁）
+func (this *KVStore) revoke(id LeaseId) []*TKvEvent {
+	l, ok := this.leases[id]
+	if !ok {
+		return nil
+	}
+
+	delete(this.leases, id)
+
+	events := make([]*TKvEvent, 0, len(l.keys))
+	for key := range l.keys {
+		if evt := this.delete(key); nil != evt {
+			events = append(events, evt)
+		}
+	}
+
+	return events
+}
+
+// 删除1个 key（需持有锁）
+func (this *KVStore) delete(key string) *TKvEvent {
+	e, ok := this.data[key]
+	if !ok {
+		return nil
+	}
+
+	delete(this.data, key)
+
+	if l, ok := this.leases[e.lease]; ok {
+		delete(l.keys, key)
+	}
+
+	evt := &TKvEvent{
+		Type: C_KV_DELETE,
+		Key:  key,
+	}
+
+	return evt
+}
+
+// 将事件通知给匹配的监听函数（不可持有锁）
+func (this *KVStore) notify(events []*TKvEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	this.mutex.Lock()
+	watchers := make([]*tWatcher, 0, len(this.watchers))
+	for _, w := range this.watchers {
+		watchers = append(watchers, w)
+	}
+	this.mutex.Unlock()
+
+	for _, evt := range events {
+		for _, w := range watchers {
+			if strings.HasPrefix(evt.Key, w.prefix) {
+				w.fn(evt)
+			}
+		}
+	}
+}
+
+// 定时删除过期的租约
+func (this *KVStore) expireLoop() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.stopChan:
+			return
+		case now := <-ticker.C:
+			this.mutex.Lock()
+			var events []*TKvEvent
+			for id, l := range this.leases {
+				if now.After(l.deadline) {
+					events = append(events, this.revoke(id)...)
+				}
+			}
+			this.mutex.Unlock()
+
+			this.notify(events)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 基于内嵌 kv 存储的服务发现
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"         // 异常
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/zaplog"     // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// KVDiscovery 对象
+
+// 基于内嵌 kv 存储的服务发现：本服务器信息写入 kv 并绑定租约，定时续约；
+// 服务器停止或续约超时后，租约过期，其他服务器收到移除事件
+type KVDiscovery struct {
+	*ServerStore                     // 服务器信息集合
+	cmptName     string              // 组件名字
+	store        *KVStore            // kv 存储
+	serverType   string              // 本服务器类型
+	info         *config.TServerInfo // 本服务器信息，nil=只发现其他服务器，不注册自己
+	ttl          time.Duration       // 租约有效期
+	lease        LeaseId             // 当前租约
+	unwatch      func()              // 取消监听
+	stopGroup    sync.WaitGroup      // 停止等待组
+	cancel       context.CancelFunc  // 停止续约
+}
+
+// 新建1个 KVDiscovery
+//
+// store=kv 存储，nil=使用进程内默认存储；info=本服务器信息，nil=不注册自己；ttl=租约有效期，<=0 使用默认值
+func NewKVDiscovery(store *KVStore, serverType string, info *config.TServerInfo, ttl time.Duration) *KVDiscovery {
+	if nil == store {
+		store = GetKVStore()
+	}
+
+	if ttl <= 0 {
+		ttl = C_LEASE_TTL
+	}
+
+	kd := &KVDiscovery{
+		ServerStore: NewServerStore(),
+		cmptName:    C_CMPT_NAME,
+		store:       store,
+		serverType:  serverType,
+		info:        info,
+		ttl:         ttl,
+	}
+
+	return kd
+}
+
+// 启动组件：注册本服务器，并监听其他服务器变化 [IComponent 接口]
+func (this *KVDiscovery) Run(ctx context.Context) error {
+	// 先监听再加载，避免遗漏加载期间的变化
+	this.unwatch = this.store.Watch(C_KEY_PREFIX, this.onKvEvent)
+
+	sm := config.TServerMap{}
+	for key, value := range this.store.List(C_KEY_PREFIX) {
+		if serverType, info, err := decodeServer(key, value); nil == err {
+			sm[serverType] = append(sm[serverType], info)
+		}
+	}
+	this.Set(sm)
+
+	// 注册本服务器
+	if nil == this.info {
+		return nil
+	}
+
+	if err := this.register(); nil != err {
+		this.unwatch()
+
+		return errors.Wrap(err, "KVDiscovery 注册失败")
+	}
+
+	ctx, this.cancel = context.WithCancel(ctx)
+
+	this.stopGroup.Add(1)
+	go this.keepAliveLoop(ctx)
+
+	zaplog.Infof("KVDiscovery 组件启动成功。type=%s，name=%s", this.serverType, this.info.Name)
+
+	return nil
+}
+
+// 停止组件：撤销租约，其他服务器将收到移除事件 [IComponent 接口]
+func (this *KVDiscovery) Stop() {
+	if nil != this.cancel {
+		this.cancel()
+	}
+
+	this.stopGroup.Wait()
+
+	if 0 != this.lease {
+		this.store.Revoke(this.lease)
+	}
+
+	if nil != this.unwatch {
+		this.unwatch()
+	}
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *KVDiscovery) Name() string {
+	return this.cmptName
+}
+
+// 创建租约，并写入本服务器信息
+func (this *KVDiscovery) register() error {
+	value, err := json.Marshal(this.info)
+	if nil != err {
+		return errors.Wrap(err, "编码服务器信息失败")
+	}
+
+	this.lease = this.store.Grant(this.ttl)
+
+	return this.store.Put(C_KEY_PREFIX+this.serverType+"/"+this.info.Name, value, this.lease)
+}
+
+// 定时续约：租约已过期时，重新注册
+func (this *KVDiscovery) keepAliveLoop(ctx context.Context) {
+	defer this.stopGroup.Done()
+
+	ticker := time.NewTicker(this.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := this.store.KeepAlive(this.lease); nil == err {
+				continue
+			}
+
+			if err := this.register(); nil != err {
+				zaplog.Errorf("KVDiscovery 重新注册失败：%s", err)
+			}
+		}
+	}
+}
+
+// 收到 kv 变化
+func (this *KVDiscovery) onKvEvent(evt *TKvEvent) {
+	switch evt.Type {
+	case C_KV_PUT:
+		serverType, info, err := decodeServer(evt.Key, evt.Value)
+		if nil != err {
+			zaplog.Warnf("KVDiscovery 解析服务器信息失败：%s", err)
+
+			return
+		}
+
+		this.Add(serverType, info)
+	case C_KV_DELETE:
+		serverType, name := splitKey(evt.Key)
+		this.Remove(serverType, name)
+	}
+}
+
+// 解析 key 中的服务器类型和名字
+func splitKey(key string) (string, string) {
+	key = strings.TrimPrefix(key, C_KEY_PREFIX)
+	if idx := strings.LastIndex(key, "/"); idx >= 0 {
+		return key[:idx], key[idx+1:]
+	}
+
+	return "", key
+}
+
+// 解析 kv 中的服务器信息
+func decodeServer(key string, value []byte) (string, *config.TServerInfo, error) {
+	serverType, _ := splitKey(key)
+
+	info := &config.TServerInfo{}
+	if err := json.Unmarshal(value, info); nil != err {
+		return "", nil, errors.Wrapf(err, "key=%s", key)
+	}
+
+	return serverType, info, nil
+}
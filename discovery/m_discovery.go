@@ -0,0 +1,73 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 常量-接口-types
+
+package discovery
+
+import (
+	"time"
+
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/sco/model"  // 全局模型
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 常量
+
+// 组件名字
+const (
+	C_CMPT_NAME = "discovery" // 服务发现组件名字
+)
+
+// 默认参数
+const (
+	C_WATCH_INTERVAL = time.Second      // servers.json 变化检查间隔
+	C_LEASE_TTL      = 10 * time.Second // kv 租约有效期
+	C_KEY_PREFIX     = "/sco/servers/"  // kv 中服务器信息 key 前缀，完整 key=前缀+服务器类型/服务器名字
+)
+
+// 服务器事件类型
+const (
+	C_EVENT_ADD    uint32 = iota + 1 // 服务器加入集群
+	C_EVENT_REMOVE                   // 服务器离开集群
+	C_EVENT_UPDATE                   // 服务器信息变化
+)
+
+// kv 事件类型
+const (
+	C_KV_PUT    uint32 = iota + 1 // 写入
+	C_KV_DELETE                   // 删除（包括租约过期）
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 接口
+
+// 服务发现：提供集群中的服务器信息，并在服务器加入/离开时发出事件
+type IDiscovery interface {
+	model.IComponent                                    // 组件接口
+	GetServerMap() config.TServerMap                    // 获取服务器信息集合：服务器类型 -> 服务器列表，返回值只读
+	GetServers(serverType string) []*config.TServerInfo // 获取某类型的服务器列表，返回值只读
+	AddListener(fn EventFunc)                           // 添加服务器事件监听函数
+}
+
+// 服务器事件监听函数
+type EventFunc func(evt *TEvent)
+
+// kv 事件监听函数
+type WatchFunc func(evt *TKvEvent)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 事件
+
+// 服务器事件
+type TEvent struct {
+	Type       uint32              // 事件类型
+	ServerType string              // 服务器类型
+	Info       *config.TServerInfo // 服务器信息
+}
+
+// kv 事件
+type TKvEvent struct {
+	Type  uint32 // 事件类型
+	Key   string // key
+	Value []byte // 写入的值；删除时为 nil
+}
@@ -0,0 +1,45 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 静态服务发现
+
+package discovery
+
+import (
+	"context"
+
+	"github.com/zpab123/sco/config" // 配置管理
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// StaticDiscovery 对象
+
+// 静态服务发现：服务器信息在创建时给定，之后可通过 Set/Add/Remove 手动修改
+type StaticDiscovery struct {
+	*ServerStore        // 服务器信息集合
+	cmptName     string // 组件名字
+}
+
+// 新建1个 StaticDiscovery
+func NewStaticDiscovery(m config.TServerMap) *StaticDiscovery {
+	sd := &StaticDiscovery{
+		ServerStore: NewServerStore(),
+		cmptName:    C_CMPT_NAME,
+	}
+
+	sd.Set(m)
+
+	return sd
+}
+
+// 启动组件 [IComponent 接口]
+func (this *StaticDiscovery) Run(ctx context.Context) error {
+	return nil
+}
+
+// 停止组件 [IComponent 接口]
+func (this *StaticDiscovery) Stop() {
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *StaticDiscovery) Name() string {
+	return this.cmptName
+}
@@ -0,0 +1,194 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 服务器信息集合
+
+package discovery
+
+import (
+	"sync"
+
+	"github.com/zpab123/sco/config" // 配置管理
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// ServerStore 对象
+
+// 线程安全的服务器信息集合：写入时复制，变化时通知监听函数；供各服务发现实现复用
+type ServerStore struct {
+	mutex     sync.RWMutex      // 读写锁
+	serverMap config.TServerMap // 服务器类型 -> 服务器列表
+	listeners []EventFunc       // 服务器事件监听
+}
+
+// 新建1个 ServerStore
+func NewServerStore() *ServerStore {
+	ss := &ServerStore{
+		serverMap: config.TServerMap{},
+	}
+
+	return ss
+}
+
+// 获取服务器信息集合 [IDiscovery 接口]
+func (this *ServerStore) GetServerMap() config.TServerMap {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.serverMap
+}
+
+// 获取某类型的服务器列表 [IDiscovery 接口]
+func (this *ServerStore) GetServers(serverType string) []*config.TServerInfo {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.serverMap[serverType]
+}
+
+// 添加服务器事件监听函数 [IDiscovery 接口]
+func (this *ServerStore) AddListener(fn EventFunc) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.listeners = append(this.listeners, fn)
+}
+
+// 替换全部服务器信息，并为变化的服务器发出事件
+func (this *ServerStore) Set(m config.TServerMap) {
+	this.mutex.Lock()
+	old := this.serverMap
+	this.serverMap = copyServerMap(m)
+	listeners := this.listeners
+	this.mutex.Unlock()
+
+	var events []*TEvent
+	for serverType, list := range m {
+		for _, info := range list {
+			oi := findServer(old, serverType, info.Name)
+			if nil == oi {
+				events = append(events, newEvent(C_EVENT_ADD, serverType, info))
+			} else if *oi != *info {
+				events = append(events, newEvent(C_EVENT_UPDATE, serverType, info))
+			}
+		}
+	}
+
+	for serverType, list := range old {
+		for _, info := range list {
+			if nil == findServer(m, serverType, info.Name) {
+				events = append(events, newEvent(C_EVENT_REMOVE, serverType, info))
+			}
+		}
+	}
+
+	emit(listeners, events...)
+}
+
+// 添加1个服务器，名字相同的服务器信息将被替换
+func (this *ServerStore) Add(serverType string, info *config.TServerInfo) {
+	this.mutex.Lock()
+
+	evtType := C_EVENT_ADD
+	m := copyServerMap(this.serverMap)
+	list := m[serverType]
+	replaced := false
+
+	for i, old := range list {
+		if old.Name == info.Name {
+			if *old == *info {
+				this.mutex.Unlock()
+
+				return
+			}
+
+			list[i] = info
+			evtType = C_EVENT_UPDATE
+			replaced = true
+
+			break
+		}
+	}
+
+	if !replaced {
+		m[serverType] = append(list, info)
+	}
+
+	this.serverMap = m
+	listeners := this.listeners
+	this.mutex.Unlock()
+
+	emit(listeners, newEvent(evtType, serverType, info))
+}
+
+// 移除1个服务器
+//
+// 返回 false=服务器不存在
+func (this *ServerStore) Remove(serverType string, name string) bool {
+	this.mutex.Lock()
+
+	list := this.serverMap[serverType]
+	for i, info := range list {
+		if info.Name != name {
+			continue
+		}
+
+		m := copyServerMap(this.serverMap)
+		newList := make([]*config.TServerInfo, 0, len(list)-1)
+		newList = append(newList, list[:i]...)
+		m[serverType] = append(newList, list[i+1:]...)
+		this.serverMap = m
+		listeners := this.listeners
+		this.mutex.Unlock()
+
+		emit(listeners, newEvent(C_EVENT_REMOVE, serverType, info))
+
+		return true
+	}
+
+	this.mutex.Unlock()
+
+	return false
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 新建1个服务器事件
+func newEvent(evtType uint32, serverType string, info *config.TServerInfo) *TEvent {
+	evt := &TEvent{
+		Type:       evtType,
+		ServerType: serverType,
+		Info:       info,
+	}
+
+	return evt
+}
+
+// 依次通知监听函数
+func emit(listeners []EventFunc, events ...*TEvent) {
+	for _, evt := range events {
+		for _, fn := range listeners {
+			fn(evt)
+		}
+	}
+}
+
+// 在服务器信息集合中查找服务器
+func findServer(m config.TServerMap, serverType string, name string) *config.TServerInfo {
+	for _, info := range m[serverType] {
+		if info.Name == name {
+			return info
+		}
+	}
+
+	return nil
+}
+
+// 复制服务器信息集合（浅复制，服务器信息对象共享）
+func copyServerMap(src config.TServerMap) config.TServerMap {
+	dst := make(config.TServerMap, len(src)+1)
+	for k, list := range src {
+		dst[k] = append([]*config.TServerInfo(nil), list...)
+	}
+
+	return dst
+}
@@ -0,0 +1,152 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 进程内集群：在1个进程中运行多个 app，app 之间通过内存网络通信，用于开发与测试
+
+package inproc
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"            // 异常
+	"github.com/zpab123/sco/app"       // app
+	"github.com/zpab123/sco/config"    // 配置管理
+	"github.com/zpab123/sco/discovery" // 服务发现
+	"github.com/zpab123/sco/network"   // 网络
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Cluster 对象
+
+// 进程内集群：创建时注入配置并开启内存网络，app 以嵌入模式运行
+type Cluster struct {
+	servers config.TServerMap  // 集群中的服务器信息
+	apps    []*app.Application // 按添加顺序保存的 app
+}
+
+// 新建1个 Cluster
+//
+// ini=sco 引擎配置，nil=开发环境默认配置；servers=集群中的服务器信息，代替 servers.json
+func NewCluster(ini *config.TScoIni, servers config.TServerMap) *Cluster {
+	if nil == ini {
+		ini = &config.TScoIni{
+			Env:      config.C_ENV_DEV,
+			LogLevel: "info",
+		}
+	}
+
+	config.SetScoIni(ini)
+	config.SetServerJson(&config.TServerJson{
+		Development: servers,
+		Production:  servers,
+	})
+
+	network.SetMemNetwork(true)
+
+	c := &Cluster{
+		servers: servers,
+	}
+
+	return c
+}
+
+// 添加1个 app，并完成初始化
+//
+// appType=服务器类型；name=服务器名字，需在 servers 中存在
+func (this *Cluster) Add(appType string, name string, delegate app.IDelegate) (*app.Application, error) {
+	if nil == this.findServer(appType, name) {
+		return nil, errors.Errorf("添加 app 失败：服务器不存在。appType=%s，name=%s", appType, name)
+	}
+
+	a, err := app.NewApplication(appType, delegate)
+	if nil != err {
+		return nil, err
+	}
+
+	a.SetName(name)
+	a.SetEmbedded(true)
+	a.SetDiscovery(discovery.NewStaticDiscovery(this.servers))
+	if err = a.Init(); nil != err {
+		return nil, err
+	}
+
+	this.apps = append(this.apps, a)
+
+	return a, nil
+}
+
+// 按添加顺序启动所有 app；任一 app 启动失败时，停止已启动的 app
+func (this *Cluster) Start() error {
+	for i, a := range this.apps {
+		if err := a.Run(); nil != err {
+			for j := i - 1; j >= 0; j-- {
+				this.apps[j].Stop()
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 按添加顺序的逆序停止所有 app，返回第1个错误
+func (this *Cluster) Stop() error {
+	var first error
+	for i := len(this.apps) - 1; i >= 0; i-- {
+		if err := this.apps[i].Stop(); nil != err && nil == first {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// 以客户端身份连接1个服务器，握手成功后返回
+//
+// name=服务器名字；连接该服务器面向客户端的 websocket 地址
+func (this *Cluster) Dial(name string) (*network.ScoConn, error) {
+	return this.DialResume(name, "", 0)
+}
+
+// 以客户端身份连接1个服务器，使用会话恢复令牌握手，握手成功后返回
+//
+// token=上次握手返回的令牌；recvSeq=上次连接中已收到的消息数量；通过 ScoConn.IsResumed 判断是否恢复成功
+func (this *Cluster) DialResume(name string, token string, recvSeq uint64) (*network.ScoConn, error) {
+	info := this.findServer("", name)
+	if nil == info {
+		return nil, errors.Errorf("连接失败：服务器不存在。name=%s", name)
+	}
+
+	addr := fmt.Sprintf("%s:%d", info.ClientHost, info.CWsPort)
+	socket, err := network.DialWs(addr)
+	if nil != err {
+		return nil, err
+	}
+
+	conn := network.NewScoConn(socket, nil)
+	if err = conn.HandshakeResume(token, recvSeq); nil != err {
+		socket.Close()
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// 查找服务器信息
+//
+// appType=""=查找所有类型
+func (this *Cluster) findServer(appType string, name string) *config.TServerInfo {
+	for serverType, list := range this.servers {
+		if "" != appType && serverType != appType {
+			continue
+		}
+
+		for _, info := range list {
+			if info.Name == name {
+				return info
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package inproc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/app"     // app
+	"github.com/zpab123/sco/config"  // 配置管理
+	"github.com/zpab123/sco/network" // 网络
+	"github.com/zpab123/sco/session" // 会话
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用 app 代理
+
+// 前端服务器：1000-1999 的消息转发给 game 服务器
+type testGate struct{}
+
+func (testGate) Init(a *app.Application) {
+	a.SetRoute("game", 1000, 1999)
+}
+
+func (testGate) OnClentMsg(msg session.ClientMsg) {}
+
+// 后端服务器：以 mid+1 回复前端服务器转发的消息
+type testGame struct{}
+
+func (testGame) Init(a *app.Application) {
+	a.Option.NetServiceOpt.ForClient = false
+}
+
+func (testGame) OnClentMsg(msg session.ClientMsg) {}
+
+func (testGame) OnServerMsg(msg *session.ServerMsg) {
+	pkt := network.NewPacket(msg.GetPacket().GetMid() + 1)
+	pkt.AppendBytes(msg.GetPacket().GetBody())
+	msg.Reply(pkt)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 在内存网络上启动 gate + game，客户端握手后发送1条需要转发的消息，收到后端服务器回复后停止集群
+func TestClusterForward(t *testing.T) {
+	servers := config.TServerMap{
+		"gate": {{Name: "gate_t1", Host: "127.0.0.1", Port: 6001, ClientHost: "127.0.0.1", CWsPort: 6002}},
+		"game": {{Name: "game_t1", Host: "127.0.0.1", Port: 6003}},
+	}
+
+	c := NewCluster(nil, servers)
+	if _, err := c.Add("game", "game_t1", testGame{}); nil != err {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Add("gate", "gate_t1", testGate{}); nil != err {
+		t.Fatal(err)
+	}
+
+	if err := c.Start(); nil != err {
+		t.Fatal(err)
+	}
+
+	// 客户端握手
+	conn, err := c.Dial("gate_t1")
+	if nil != err {
+		c.Stop()
+		t.Fatal(err)
+	}
+
+	// 接收回复：忽略心跳等内部消息
+	reply := make(chan string, 1)
+	go func() {
+		for {
+			pkt, err := conn.RecvPacket()
+			if nil != err {
+				return
+			}
+
+			if nil != pkt && pkt.GetMid() == 1001 {
+				reply <- pkt.ReadString()
+
+				return
+			}
+		}
+	}()
+
+	// 转发1条消息
+	pkt := network.NewPacket(1000)
+	pkt.AppendString("ping")
+	conn.SendPacket(pkt)
+	if err = conn.Flush(); nil != err {
+		c.Stop()
+		t.Fatal(err)
+	}
+
+	select {
+	case body := <-reply:
+		if body != "ping" {
+			t.Errorf("回复内容错误。got=%q，want=%q", body, "ping")
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("等待后端服务器回复超时")
+	}
+
+	conn.Close()
+
+	if err = c.Stop(); nil != err {
+		t.Fatal(err)
+	}
+}
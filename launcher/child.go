@@ -0,0 +1,186 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 服务器子进程
+
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/zaplog"     // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// child 对象
+
+// 1个服务器子进程
+type child struct {
+	launcher   *Launcher           // 所属启动器
+	serverType string              // 服务器类型
+	info       *config.TServerInfo // 服务器信息
+}
+
+// 新建1个 child
+func newChild(l *Launcher, serverType string, info *config.TServerInfo) *child {
+	c := &child{
+		launcher:   l,
+		serverType: serverType,
+		info:       info,
+	}
+
+	return c
+}
+
+// 运行子进程，崩溃（退出码非0或被信号结束）后按退避时间重启，正常退出后不再重启；ctx 结束后停止子进程
+func (this *child) supervise(ctx context.Context) {
+	defer this.launcher.stopGroup.Done()
+
+	opt := this.launcher.option
+	backoff := opt.MinBackoff
+
+	for {
+		start := time.Now()
+		err := this.run(ctx)
+
+		if nil != ctx.Err() {
+			return
+		}
+
+		// 正常退出：退出码为0
+		if nil == err {
+			zaplog.Infof("Launcher 服务器 %s 正常退出，不再重启", this.info.Name)
+
+			return
+		}
+
+		// 运行足够久，认为是偶发崩溃，重置等待时间
+		if time.Since(start) >= opt.StableTime {
+			backoff = opt.MinBackoff
+		}
+
+		zaplog.Warnf("Launcher 服务器 %s 退出，%s 后重启。err=%v", this.info.Name, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > opt.MaxBackoff {
+			backoff = opt.MaxBackoff
+		}
+	}
+}
+
+// 启动1次子进程，并等待其退出；ctx 结束时，先发送 SIGINT，超时后强制结束
+func (this *child) run(ctx context.Context) error {
+	name, err := this.launcher.getCommand(this.serverType)
+	if nil != err {
+		return err
+	}
+
+	args := append([]string{"-name", this.info.Name}, this.launcher.option.Args...)
+	cmd := exec.Command(name, args...)
+
+	out := newPrefixWriter(this.launcher, this.info.Name)
+	defer out.Flush()
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err = cmd.Start(); nil != err {
+		return err
+	}
+
+	zaplog.Infof("Launcher 启动服务器 %s。pid=%d", this.info.Name, cmd.Process.Pid)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	// 优雅停止
+	cmd.Process.Signal(os.Interrupt)
+
+	select {
+	case err = <-done:
+	case <-time.After(this.launcher.option.StopTimeout):
+		zaplog.Warnf("Launcher 服务器 %s 停止超时，强制结束", this.info.Name)
+
+		cmd.Process.Kill()
+		err = <-done
+	}
+
+	zaplog.Infof("Launcher 服务器 %s 已停止", this.info.Name)
+
+	return err
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// prefixWriter 对象
+
+// 按行输出，每行前加 [服务器名字] 前缀
+type prefixWriter struct {
+	launcher *Launcher    // 所属启动器，提供输出与输出锁
+	prefix   []byte       // 前缀
+	buf      bytes.Buffer // 未满1行的数据
+}
+
+// 新建1个 prefixWriter
+func newPrefixWriter(l *Launcher, name string) *prefixWriter {
+	pw := &prefixWriter{
+		launcher: l,
+		prefix:   []byte(fmt.Sprintf("[%s] ", name)),
+	}
+
+	return pw
+}
+
+// 写入数据，输出其中完整的行 [io.Writer 接口]
+func (this *prefixWriter) Write(p []byte) (int, error) {
+	this.buf.Write(p)
+
+	for {
+		idx := bytes.IndexByte(this.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+
+		this.output(this.buf.Next(idx + 1))
+	}
+
+	return len(p), nil
+}
+
+// 输出剩余的不完整行
+func (this *prefixWriter) Flush() {
+	if this.buf.Len() > 0 {
+		this.output(append(this.buf.Bytes(), '\n'))
+		this.buf.Reset()
+	}
+}
+
+// 输出1行
+func (this *prefixWriter) output(line []byte) {
+	l := this.launcher
+	if nil == l.option.Output {
+		return
+	}
+
+	l.outMutex.Lock()
+	defer l.outMutex.Unlock()
+
+	l.option.Output.Write(this.prefix)
+	l.option.Output.Write(line)
+}
@@ -0,0 +1,168 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 集群启动器：根据 servers.json 启动所有服务器进程
+
+package launcher
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"         // 异常
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/zaplog"     // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 启动当前环境 servers.json（以及 master.json）中的所有服务器，收到 SIGINT/SIGTERM 后停止所有服务器
+//
+// 阻塞直到所有子进程退出
+func Launch(opt *TLauncherOpt) error {
+	l := NewLauncher(opt)
+
+	// master：由 Run 先启动
+	if info := config.GetMasterInfo(); nil != info {
+		l.Add(config.C_SERVER_TYPE_MASTER, info)
+	}
+
+	if err := l.AddServerMap(config.GetServerMap()); nil != err {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	go func() {
+		select {
+		case sig := <-sigChan:
+			zaplog.Infof("Launcher 收到信号 %s，停止所有服务器", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return l.Run(ctx)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Launcher 对象
+
+// 集群启动器：每个服务器1个子进程，子进程崩溃后按退避时间重启
+type Launcher struct {
+	option    *TLauncherOpt  // 配置参数
+	children  []*child       // 子进程
+	outMutex  sync.Mutex     // 日志输出互斥锁
+	stopGroup sync.WaitGroup // 停止等待组
+}
+
+// 新建1个 Launcher
+func NewLauncher(opt *TLauncherOpt) *Launcher {
+	if nil == opt {
+		opt = NewTLauncherOpt()
+	}
+
+	l := &Launcher{
+		option: opt,
+	}
+
+	return l
+}
+
+// 添加1个服务器
+func (this *Launcher) Add(serverType string, info *config.TServerInfo) {
+	c := newChild(this, serverType, info)
+	this.children = append(this.children, c)
+}
+
+// 添加服务器信息集合中的所有服务器（按服务器类型排序）
+func (this *Launcher) AddServerMap(m config.TServerMap) error {
+	types := make([]string, 0, len(m))
+	for serverType := range m {
+		types = append(types, serverType)
+	}
+	sort.Strings(types)
+
+	for _, serverType := range types {
+		for _, info := range m[serverType] {
+			if "" == info.Name {
+				return errors.Errorf("服务器类型 %s 中存在没有名字的服务器", serverType)
+			}
+
+			this.Add(serverType, info)
+		}
+	}
+
+	return nil
+}
+
+// 启动所有服务器，ctx 结束后停止所有服务器
+//
+// 先启动 master，等待 MasterWait 后再启动其他服务器；阻塞直到所有子进程退出
+func (this *Launcher) Run(ctx context.Context) error {
+	if len(this.children) == 0 {
+		return errors.New("启动集群失败：没有服务器")
+	}
+
+	for _, c := range this.children {
+		if _, err := this.getCommand(c.serverType); nil != err {
+			return err
+		}
+	}
+
+	// master 先启动：其他服务器启动时需要连接 master
+	masters := 0
+	for _, c := range this.children {
+		if config.C_SERVER_TYPE_MASTER == c.serverType {
+			this.stopGroup.Add(1)
+			go c.supervise(ctx)
+			masters++
+		}
+	}
+
+	if masters > 0 && this.option.MasterWait > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(this.option.MasterWait):
+		}
+	}
+
+	for _, c := range this.children {
+		if config.C_SERVER_TYPE_MASTER != c.serverType {
+			this.stopGroup.Add(1)
+			go c.supervise(ctx)
+		}
+	}
+
+	zaplog.Infof("Launcher 启动集群成功。服务器数量=%d", len(this.children))
+
+	this.stopGroup.Wait()
+
+	zaplog.Infof("Launcher 所有服务器已停止")
+
+	return nil
+}
+
+// 获取服务器类型对应的可执行文件路径
+func (this *Launcher) getCommand(serverType string) (string, error) {
+	if cmd, ok := this.option.Commands[serverType]; ok {
+		return cmd, nil
+	}
+
+	cmd := filepath.Join(this.option.Dir, serverType)
+	if _, err := os.Stat(cmd); nil != err {
+		return "", errors.Wrapf(err, "找不到服务器类型 %s 的可执行文件", serverType)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,54 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 常量-接口-types
+
+package launcher
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 常量
+
+// 默认参数
+const (
+	C_MIN_BACKOFF  = time.Second      // 子进程崩溃后，首次重启等待时间
+	C_MAX_BACKOFF  = 30 * time.Second // 子进程崩溃后，最大重启等待时间
+	C_STABLE_TIME  = 10 * time.Second // 子进程运行超过该时间后崩溃，重启等待时间重置为 C_MIN_BACKOFF
+	C_STOP_TIMEOUT = 10 * time.Second // 停止时等待子进程退出的时间，超时后强制结束
+	C_MASTER_WAIT  = time.Second      // 先启动 master，等待该时间后，再启动其他服务器
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// TLauncherOpt 对象
+
+// 启动器配置参数
+type TLauncherOpt struct {
+	Dir         string            // 可执行文件目录，服务器类型为 gate 时，执行 Dir/gate
+	Commands    map[string]string // 服务器类型 -> 可执行文件路径，优先于 Dir
+	Args        []string          // 附加的启动参数，放在 -name 参数之后
+	Output      io.Writer         // 子进程日志输出，每行前加 [服务器名字] 前缀
+	MinBackoff  time.Duration     // 首次重启等待时间
+	MaxBackoff  time.Duration     // 最大重启等待时间
+	StableTime  time.Duration     // 运行超过该时间后崩溃，重启等待时间重置
+	StopTimeout time.Duration     // 停止时等待子进程退出的时间
+	MasterWait  time.Duration     // 启动 master 后，等待该时间再启动其他服务器。0=同时启动
+}
+
+// 新建1个 TLauncherOpt
+func NewTLauncherOpt() *TLauncherOpt {
+	opt := &TLauncherOpt{
+		Dir:         ".",
+		Commands:    map[string]string{},
+		Output:      os.Stdout,
+		MinBackoff:  C_MIN_BACKOFF,
+		MaxBackoff:  C_MAX_BACKOFF,
+		StableTime:  C_STABLE_TIME,
+		StopTimeout: C_STOP_TIMEOUT,
+		MasterWait:  C_MASTER_WAIT,
+	}
+
+	return opt
+}
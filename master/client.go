@@ -0,0 +1,218 @@
+// /////////////////////////////////////////////////////////////////////////////
+// master 客户端组件：向 master 注册，并维护本地的集群成员信息
+
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"            // 异常
+	"github.com/zpab123/sco/config"    // 配置管理
+	"github.com/zpab123/sco/discovery" // 服务发现
+	"github.com/zpab123/sco/rpc"       // rpc
+	"github.com/zpab123/zaplog"        // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Client 对象
+
+// master 客户端组件：启动后向 master 注册并定时发送心跳；收到集群事件后更新服务器信息集合 [IDiscovery 接口]
+type Client struct {
+	*discovery.ServerStore                     // 集群中的服务器信息
+	cmptName               string              // 组件名字
+	self                   *TMember            // 本服务器
+	masterInfo             *config.TServerInfo // master 服务器信息
+	option                 *TMasterOpt         // 配置参数
+	rpcClient              *rpc.RpcClient      // rpc 客户端
+	mutex                  sync.Mutex          // version registered 互斥锁
+	version                uint64              // 本地集群版本号
+	registered             bool                // 是否已注册成功
+	stopGroup              sync.WaitGroup      // 停止等待组
+	cancel                 context.CancelFunc  // 停止心跳
+}
+
+// 新建1个 Client，并将集群事件通知方法注册到 rpcServer
+//
+// serverType=本服务器类型；info=本服务器信息；masterInfo=master 服务器信息
+func NewClient(serverType string, info *config.TServerInfo, masterInfo *config.TServerInfo, rpcServer *rpc.RpcServer, rpcClient *rpc.RpcClient, opt *TMasterOpt) (*Client, error) {
+	if nil == info || nil == masterInfo {
+		return nil, errors.New("创建 master Client 失败：参数 info 或 masterInfo 为 nil")
+	}
+
+	if nil == rpcServer || nil == rpcClient {
+		return nil, errors.New("创建 master Client 失败：参数 rpcServer 或 rpcClient 为 nil")
+	}
+
+	if nil == opt {
+		opt = NewTMasterOpt()
+	}
+
+	c := &Client{
+		ServerStore: discovery.NewServerStore(),
+		cmptName:    C_CMPT_NAME_CLIENT,
+		self: &TMember{
+			ServerType: serverType,
+			Info:       info,
+		},
+		masterInfo: masterInfo,
+		option:     opt,
+		rpcClient:  rpcClient,
+	}
+
+	rpcServer.Handle(C_ROUTE_NOTIFY, c.onNotify)
+
+	return c, nil
+}
+
+// 启动组件：注册并开始心跳 [IComponent 接口]
+func (this *Client) Run(ctx context.Context) error {
+	ctx, this.cancel = context.WithCancel(ctx)
+
+	this.stopGroup.Add(1)
+	go this.heartbeatLoop(ctx)
+
+	zaplog.Infof("master Client 组件启动成功。master=%s", rpc.GetRpcAddr(this.masterInfo))
+
+	return nil
+}
+
+// 停止组件：停止心跳并从 master 注销 [IComponent 接口]
+func (this *Client) Stop() {
+	if nil != this.cancel {
+		this.cancel()
+	}
+
+	this.stopGroup.Wait()
+
+	req := &TUnregisterReq{
+		Name: this.self.Info.Name,
+	}
+
+	if err := this.rpcClient.InvokeServer(context.Background(), this.masterInfo, C_ROUTE_UNREGISTER, req, nil); nil != err {
+		zaplog.Warnf("master Client 注销失败：%s", err)
+	}
+
+	zaplog.Infof("master Client 组件停止成功")
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *Client) Name() string {
+	return this.cmptName
+}
+
+// 心跳循环：未注册时注册，已注册时发送心跳
+func (this *Client) heartbeatLoop(ctx context.Context) {
+	defer this.stopGroup.Done()
+
+	ticker := time.NewTicker(this.option.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		if err := this.keepalive(ctx); nil != err && nil == ctx.Err() {
+			zaplog.Warnf("master Client 心跳失败：%s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 注册或发送1次心跳：master 中没有本服务器或集群版本号不一致时，重新注册以同步集群成员
+func (this *Client) keepalive(ctx context.Context) error {
+	this.mutex.Lock()
+	registered := this.registered
+	version := this.version
+	this.mutex.Unlock()
+
+	if registered {
+		req := &THeartbeatReq{
+			Name: this.self.Info.Name,
+		}
+		res := &THeartbeatRes{}
+
+		if err := this.rpcClient.InvokeServer(ctx, this.masterInfo, C_ROUTE_HEARTBEAT, req, res); nil != err {
+			return err
+		}
+
+		if res.Registered && res.Version == version {
+			return nil
+		}
+	}
+
+	return this.register(ctx)
+}
+
+// 向 master 注册，并用 master 返回的集群成员替换本地成员信息
+func (this *Client) register(ctx context.Context) error {
+	req := &TRegisterReq{
+		Member: this.self,
+	}
+	res := &TRegisterRes{}
+
+	if err := this.rpcClient.InvokeServer(ctx, this.masterInfo, C_ROUTE_REGISTER, req, res); nil != err {
+		return err
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	// 通知期间版本号已经更新
+	if this.registered && res.Version < this.version {
+		return nil
+	}
+
+	sm := config.TServerMap{}
+	for _, m := range res.Members {
+		sm[m.ServerType] = append(sm[m.ServerType], m.Info)
+	}
+
+	// 替换服务器信息，并为变化的服务器触发事件
+	this.Set(sm)
+	this.version = res.Version
+
+	if !this.registered {
+		zaplog.Infof("master Client 注册成功。集群版本=%d，服务器数量=%d", res.Version, len(res.Members))
+	}
+	this.registered = true
+
+	return nil
+}
+
+// 收到 master 的集群事件通知
+func (this *Client) onNotify(ctx context.Context, payload []byte) ([]byte, error) {
+	evt := &TEvent{}
+	if err := json.Unmarshal(payload, evt); nil != err {
+		return nil, errors.Wrap(err, "解码集群事件失败")
+	}
+
+	if nil == evt.Member || nil == evt.Member.Info {
+		return nil, errors.New("集群事件中服务器信息为空")
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	// 过期事件：下次心跳时通过重新注册同步
+	if evt.Version <= this.version {
+		return nil, nil
+	}
+
+	if evt.Version == this.version+1 {
+		this.version = evt.Version
+	}
+
+	switch evt.Type {
+	case C_EVENT_ADD:
+		this.Add(evt.Member.ServerType, evt.Member.Info)
+	case C_EVENT_REMOVE:
+		this.Remove(evt.Member.ServerType, evt.Member.Info.Name)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,110 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 常量-接口-types
+
+package master
+
+import (
+	"time"
+
+	"github.com/zpab123/sco/config" // 配置管理
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 常量
+
+// 组件名字
+const (
+	C_CMPT_NAME        = "master"       // master 组件名字
+	C_CMPT_NAME_CLIENT = "masterclient" // master 客户端组件名字
+)
+
+// 默认参数
+const (
+	C_HEARTBEAT = 3 * time.Second  // 心跳间隔
+	C_TIMEOUT   = 10 * time.Second // 心跳超时时间：超过该时间未收到心跳，认为服务器已离线
+)
+
+// 集群事件类型
+const (
+	C_EVENT_ADD    uint32 = iota + 1 // 服务器加入集群
+	C_EVENT_REMOVE                   // 服务器离开集群
+)
+
+// rpc 路由
+const (
+	C_ROUTE_REGISTER   = "master.Remote.Register"   // 注册
+	C_ROUTE_HEARTBEAT  = "master.Remote.Heartbeat"  // 心跳
+	C_ROUTE_UNREGISTER = "master.Remote.Unregister" // 注销
+	C_ROUTE_NOTIFY     = "sco.Member.Notify"        // master -> 服务器 集群事件通知
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// TMasterOpt 对象
+
+// master 配置参数
+type TMasterOpt struct {
+	Enable    bool          // 是否启用 master：启用后，服务器启动时向 master 注册，集群成员由 master 动态维护
+	Heartbeat time.Duration // 心跳间隔
+	Timeout   time.Duration // 心跳超时时间
+}
+
+// 新建1个 TMasterOpt
+func NewTMasterOpt() *TMasterOpt {
+	opt := &TMasterOpt{
+		Enable:    false,
+		Heartbeat: C_HEARTBEAT,
+		Timeout:   C_TIMEOUT,
+	}
+
+	return opt
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 集群成员
+
+// 集群中的1个服务器
+type TMember struct {
+	ServerType string              // 服务器类型
+	Info       *config.TServerInfo // 服务器信息
+}
+
+// 集群事件：master -> 服务器 通知消息
+type TEvent struct {
+	Type    uint32   // 事件类型
+	Version uint64   // 事件发生后的集群版本号
+	Member  *TMember // 加入/离开的服务器
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// rpc 消息
+
+// 注册请求
+type TRegisterReq struct {
+	Member *TMember // 本服务器
+}
+
+// 注册回复
+type TRegisterRes struct {
+	Version uint64     // 集群版本号
+	Members []*TMember // 集群中所有服务器
+}
+
+// 心跳请求
+type THeartbeatReq struct {
+	Name string // 服务器名字
+}
+
+// 心跳回复
+type THeartbeatRes struct {
+	Registered bool   // false=master 中没有该服务器（例如 master 重启或心跳超时），需重新注册
+	Version    uint64 // 集群版本号
+}
+
+// 注销请求
+type TUnregisterReq struct {
+	Name string // 服务器名字
+}
+
+// 注销回复
+type TUnregisterRes struct {
+}
@@ -0,0 +1,270 @@
+// /////////////////////////////////////////////////////////////////////////////
+// master 组件：维护集群成员
+
+package master
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"         // 异常
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/sco/rpc"    // rpc
+	"github.com/zpab123/zaplog"     // log
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Master 对象
+
+// 集群中的1个成员，以及最后1次心跳时间
+type tMember struct {
+	member   *TMember  // 成员信息
+	lastTime time.Time // 最后1次心跳时间
+}
+
+// master 组件：接收服务器注册与心跳，心跳超时的服务器被移出集群，成员变化时通知集群中所有服务器
+type Master struct {
+	cmptName  string              // 组件名字
+	option    *TMasterOpt         // 配置参数
+	rpcClient *rpc.RpcClient      // rpc 客户端，用于通知集群事件
+	mutex     sync.Mutex          // members 互斥锁
+	members   map[string]*tMember // 服务器名字 -> 成员
+	version   uint64              // 集群版本号：成员每变化1次，版本号+1
+	stopGroup sync.WaitGroup      // 停止等待组
+	cancel    context.CancelFunc  // 停止心跳检查
+}
+
+// 新建1个 Master，并将远程方法注册到 rpcServer
+func NewMaster(rpcServer *rpc.RpcServer, rpcClient *rpc.RpcClient, opt *TMasterOpt) (*Master, error) {
+	if nil == rpcServer || nil == rpcClient {
+		return nil, errors.New("创建 Master 失败：参数 rpcServer 或 rpcClient 为 nil")
+	}
+
+	if nil == opt {
+		opt = NewTMasterOpt()
+	}
+
+	m := &Master{
+		cmptName:  C_CMPT_NAME,
+		option:    opt,
+		rpcClient: rpcClient,
+		members:   map[string]*tMember{},
+	}
+
+	if _, err := rpcServer.Register(config.C_SERVER_TYPE_MASTER, &Remote{master: m}); nil != err {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// 启动组件：开始心跳超时检查 [IComponent 接口]
+func (this *Master) Run(ctx context.Context) error {
+	ctx, this.cancel = context.WithCancel(ctx)
+
+	this.stopGroup.Add(1)
+	go this.checkLoop(ctx)
+
+	zaplog.Infof("Master 组件启动成功")
+
+	return nil
+}
+
+// 停止组件 [IComponent 接口]
+func (this *Master) Stop() {
+	if nil != this.cancel {
+		this.cancel()
+	}
+
+	this.stopGroup.Wait()
+
+	zaplog.Infof("Master 组件停止成功")
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *Master) Name() string {
+	return this.cmptName
+}
+
+// 获取集群中所有服务器，以及集群版本号
+func (this *Master) GetMembers() ([]*TMember, uint64) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	list := make([]*TMember, 0, len(this.members))
+	for _, m := range this.members {
+		list = append(list, m.member)
+	}
+
+	return list, this.version
+}
+
+// 注册1个服务器：名字已存在时，更新服务器信息
+func (this *Master) register(member *TMember) *TRegisterRes {
+	this.mutex.Lock()
+
+	old, ok := this.members[member.Info.Name]
+	changed := !ok || old.member.ServerType != member.ServerType || *old.member.Info != *member.Info
+
+	this.members[member.Info.Name] = &tMember{
+		member:   member,
+		lastTime: time.Now(),
+	}
+
+	var evt *TEvent
+	if changed {
+		this.version++
+		evt = &TEvent{
+			Type:    C_EVENT_ADD,
+			Version: this.version,
+			Member:  member,
+		}
+	}
+
+	this.mutex.Unlock()
+
+	if nil != evt {
+		zaplog.Infof("Master 服务器加入集群。type=%s，name=%s", member.ServerType, member.Info.Name)
+
+		this.broadcast(evt)
+	}
+
+	members, version := this.GetMembers()
+	res := &TRegisterRes{
+		Version: version,
+		Members: members,
+	}
+
+	return res
+}
+
+// 更新服务器心跳时间
+func (this *Master) heartbeat(name string) *THeartbeatRes {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	res := &THeartbeatRes{
+		Version: this.version,
+	}
+
+	if m, ok := this.members[name]; ok {
+		m.lastTime = time.Now()
+		res.Registered = true
+	}
+
+	return res
+}
+
+// 移除1个服务器
+func (this *Master) remove(name string) {
+	this.mutex.Lock()
+
+	m, ok := this.members[name]
+	if !ok {
+		this.mutex.Unlock()
+
+		return
+	}
+
+	delete(this.members, name)
+	this.version++
+	evt := &TEvent{
+		Type:    C_EVENT_REMOVE,
+		Version: this.version,
+		Member:  m.member,
+	}
+
+	this.mutex.Unlock()
+
+	zaplog.Infof("Master 服务器离开集群。type=%s，name=%s", m.member.ServerType, name)
+
+	this.broadcast(evt)
+}
+
+// 心跳超时检查
+func (this *Master) checkLoop(ctx context.Context) {
+	defer this.stopGroup.Done()
+
+	ticker := time.NewTicker(this.option.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, name := range this.expired(now) {
+				this.remove(name)
+			}
+		}
+	}
+}
+
+// 获取心跳超时的服务器名字
+func (this *Master) expired(now time.Time) []string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	var names []string
+	for name, m := range this.members {
+		if now.Sub(m.lastTime) > this.option.Timeout {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// 将集群事件通知给集群中所有服务器（事件中的服务器除外）
+func (this *Master) broadcast(evt *TEvent) {
+	payload, err := json.Marshal(evt)
+	if nil != err {
+		zaplog.Errorf("Master 编码集群事件失败：%s", err)
+
+		return
+	}
+
+	members, _ := this.GetMembers()
+	for _, m := range members {
+		if m.Info.Name == evt.Member.Info.Name {
+			continue
+		}
+
+		go func(info *config.TServerInfo) {
+			if _, err := this.rpcClient.CallServer(context.Background(), info, C_ROUTE_NOTIFY, payload); nil != err {
+				zaplog.Warnf("Master 通知集群事件失败。name=%s，err=%s", info.Name, err)
+			}
+		}(m.Info)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Remote 对象
+
+// master 远程服务：供集群中的服务器通过 rpc 调用
+type Remote struct {
+	master *Master // master 组件
+}
+
+// 注册
+func (this *Remote) Register(ctx context.Context, req *TRegisterReq) (*TRegisterRes, error) {
+	if nil == req.Member || nil == req.Member.Info || "" == req.Member.Info.Name {
+		return nil, errors.New("注册失败：服务器信息为空")
+	}
+
+	return this.master.register(req.Member), nil
+}
+
+// 心跳
+func (this *Remote) Heartbeat(ctx context.Context, req *THeartbeatReq) (*THeartbeatRes, error) {
+	return this.master.heartbeat(req.Name), nil
+}
+
+// 注销
+func (this *Remote) Unregister(ctx context.Context, req *TUnregisterReq) (*TUnregisterRes, error) {
+	this.master.remove(req.Name)
+
+	return &TUnregisterRes{}, nil
+}
@@ -9,3 +9,10 @@ const (
 	C_TCP_BUFFER_WRITE_SIZE = 1024 * 1024 // 写 buffer 默认大小
 	C_TCP_NO_DELAY          = true        // net.tcpConn 对象写入数据后，是否立即发送
 )
+
+// 消息处理出现 panic 时的处理策略
+const (
+	C_PANIC_DROP  uint32 = iota // 丢弃该消息，继续工作
+	C_PANIC_CLOSE               // 关闭消息所属的 session
+	C_PANIC_CRASH               // 进程崩溃退出
+)
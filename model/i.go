@@ -12,7 +12,12 @@ import (
 
 // 组件接口
 type IComponent interface {
-	Run(ctx context.Context) // 组件开始运行
-	Stop()                   // 组件停止运行
-	Name() string            // 获取组件名字
+	Run(ctx context.Context) error // 组件开始运行：返回 nil=已就绪；长期运行的工作需在 goroutine 中进行
+	Stop()                         // 组件停止运行
+	Name() string                  // 获取组件名字
+}
+
+// 组件依赖：组件实现此接口后，在依赖的组件之后启动，之前停止
+type IDependent interface {
+	Depends() []string // 依赖的组件名字
 }
@@ -4,6 +4,8 @@
 package netservice
 
 import (
+	"time"
+
 	"github.com/zpab123/sco/model"   // 全局模型
 	"github.com/zpab123/sco/network" // 网络
 	"github.com/zpab123/sco/session" // session 组件
@@ -23,7 +25,10 @@ const (
 
 // 接收器接口
 type INetService interface {
-	model.IComponent // 接口继承：组件接口
+	model.IComponent                            // 接口继承：组件接口
+	GetSessionManager() *session.SessionManager // 获取 session 管理对象
+	Shutdown() error                            // 优雅关闭第1步：停止接收新连接，并通知所有连接服务器即将关闭
+	Drain(deadline time.Time)                   // 优雅关闭第2步：等待所有连接发送队列刷新完成后关闭，超过 deadline 后强制关闭
 }
 
 // /////////////////////////////////////////////////////////////////////////////
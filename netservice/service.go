@@ -7,6 +7,7 @@ import (
 	"context"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"          // 异常
 	"github.com/zpab123/sco/network" // 网络
@@ -58,6 +59,7 @@ func NewNetService(laddr *network.TLaddr, handler session.IMsgHandler, opt *TNet
 		stateMgr:   sm,
 		option:     opt,
 		sessionMgr: sesMgr,
+		handler:    handler,
 	}
 
 	// 创建 NetService
@@ -75,51 +77,54 @@ func NewNetService(laddr *network.TLaddr, handler session.IMsgHandler, opt *TNet
 }
 
 // 启动 NetService
-func (this *NetService) Run(ctx context.Context) {
+func (this *NetService) Run(ctx context.Context) error {
 	var err error
 
 	// 改变状态： 启动中
 	if !this.stateMgr.CompareAndSwap(state.C_INIT, state.C_RUNING) {
 		if !this.stateMgr.CompareAndSwap(state.C_STOPED, state.C_RUNING) {
 			err = errors.Errorf("network.NetService 组件启动失败，状态错误。当前状态=%d，正确状态=%d或=%d", this.stateMgr.GetState(), state.C_INIT, state.C_STOPED)
-		}
 
-		return
+			return err
+		}
 	}
 
 	// acceptor 检查
 	if nil == this.acceptor {
+		this.stateMgr.SetState(state.C_STOPED)
 		err = errors.New("network.NetService 组件启动失败。acceptor=nil")
 
-		return
+		return err
 	}
 
 	// 启动 acceptor
 	if err = this.acceptor.Run(); nil != err {
-		return
+		this.stateMgr.SetState(state.C_STOPED)
+
+		return errors.Wrap(err, "network.NetService 组件启动失败")
 	}
 
 	this.stateMgr.SetState(state.C_WORKING)
 
 	zaplog.Infof("NetService 组件启动成功")
 
-	// 等待结束信号
-	// <-ctx.Done()
+	return nil
 }
 
-// 停止 NetService
+// 停止 NetService：强制关闭剩余的所有 session
+//
+// 优雅关闭时，先调用 Shutdown 与 Drain
 func (this *NetService) Stop() {
 	var err error
 
-	// 状态效验
-	if !this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_STOPING) {
-		err = errors.Errorf("network.NetService 组件停止失败，状态错误。当前状态=%d，正确状态=%d", this.stateMgr.GetState(), state.C_WORKING)
-
-		return
-	}
+	// 未调用 Shutdown：停止 acceptor
+	if this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_STOPING) {
+		if err = this.acceptor.Stop(); nil != err {
+			zaplog.Errorf("network.NetService 停止 acceptor 失败。err=%s", err)
+		}
+	} else if this.stateMgr.GetState() != state.C_STOPING {
+		err = errors.Errorf("network.NetService 组件停止失败，状态错误。当前状态=%d，正确状态=%d或%d", this.stateMgr.GetState(), state.C_WORKING, state.C_STOPING)
 
-	// 停止 acceptor
-	if err = this.acceptor.Stop(); nil != err {
 		return
 	}
 
@@ -137,11 +142,43 @@ func (this *NetService) Stop() {
 	return
 }
 
+// 停止接收新连接，并向所有 session 发送停服通知
+func (this *NetService) Shutdown() error {
+	// 状态效验
+	if !this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_STOPING) {
+		return errors.Errorf("network.NetService 优雅关闭失败，状态错误。当前状态=%d，正确状态=%d", this.stateMgr.GetState(), state.C_WORKING)
+	}
+
+	// 停止 acceptor
+	if err := this.acceptor.Stop(); nil != err {
+		zaplog.Errorf("network.NetService 停止 acceptor 失败。err=%s", err)
+	}
+
+	// 通知客户端
+	this.sessionMgr.NotifyShutdown()
+
+	zaplog.Infof("network.NetService 停止接收新连接，已通知所有 session。session 数量=%d", this.sessionMgr.GetCount())
+
+	return nil
+}
+
+// 优雅关闭所有 session：等待发送队列刷新完成后关闭，超过 deadline 后强制关闭
+//
+// 阻塞直到所有 session 关闭
+func (this *NetService) Drain(deadline time.Time) {
+	this.sessionMgr.ShutdownAllSession(deadline)
+}
+
 // 获取组件名字
 func (this *NetService) Name() string {
 	return this.cmptName
 }
 
+// 获取 session 管理对象
+func (this *NetService) GetSessionManager() *session.SessionManager {
+	return this.sessionMgr
+}
+
 // 收到1个新的 websocket 连接对象
 func (this *NetService) OnNewWsConn(wsconn *websocket.Conn) {
 	zaplog.Debugf("收到1个新的 websocket 连接。ip=%s", wsconn.RemoteAddr())
@@ -76,11 +76,22 @@ func (this *WsAcceptor) Run() error {
 	}
 
 	// 创建侦听器
-	this.listener, err = net.Listen("tcp", this.laddr)
+	this.listener, err = Listen(this.laddr)
 	if nil != err {
 		return err
 	}
 
+	// 创建 mux
+	mux := http.NewServeMux()
+	handler := websocket.Handler(this.connMgr.OnNewWsConn) // 路由函数
+	mux.Handle("/ws", handler)                             // 客户端需要在url后面加上 /ws 路由
+
+	// 创建 httpServer：在启动 goroutine 之前创建，启动后立即 Stop 时不会为 nil
+	this.httpServer = &http.Server{
+		Addr:    this.laddr,
+		Handler: mux,
+	}
+
 	this.stopGroup.Add(1)
 
 	// 侦听新连接
@@ -122,17 +133,6 @@ func (this *WsAcceptor) Stop() error {
 func (this *WsAcceptor) accept() {
 	defer this.stopGroup.Done()
 
-	// 创建 mux
-	mux := http.NewServeMux()
-	handler := websocket.Handler(this.connMgr.OnNewWsConn) // 路由函数
-	mux.Handle("/ws", handler)                             // 客户端需要在url后面加上 /ws 路由
-
-	// 创建 httpServer
-	this.httpServer = &http.Server{
-		Addr:    this.laddr,
-		Handler: mux,
-	}
-
 	// 开启服务器
 	var err error
 	zaplog.Debugf("WsAcceptor 启动成功。ip=%s", this.laddr)
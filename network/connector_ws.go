@@ -0,0 +1,59 @@
+// /////////////////////////////////////////////////////////////////////////////
+// websocket 连接器
+
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"      // 异常库
+	"golang.org/x/net/websocket" // websocket 库
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// public api
+
+// 连接1个 websocket 服务器
+//
+// addr=服务器地址，格式 192.168.1.1:8600
+func DialWs(addr string) (ISocket, error) {
+	// 参数效验
+	if "" == addr {
+		err := errors.New("连接 websocket 服务器失败。参数 addr 为空")
+
+		return nil, err
+	}
+
+	// 连接
+	url := fmt.Sprintf("ws://%s/ws", addr)
+	origin := fmt.Sprintf("http://%s/", addr)
+	config, err := websocket.NewConfig(url, origin)
+	if nil != err {
+		return nil, err
+	}
+
+	conn, err := Dial(addr)
+	if nil != err {
+		return nil, err
+	}
+
+	// websocket 握手：服务器无响应时，不会一直阻塞
+	conn.SetDeadline(time.Now().Add(C_DIAL_TIMEOUT))
+	wsconn, err := websocket.NewClient(config, conn)
+	if nil != err {
+		conn.Close()
+
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	// 以二进制方式收发数据
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	socket := &Socket{
+		Conn: wsconn,
+	}
+
+	return socket, nil
+}
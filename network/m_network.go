@@ -4,9 +4,12 @@
 package network
 
 import (
+	"fmt"
 	"net"
+	"time"
 
-	"golang.org/x/net/websocket" // websocket 库
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"golang.org/x/net/websocket"      // websocket 库
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -40,6 +43,58 @@ const (
 	C_PKT_MAX_LEN  = 25 * 1024 * 1024 // 最大单个 packet 数据，= head + body = 25M
 )
 
+// 心跳常量
+const (
+	C_HEARTBEAT_TIMEOUT_RATE = 2 // 未设置心跳超时时，心跳超时 = 心跳间隔 * 此倍数
+)
+
+// 连接常量
+const (
+	C_DIAL_TIMEOUT  = 5 * time.Second // 作为客户端，建立连接（包括 websocket 握手）的最长时间
+	C_SHAKE_TIMEOUT = 5 * time.Second // 作为客户端，发送握手请求并等待握手结果的最长时间
+)
+
+// 关闭常量
+const (
+	C_CLOSE_WRITE_TIME = 1 * time.Second // 关闭连接时，写入关闭帧等数据的最长时间：对端不读取数据时，避免关闭一直阻塞
+)
+
+// 连接关闭原因
+type CloseReason uint32
+
+// 连接关闭原因
+const (
+	C_CLOSE_REASON_NONE     CloseReason = iota // 无：尚未关闭，或关闭时未指定原因
+	C_CLOSE_REASON_SERVER                      // 服务器主动关闭
+	C_CLOSE_REASON_CLIENT                      // 对端关闭连接
+	C_CLOSE_REASON_TIMEOUT                     // 心跳超时
+	C_CLOSE_REASON_ERROR                       // 协议错误：数据无法解析、长度超限、状态错误等
+	C_CLOSE_REASON_SHUTDOWN                    // 服务器停服
+	C_CLOSE_REASON_KICK                        // 被踢下线（重复登录等）
+	C_CLOSE_REASON_OVERFLOW                    // 发送溢出：对端读取过慢，发送队列或会话恢复缓存超过上限
+)
+
+// 关闭原因名字
+var closeReasonNames = [...]string{
+	C_CLOSE_REASON_NONE:     "none",
+	C_CLOSE_REASON_SERVER:   "server",
+	C_CLOSE_REASON_CLIENT:   "client",
+	C_CLOSE_REASON_TIMEOUT:  "timeout",
+	C_CLOSE_REASON_ERROR:    "error",
+	C_CLOSE_REASON_SHUTDOWN: "shutdown",
+	C_CLOSE_REASON_KICK:     "kick",
+	C_CLOSE_REASON_OVERFLOW: "overflow",
+}
+
+// 打印信息
+func (this CloseReason) String() string {
+	if int(this) < len(closeReasonNames) {
+		return closeReasonNames[this]
+	}
+
+	return fmt.Sprintf("CloseReason(%d)", uint32(this))
+}
+
 // ScoConn 状态
 const (
 	C_CONN_STATE_INIT     uint32 = iota // 初始化状态
@@ -69,6 +124,12 @@ type IConnManager interface {
 	IWsConnManager // websocket 连接管理
 }
 
+// 握手处理：作为服务器时，ScoConn 在握手过程中通知（例如会话恢复）
+type IShakeHandler interface {
+	OnHandshake(req *protocol.HandshakeReq, res *protocol.HandshakeOk) // 握手请求验证通过，返回握手结果之前调用，可修改握手结果
+	OnHandshakeAck()                                                   // 收到握手 ACK，连接进入工作状态后调用
+}
+
 // socket 组件
 type ISocket interface {
 	net.Conn // 接口继承： 符合 Conn 的对象
@@ -120,7 +181,10 @@ func NewTBufferSocketOpt() *TBufferSocketOpt {
 // ScoConn 配置参数
 type TScoConnOpt struct {
 	ShakeKey      string            // 握手key
-	Heartbeat     uint32            // 心跳间隔，单位：秒。0=不设置心跳
+	Heartbeat     time.Duration     // 心跳间隔：作为服务器时，通过握手告知客户端。0=不发送心跳
+	Timeout       time.Duration     // 心跳超时：超过此时间未收到任何消息，关闭连接。0=心跳间隔 * C_HEARTBEAT_TIMEOUT_RATE
+	SendQueueSize int               // 发送队列最大长度：超过后以 C_CLOSE_REASON_OVERFLOW 关闭连接。0=不限制
+	ShakeTimeout  time.Duration     // 作为客户端握手时，发送请求并等待结果的最长时间。0=不限制
 	BuffSocketOpt *TBufferSocketOpt // BufferSocket 配置参数
 }
 
@@ -130,6 +194,7 @@ func NewTScoConnOpt() *TScoConnOpt {
 	buffOpt := NewTBufferSocketOpt()
 
 	opt := &TScoConnOpt{
+		ShakeTimeout:  C_SHAKE_TIMEOUT,
 		BuffSocketOpt: buffOpt,
 	}
 
@@ -0,0 +1,164 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 进程内存网络：开启后，Listen/Dial 不经过操作系统网络，用于在1个进程中运行多个 app
+
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"       // 异常库
+	"github.com/zpab123/syncutil" // 原子变量
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 包初始化
+
+// 变量
+var (
+	memNetwork   syncutil.AtomicUint32       // 是否使用进程内存网络：1=使用
+	memMutex     sync.Mutex                  // memListeners 互斥锁
+	memListeners = map[string]*memListener{} // 地址 -> 内存侦听器
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 开启/关闭进程内存网络（需在所有 app 启动前设置）
+func SetMemNetwork(enable bool) {
+	if enable {
+		memNetwork.Store(1)
+	} else {
+		memNetwork.Store(0)
+	}
+}
+
+// 是否使用进程内存网络
+func IsMemNetwork() bool {
+	return memNetwork.Load() == 1
+}
+
+// 在 addr 上侦听 tcp 连接；开启进程内存网络时，创建内存侦听器
+//
+// addr=侦听地址，格式 192.168.1.1:8600
+func Listen(addr string) (net.Listener, error) {
+	if !IsMemNetwork() {
+		return net.Listen("tcp", addr)
+	}
+
+	memMutex.Lock()
+	defer memMutex.Unlock()
+
+	if _, ok := memListeners[addr]; ok {
+		return nil, errors.Errorf("内存网络地址已被占用。addr=%s", addr)
+	}
+
+	l := &memListener{
+		addr:      memAddr(addr),
+		connChan:  make(chan net.Conn),
+		closeChan: make(chan struct{}),
+	}
+	memListeners[addr] = l
+
+	return l, nil
+}
+
+// 连接 addr；开启进程内存网络时，连接内存侦听器
+//
+// addr=服务器地址，格式 192.168.1.1:8600
+func Dial(addr string) (net.Conn, error) {
+	if !IsMemNetwork() {
+		return net.DialTimeout("tcp", addr, C_DIAL_TIMEOUT)
+	}
+
+	memMutex.Lock()
+	l, ok := memListeners[addr]
+	memMutex.Unlock()
+
+	if !ok {
+		return nil, errors.Errorf("连接内存网络地址失败，没有侦听器。addr=%s", addr)
+	}
+
+	return l.dial()
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// memAddr 对象
+
+// 内存网络地址 [net.Addr 接口]
+type memAddr string
+
+// 网络名字 [net.Addr 接口]
+func (this memAddr) Network() string {
+	return "mem"
+}
+
+// 地址 [net.Addr 接口]
+func (this memAddr) String() string {
+	return string(this)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// memListener 对象
+
+// 内存侦听器 [net.Listener 接口]
+type memListener struct {
+	addr      memAddr       // 侦听地址
+	connChan  chan net.Conn // 新连接
+	closeChan chan struct{} // 关闭通知
+	closeOnce sync.Once     // closeChan 关闭锁
+}
+
+// 等待1个新连接 [net.Listener 接口]
+func (this *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-this.connChan:
+		return conn, nil
+	case <-this.closeChan:
+		return nil, errors.Errorf("内存侦听器已关闭。addr=%s", this.addr)
+	}
+}
+
+// 关闭侦听器，并释放地址 [net.Listener 接口]
+func (this *memListener) Close() error {
+	this.closeOnce.Do(func() {
+		close(this.closeChan)
+
+		memMutex.Lock()
+		if memListeners[string(this.addr)] == this {
+			delete(memListeners, string(this.addr))
+		}
+		memMutex.Unlock()
+	})
+
+	return nil
+}
+
+// 侦听地址 [net.Listener 接口]
+func (this *memListener) Addr() net.Addr {
+	return this.addr
+}
+
+// 创建1对内存连接，一端交给 Accept，另一端返回给调用者
+func (this *memListener) dial() (net.Conn, error) {
+	server, client := net.Pipe()
+
+	timer := time.NewTimer(C_DIAL_TIMEOUT)
+	defer timer.Stop()
+
+	select {
+	case this.connChan <- server:
+		return client, nil
+	case <-this.closeChan:
+		server.Close()
+		client.Close()
+
+		return nil, errors.Errorf("连接内存网络地址失败，侦听器已关闭。addr=%s", this.addr)
+	case <-timer.C:
+		server.Close()
+		client.Close()
+
+		return nil, errors.Errorf("连接内存网络地址失败，侦听器未接受连接。addr=%s", this.addr)
+	}
+}
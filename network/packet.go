@@ -5,6 +5,7 @@ package network
 
 import (
 	"encoding/binary"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/zpab123/zaplog" // log 工具
@@ -35,6 +36,7 @@ type Packet struct {
 	bytes     []byte                             // 用于存放需要通过网络 发送/接收 的数据 （head + body）
 	initBytes [_HEAD_LEN + _MIN_PAYLOAD_CAP]byte // bytes 初始化时候的 buffer 4 + 128
 	readCount uint32                             // bytes 中已经读取的字节数
+	refcount  int32                              // 引用计数：为0时放回对象池
 }
 
 // 创建1个新的 packet 对象
@@ -52,11 +54,19 @@ func newPacket() interface{} {
 func NewPacket(mid uint16) *Packet {
 	pkt := getPacketFromPool()
 
+	pkt.refcount = 1
 	pkt.SetMid(mid)
 
 	return pkt
 }
 
+// 增加1次引用：同1个 Packet 发送给多个连接时，每个连接持有1次引用，发送完成后各自 Release
+//
+// 增加引用后，Packet 的数据只能读取，不能再修改
+func (this *Packet) Retain() {
+	atomic.AddInt32(&this.refcount, 1)
+}
+
 // 设置 Packet 的 id
 func (this *Packet) SetMid(v uint16) {
 	// 记录消息类型
@@ -315,10 +325,9 @@ func (this *Packet) ReadString() string {
 	return string(varBytes)
 }
 
-// 将1个 Packet包中的数据初始化，并存入 对象池
+// 释放1次引用：引用计数为0时，将 Packet 包中的数据初始化，并存入 对象池
 func (this *Packet) Release() {
-	// 后续添加
-	refcount := 0
+	refcount := atomic.AddInt32(&this.refcount, -1)
 
 	// 对象池处理
 	if 0 == refcount {
@@ -337,9 +346,10 @@ func (this *Packet) Release() {
 
 		// 将 pakcet 放回对象池
 		this.readCount = 0
+		this.setBodyLen(0, false)
 		packetPool.Put(this)
 	} else if refcount < 0 {
-		// zaplog.Panicf("释放1个 packet 错误，剩余 refcount=%d", p.refcount)
+		zaplog.Errorf("释放1个 packet 错误，剩余 refcount=%d。mid=%d", refcount, this.mid)
 	}
 }
 
@@ -5,9 +5,11 @@ package network
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/pkg/errors"           // 异常
 	"github.com/zpab123/sco/protocol" // world 内部通信协议
+	"github.com/zpab123/sco/scoerr"   // 错误
 	"github.com/zpab123/sco/state"    // 状态管理
 	"github.com/zpab123/zaplog"       // 日志
 )
@@ -20,6 +22,11 @@ type ScoConn struct {
 	stateMgr     *state.StateManager // 状态管理
 	option       *TScoConnOpt        // 配置参数
 	packetSocket *PacketSocket       // PacketSocket
+	shakeHandler IShakeHandler       // 握手处理（作为服务器）
+	heartbeat    time.Duration       // 心跳间隔：作为客户端时，使用握手返回的值
+	timeout      time.Duration       // 心跳超时：作为客户端时，使用握手返回的值
+	token        string              // 握手返回的会话恢复令牌（作为客户端）
+	resumed      bool                // 握手是否恢复了上次的会话（作为客户端）
 }
 
 // 新建1个 ScoConn 对象
@@ -35,6 +42,7 @@ func NewScoConn(socket ISocket, opt *TScoConnOpt) *ScoConn {
 	// 创建 packetSocket
 	bufSocket := NewBufferSocket(socket, opt.BuffSocketOpt)
 	pktSocket := NewPacketSocket(bufSocket)
+	pktSocket.SetMaxQueue(opt.SendQueueSize)
 
 	// 创建对象
 	wc := &ScoConn{
@@ -42,6 +50,7 @@ func NewScoConn(socket ISocket, opt *TScoConnOpt) *ScoConn {
 		packetSocket: pktSocket,
 		option:       opt,
 	}
+	wc.setHeartbeat(opt.Heartbeat, opt.Timeout)
 
 	// 设置为初始化状态
 	wc.stateMgr.SetState(C_CONN_STATE_INIT)
@@ -66,9 +75,9 @@ func (this *ScoConn) RecvPacket() (*Packet, error) {
 
 	// 状态效验
 	if this.stateMgr.GetState() != C_CONN_STATE_WORKING {
-		this.Close()
+		this.CloseWithReason(C_CLOSE_REASON_ERROR)
 
-		err = errors.Errorf("ScoConn %s 收到数据，但是状态错误。当前状态=%d，正确状态=%s", this, this.stateMgr.GetState(), C_CONN_STATE_WORKING)
+		err = errors.Errorf("ScoConn %s 收到数据，但是状态错误。当前状态=%d，正确状态=%d", this, this.stateMgr.GetState(), C_CONN_STATE_WORKING)
 
 		return nil, err
 	}
@@ -76,26 +85,182 @@ func (this *ScoConn) RecvPacket() (*Packet, error) {
 	return pkt, nil
 }
 
+// 设置握手处理（作为服务器），需在收发循环启动之前调用
+func (this *ScoConn) SetShakeHandler(h IShakeHandler) {
+	this.shakeHandler = h
+}
+
+// 作为客户端，向服务器发起握手，并阻塞等待握手完成
+//
+// 必须在收发循环启动之前调用
+func (this *ScoConn) Handshake() error {
+	return this.HandshakeResume("", 0)
+}
+
+// 作为客户端，使用会话恢复令牌向服务器发起握手，并阻塞等待握手完成
+//
+// token=上次握手返回的令牌；recvSeq=上次连接中已收到的消息数量（见 protocol.IsResumeMsg）。
+// 握手成功后，通过 IsResumed 判断是否恢复了上次的会话；必须在收发循环启动之前调用
+func (this *ScoConn) HandshakeResume(token string, recvSeq uint64) error {
+	var err error
+
+	// 状态效验
+	if !this.stateMgr.CompareAndSwap(C_CONN_STATE_INIT, C_CONN_STATE_SHAKE) {
+		err = errors.Errorf("ScoConn %s 握手失败，状态错误。当前状态=%d，正确状态=%d", this, this.stateMgr.GetState(), C_CONN_STATE_INIT)
+
+		return err
+	}
+
+	// 发送握手请求
+	req := &protocol.HandshakeReq{
+		Key:     this.option.ShakeKey,
+		Token:   token,
+		RecvSeq: recvSeq,
+	}
+	data, err := json.Marshal(req)
+	if nil != err {
+		return err
+	}
+
+	// 握手超时：服务器无响应时，不会一直阻塞
+	if this.option.ShakeTimeout > 0 {
+		deadline := time.Now().Add(this.option.ShakeTimeout)
+		this.packetSocket.SetSendDeadline(deadline)
+		this.packetSocket.SetRecvDeadline(deadline)
+
+		defer func() {
+			this.packetSocket.SetSendDeadline(time.Time{})
+			this.packetSocket.SetRecvDeadline(time.Time{})
+		}()
+	}
+
+	pkt := NewPacket(protocol.C_MID_HANDSHAKE)
+	pkt.AppendBytes(data)
+	if err = this.sendRaw(pkt); nil != err {
+		return err
+	}
+
+	if err = this.packetSocket.Flush(); nil != err {
+		return err
+	}
+
+	// 等待握手结果
+	var res *Packet
+	for nil == res {
+		res, err = this.packetSocket.RecvPacket()
+		if nil == err {
+			continue
+		}
+
+		if !scoerr.IsTimeoutError(err) {
+			return err
+		}
+
+		if this.option.ShakeTimeout > 0 {
+			return errors.Wrapf(err, "ScoConn %s 握手失败，等待握手结果超时。timeout=%s", this, this.option.ShakeTimeout)
+		}
+	}
+	defer res.Release()
+
+	if res.mid != protocol.C_MID_HANDSHAKE {
+		err = errors.Errorf("ScoConn %s 握手失败，收到无效消息。mid=%d", this, res.mid)
+
+		return err
+	}
+
+	ok := &protocol.HandshakeOk{}
+	if err = json.Unmarshal(res.GetBody(), ok); nil != err {
+		return err
+	}
+
+	if ok.Code != protocol.C_CODE_OK {
+		err = errors.Errorf("ScoConn %s 握手失败，服务器拒绝。code=%d", this, ok.Code)
+
+		return err
+	}
+
+	this.token = ok.Token
+	this.resumed = ok.Resumed
+	// 心跳：优先使用毫秒精度的值，旧服务器只返回秒
+	heartbeat := time.Duration(ok.HeartbeatMs) * time.Millisecond
+	if 0 == ok.HeartbeatMs {
+		heartbeat = time.Duration(ok.Heartbeat) * time.Second
+	}
+	this.setHeartbeat(heartbeat, time.Duration(ok.TimeoutMs)*time.Millisecond)
+
+	// 发送握手 ack
+	ack := NewPacket(protocol.C_MID_HANDSHAKE_ACK)
+	if err = this.sendRaw(ack); nil != err {
+		return err
+	}
+
+	if err = this.packetSocket.Flush(); nil != err {
+		return err
+	}
+
+	// 状态：工作中
+	this.stateMgr.SetState(C_CONN_STATE_WORKING)
+
+	return nil
+}
+
+// 获取握手返回的会话恢复令牌（作为客户端）
+//
+// 返回 ""=服务器不支持会话恢复
+func (this *ScoConn) GetToken() string {
+	return this.token
+}
+
+// 握手是否恢复了上次的会话（作为客户端）
+func (this *ScoConn) IsResumed() bool {
+	return this.resumed
+}
+
+// 获取心跳间隔：作为客户端时，为握手返回的值
+//
+// 返回 0=不发送心跳
+func (this *ScoConn) GetHeartbeat() time.Duration {
+	return this.heartbeat
+}
+
+// 获取心跳超时：超过此时间未收到任何消息，应关闭连接；作为客户端时，为握手返回的值
+//
+// 返回 0=不检测超时
+func (this *ScoConn) GetTimeout() time.Duration {
+	return this.timeout
+}
+
 // 关闭 ScoConn
 func (this *ScoConn) Close() error {
+	return this.CloseWithReason(C_CLOSE_REASON_NONE)
+}
+
+// 以指定原因关闭 ScoConn：通过 GetCloseReason 获取
+func (this *ScoConn) CloseWithReason(reason CloseReason) error {
 	var err error
 
-	// 关闭中
-	if this.stateMgr.GetState() == C_CONN_STATE_CLOSING {
+	// 关闭中：多个 goroutine 同时关闭时，只有1个执行
+	st := this.stateMgr.GetState()
+	if st == C_CONN_STATE_CLOSING || st == C_CONN_STATE_CLOSED || !this.stateMgr.CompareAndSwap(st, C_CONN_STATE_CLOSING) {
 		err = errors.New("ScoConn 关闭失败：它正在关闭")
 
 		return err
-	} else {
-		this.stateMgr.SetState(C_CONN_STATE_CLOSING)
 	}
 
-	err = this.packetSocket.Close()
+	err = this.packetSocket.CloseWithReason(reason)
 
 	this.stateMgr.SetState(C_CONN_STATE_CLOSED)
 
 	return err
 }
 
+// 获取关闭原因：连接自身检测到的错误（协议错误、发送溢出），或 CloseWithReason 指定的原因
+//
+// 返回 C_CLOSE_REASON_NONE=未关闭、对端关闭，或关闭时未指定原因
+func (this *ScoConn) GetCloseReason() CloseReason {
+	return this.packetSocket.GetCloseReason()
+}
+
 //  发送心跳数据
 func (this *ScoConn) SendHeartbeat() error {
 	var err error
@@ -107,19 +272,82 @@ func (this *ScoConn) SendHeartbeat() error {
 
 	zaplog.Debugf("ScoConn %s 发送心跳", this)
 
-	// 发送心跳数据
+	// 发送心跳数据：携带发送时间，对端原样返回，用于计算往返时间
 	pkt := NewPacket(protocol.C_PKT_ID_HEARTBEAT)
-	err = this.sendPacket(pkt)
+	pkt.AppendUint64(uint64(time.Now().UnixNano()))
+	err = this.sendOwned(pkt)
 
 	return err
 }
 
+// 回复心跳
+//
+// stamp=对端心跳中携带的时间戳，原样返回
+func (this *ScoConn) SendHeartbeatAck(stamp uint64) error {
+	pkt := NewPacket(protocol.C_PKT_ID_HEARTBEAT_ACK)
+	pkt.AppendUint64(stamp)
+
+	return this.sendOwned(pkt)
+}
+
+// 发送停服通知
+func (this *ScoConn) SendShutdown() error {
+	pkt := NewPacket(protocol.C_PKT_ID_SHUTDOWN)
+
+	return this.sendOwned(pkt)
+}
+
+// 发送踢下线通知
+//
+// reason=踢下线原因码
+func (this *ScoConn) SendKick(reason uint32) error {
+	data, err := json.Marshal(&protocol.KickNotify{Reason: reason})
+	if nil != err {
+		return err
+	}
+
+	pkt := NewPacket(protocol.C_PKT_ID_KICK)
+	pkt.AppendBytes(data)
+
+	return this.sendOwned(pkt)
+}
+
+// 发送关闭通知：告知对端连接即将关闭的原因
+func (this *ScoConn) SendClose(reason CloseReason) error {
+	data, err := json.Marshal(&protocol.CloseNotify{Reason: uint32(reason)})
+	if nil != err {
+		return err
+	}
+
+	pkt := NewPacket(protocol.C_PKT_ID_CLOSE)
+	pkt.AppendBytes(data)
+
+	return this.sendOwned(pkt)
+}
+
+// 发送消息确认（作为客户端）：会话恢复时，服务器不再重发已确认的消息
+//
+// seq=已收到的消息数量（见 protocol.IsResumeMsg）
+func (this *ScoConn) SendAck(seq uint64) error {
+	pkt := NewPacket(protocol.C_PKT_ID_ACK)
+	pkt.AppendUint64(seq)
+
+	return this.sendOwned(pkt)
+}
+
 // 发送通用数据
 func (this *ScoConn) SendData(data []byte) {
 	pkt := NewPacket(protocol.C_PKT_ID_DATA)
 	pkt.AppendBytes(data)
 
-	this.sendPacket(pkt)
+	this.sendOwned(pkt)
+}
+
+// 发送1个 packet 消息
+//
+// 返回错误时，pkt 仍由调用者持有
+func (this *ScoConn) SendPacket(pkt *Packet) error {
+	return this.sendPacket(pkt)
 }
 
 // 刷新缓冲区
@@ -127,11 +355,31 @@ func (this *ScoConn) Flush() error {
 	return this.packetSocket.Flush()
 }
 
+// 等待发送队列中的数据全部写入 socket，超过 deadline 返回错误
+func (this *ScoConn) WaitFlush(deadline time.Time) error {
+	return this.packetSocket.WaitFlush(deadline)
+}
+
+// 设置写超时：超过 deadline 后，阻塞中的写入立即返回错误
+func (this *ScoConn) SetSendDeadline(deadline time.Time) error {
+	return this.packetSocket.SetSendDeadline(deadline)
+}
+
 // 打印信息
 func (this *ScoConn) String() string {
 	return this.packetSocket.String()
 }
 
+// 设置心跳间隔与超时：timeout=0 时，使用 heartbeat * C_HEARTBEAT_TIMEOUT_RATE
+func (this *ScoConn) setHeartbeat(heartbeat time.Duration, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = heartbeat * C_HEARTBEAT_TIMEOUT_RATE
+	}
+
+	this.heartbeat = heartbeat
+	this.timeout = timeout
+}
+
 // 发送1个 packet 消息
 func (this *ScoConn) sendPacket(pkt *Packet) error {
 	var err error
@@ -146,6 +394,26 @@ func (this *ScoConn) sendPacket(pkt *Packet) error {
 	return this.packetSocket.SendPacket(pkt)
 }
 
+// 发送1个内部创建的 packet 消息：发送失败时释放
+func (this *ScoConn) sendOwned(pkt *Packet) error {
+	err := this.sendPacket(pkt)
+	if nil != err {
+		pkt.Release()
+	}
+
+	return err
+}
+
+// 越过工作状态，发送1个内部创建的 packet 消息（握手）：发送失败时释放
+func (this *ScoConn) sendRaw(pkt *Packet) error {
+	err := this.packetSocket.SendPacket(pkt)
+	if nil != err {
+		pkt.Release()
+	}
+
+	return err
+}
+
 // 处理 Packet 消息
 func (this *ScoConn) handlePacket(pkt *Packet) {
 	defer pkt.Release()
@@ -157,9 +425,9 @@ func (this *ScoConn) handlePacket(pkt *Packet) {
 	case protocol.C_MID_HANDSHAKE_ACK: // 客户端握手 ACK
 		this.handleHandshakeAck()
 	default:
-		zaplog.Errorf("ScoConn &s 收到无效消息mid=%d，关闭连接", this, pkt.mid)
+		zaplog.Errorf("ScoConn %s 收到无效消息mid=%d，关闭连接", this, pkt.mid)
 
-		this.Close()
+		this.CloseWithReason(C_CLOSE_REASON_ERROR)
 	}
 }
 
@@ -175,7 +443,7 @@ func (this *ScoConn) handleHandshake(body []byte) {
 	req := &protocol.HandshakeReq{}
 	err = json.Unmarshal(body, req)
 	if nil != err {
-		this.Close()
+		this.CloseWithReason(C_CLOSE_REASON_ERROR)
 
 		return
 	}
@@ -190,11 +458,11 @@ func (this *ScoConn) handleHandshake(body []byte) {
 	// 通信方式验证,后续添加
 
 	// 握手成功
-	this.handshakeOk()
+	this.handshakeOk(req)
 }
 
 //  返回握手消息
-func (this *ScoConn) handshakeOk() {
+func (this *ScoConn) handshakeOk(req *protocol.HandshakeReq) {
 	// 状态效验
 	if this.stateMgr.GetState() != C_CONN_STATE_INIT {
 		return
@@ -202,8 +470,14 @@ func (this *ScoConn) handshakeOk() {
 
 	// 返回数据
 	res := &protocol.HandshakeOk{
-		Code:      protocol.C_CODE_OK,
-		Heartbeat: this.option.Heartbeat,
+		Code:        protocol.C_CODE_OK,
+		Heartbeat:   heartbeatSeconds(this.heartbeat),
+		HeartbeatMs: uint32(this.heartbeat / time.Millisecond),
+		TimeoutMs:   uint32(this.timeout / time.Millisecond),
+	}
+
+	if nil != this.shakeHandler {
+		this.shakeHandler.OnHandshake(req, res)
 	}
 	data, err := json.Marshal(res)
 	if nil != err {
@@ -214,7 +488,7 @@ func (this *ScoConn) handshakeOk() {
 
 	pkt := NewPacket(protocol.C_MID_HANDSHAKE)
 	pkt.AppendBytes(data)
-	this.packetSocket.SendPacket(pkt) // 越过工作状态发送消息
+	this.sendRaw(pkt) // 越过工作状态发送消息
 
 	// 状态： 等待握手 ack
 	this.stateMgr.SetState(C_CONN_STATE_WAIT_ACK)
@@ -222,7 +496,7 @@ func (this *ScoConn) handshakeOk() {
 
 //  握手失败
 func (this *ScoConn) handshakeFail(code uint32) {
-	defer this.Close()
+	defer this.CloseWithReason(C_CLOSE_REASON_ERROR)
 
 	// 状态效验
 	if this.stateMgr.GetState() != C_CONN_STATE_INIT {
@@ -242,7 +516,7 @@ func (this *ScoConn) handshakeFail(code uint32) {
 
 	pkt := NewPacket(protocol.C_MID_HANDSHAKE)
 	pkt.AppendBytes(data)
-	this.packetSocket.SendPacket(pkt) // 越过工作状态发送消息
+	this.sendRaw(pkt) // 越过工作状态发送消息
 }
 
 //  处理握手ACK
@@ -255,4 +529,26 @@ func (this *ScoConn) handleHandshakeAck() {
 
 	// 发送心跳数据
 	this.SendHeartbeat()
+
+	if nil != this.shakeHandler {
+		this.shakeHandler.OnHandshakeAck()
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 心跳间隔转换为秒：握手结果中兼容旧客户端的 Heartbeat 字段
+//
+// 向下取整，旧客户端发送心跳只会更频繁；不足1秒时按1秒（0 表示不发送心跳）
+func heartbeatSeconds(heartbeat time.Duration) uint32 {
+	if heartbeat <= 0 {
+		return 0
+	}
+
+	if heartbeat < time.Second {
+		return 1
+	}
+
+	return uint32(heartbeat / time.Second)
 }
@@ -0,0 +1,160 @@
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/protocol" // 通信协议
+)
+
+// 创建1对通过内存管道连接的 ScoConn 底层 socket
+func newTestPipe(t *testing.T) (ISocket, ISocket) {
+	c1, c2 := net.Pipe()
+	t.Cleanup(func() {
+		c1.Close()
+		c2.Close()
+	})
+
+	return &Socket{Conn: c1}, &Socket{Conn: c2}
+}
+
+// 心跳间隔转换为秒：向下取整，不足1秒按1秒，0=不发送心跳
+func TestHeartbeatSeconds(t *testing.T) {
+	tests := []struct {
+		heartbeat time.Duration // 心跳间隔
+		want      uint32        // 秒
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1},
+		{500 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 1},
+		{2999 * time.Millisecond, 2},
+		{30 * time.Second, 30},
+	}
+
+	for _, tt := range tests {
+		if got := heartbeatSeconds(tt.heartbeat); got != tt.want {
+			t.Errorf("heartbeatSeconds(%s) 错误。got=%d，want=%d", tt.heartbeat, got, tt.want)
+		}
+	}
+}
+
+// 客户端使用服务器配置的心跳间隔（毫秒精度）与超时
+func TestHandshakeHeartbeat(t *testing.T) {
+	tests := []struct {
+		name      string        // 用例名字
+		heartbeat time.Duration // 服务器心跳间隔
+		timeout   time.Duration // 服务器心跳超时
+		wantHb    time.Duration // 客户端心跳间隔
+		wantTo    time.Duration // 客户端心跳超时
+	}{
+		{"sub second", 200 * time.Millisecond, 0, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"fractional seconds", 1500 * time.Millisecond, 0, 1500 * time.Millisecond, 3 * time.Second},
+		{"explicit timeout", 200 * time.Millisecond, time.Second, 200 * time.Millisecond, time.Second},
+		{"disabled", 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, ss := newTestPipe(t)
+
+			opt := NewTScoConnOpt()
+			opt.Heartbeat = tt.heartbeat
+			opt.Timeout = tt.timeout
+			server := NewScoConn(ss, opt)
+			defer server.Close()
+
+			// 服务器收发循环：握手在接收时处理
+			go func() {
+				for nil == server.Flush() {
+				}
+			}()
+			go func() {
+				for {
+					if _, err := server.RecvPacket(); nil != err {
+						return
+					}
+				}
+			}()
+
+			client := NewScoConn(cs, nil)
+			defer client.Close()
+
+			if err := client.Handshake(); nil != err {
+				t.Fatal(err)
+			}
+
+			if got := client.GetHeartbeat(); got != tt.wantHb {
+				t.Errorf("心跳间隔错误。got=%s，want=%s", got, tt.wantHb)
+			}
+
+			if got := client.GetTimeout(); got != tt.wantTo {
+				t.Errorf("心跳超时错误。got=%s，want=%s", got, tt.wantTo)
+			}
+		})
+	}
+}
+
+// 握手结果没有毫秒精度的字段时（旧服务器），客户端使用秒
+func TestHandshakeHeartbeatFallback(t *testing.T) {
+	tests := []struct {
+		name   string               // 用例名字
+		res    protocol.HandshakeOk // 服务器返回的握手结果
+		wantHb time.Duration        // 客户端心跳间隔
+		wantTo time.Duration        // 客户端心跳超时
+	}{
+		{"seconds only", protocol.HandshakeOk{Heartbeat: 2}, 2 * time.Second, 4 * time.Second},
+		{"ms preferred", protocol.HandshakeOk{Heartbeat: 1, HeartbeatMs: 300}, 300 * time.Millisecond, 600 * time.Millisecond},
+		{"seconds with timeout", protocol.HandshakeOk{Heartbeat: 3, TimeoutMs: 10000}, 3 * time.Second, 10 * time.Second},
+		{"disabled", protocol.HandshakeOk{}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, ss := newTestPipe(t)
+
+			// 旧服务器：只返回指定的握手结果
+			ps := NewPacketSocket(NewBufferSocket(ss, NewTBufferSocketOpt()))
+			go func() {
+				req, err := ps.RecvPacket()
+				if nil != err {
+					return
+				}
+				req.Release()
+
+				res := tt.res
+				res.Code = protocol.C_CODE_OK
+				data, _ := json.Marshal(&res)
+
+				pkt := NewPacket(protocol.C_MID_HANDSHAKE)
+				pkt.AppendBytes(data)
+				ps.SendPacket(pkt)
+				ps.Flush()
+
+				// 握手 ack
+				if ack, err := ps.RecvPacket(); nil == err {
+					ack.Release()
+				}
+			}()
+
+			client := NewScoConn(cs, nil)
+			defer client.Close()
+
+			if err := client.Handshake(); nil != err {
+				t.Fatal(err)
+			}
+
+			if got := client.GetHeartbeat(); got != tt.wantHb {
+				t.Errorf("心跳间隔错误。got=%s，want=%s", got, tt.wantHb)
+			}
+
+			if got := client.GetTimeout(); got != tt.wantTo {
+				t.Errorf("心跳超时错误。got=%s，want=%s", got, tt.wantTo)
+			}
+		})
+	}
+}
@@ -39,6 +39,10 @@ type PacketSocket struct {
 	mutex         sync.Mutex      // 线程互斥锁（发送队列使用）
 	cond          *sync.Cond      // 条件同步（发送队列使用）
 	sendQueue     []*Packet       // 发送队列
+	flushing      bool            // 是否正在将发送队列中的数据写入 socket
+	closed        bool            // 是否已关闭：关闭后不再接受发送，等待中的 Flush、WaitFlush 立即返回
+	closeReason   CloseReason     // 关闭原因
+	maxQueue      int             // 发送队列最大长度，0=不限制
 	recvedHeadLen int             // 从 socket 的 readbuffer 中已经读取的 head 数据大小：字节（用于消息读取记录）
 	recvedBodyLen int             // 从 socket 的 readbuffer 中已经读取的 body 数据大小：字节（用于消息读取记录）
 	headBuff      [_HEAD_LEN]byte // 存放消息头二进制数据
@@ -91,7 +95,7 @@ func (this *PacketSocket) RecvPacket() (*Packet, error) {
 			// zaplog.Errorf("%s", err)
 
 			this.resetRecvStates()
-			this.Close()
+			this.CloseWithReason(C_CLOSE_REASON_ERROR)
 
 			return nil, err
 		}
@@ -144,34 +148,64 @@ func (this *PacketSocket) RecvPacket() (*Packet, error) {
 }
 
 // 发送1个 *Packe 数据
+//
+// 返回错误时，pkt 仍由调用者持有
 func (this *PacketSocket) SendPacket(pkt *Packet) error {
-	// 状态效验
-
 	// 添加到消息队列
 	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+
+		return errors.Errorf("PacketSocket %s 发送 packet 失败：已关闭", this)
+	}
+
+	// 对端读取过慢
+	if this.maxQueue > 0 && len(this.sendQueue) >= this.maxQueue {
+		this.mutex.Unlock()
+		this.CloseWithReason(C_CLOSE_REASON_OVERFLOW)
+
+		return errors.Errorf("PacketSocket %s 发送 packet 失败：发送队列超过 %d 个，关闭连接", this, this.maxQueue)
+	}
 	this.sendQueue = append(this.sendQueue, pkt)
 	this.mutex.Unlock()
 
-	this.cond.Signal()
+	// Flush 与 WaitFlush 共用条件变量，需全部唤醒
+	this.cond.Broadcast()
 
 	return nil
 }
 
 // 将消息队列中的数据写入 writebuff
+//
+// 发送队列为空时阻塞等待；关闭后返回错误
 func (this *PacketSocket) Flush() (err error) {
 	// 等待数据
 	this.mutex.Lock()
-	for len(this.sendQueue) == 0 {
+	for len(this.sendQueue) == 0 && !this.closed {
 		this.cond.Wait()
 	}
-	this.mutex.Unlock()
+
+	if this.closed {
+		this.mutex.Unlock()
+
+		return errors.Errorf("PacketSocket %s 刷新失败：已关闭", this)
+	}
 
 	// 复制数据
-	this.mutex.Lock()
 	packets := make([]*Packet, 0, len(this.sendQueue)) // 复制准备
 	packets, this.sendQueue = this.sendQueue, packets  // 交换数据, 并把原来的数据置空
+	this.flushing = true
 	this.mutex.Unlock()
 
+	// 写入完成，通知 WaitFlush
+	defer func() {
+		this.mutex.Lock()
+		this.flushing = false
+		this.mutex.Unlock()
+
+		this.cond.Broadcast()
+	}()
+
 	// 刷新数据
 	if 1 == len(packets) {
 		pkt := packets[0]
@@ -201,8 +235,82 @@ func (this *PacketSocket) Flush() (err error) {
 	return
 }
 
-// 关闭 socket
+// 等待发送队列中的数据全部写入 socket
+//
+// 超过 deadline 仍未写完，返回错误
+func (this *PacketSocket) WaitFlush(deadline time.Time) error {
+	// 到期后唤醒等待（先加锁，确保等待者已进入 Wait，避免丢失唤醒）
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		this.mutex.Lock()
+		this.mutex.Unlock()
+
+		this.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for len(this.sendQueue) > 0 || this.flushing {
+		if this.closed {
+			return errors.Errorf("PacketSocket %s 等待发送队列刷新失败：已关闭", this)
+		}
+
+		if !time.Now().Before(deadline) {
+			return errors.Errorf("PacketSocket %s 等待发送队列刷新超时。剩余数量=%d", this, len(this.sendQueue))
+		}
+
+		this.cond.Wait()
+	}
+
+	return nil
+}
+
+// 设置发送队列最大长度：超过后以 C_CLOSE_REASON_OVERFLOW 关闭。0=不限制
+func (this *PacketSocket) SetMaxQueue(n int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.maxQueue = n
+}
+
+// 获取关闭原因
+//
+// 返回 C_CLOSE_REASON_NONE=未关闭，或关闭时未指定原因
+func (this *PacketSocket) GetCloseReason() CloseReason {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.closeReason
+}
+
+// 关闭 socket：释放发送队列中的数据，并唤醒等待中的 Flush、WaitFlush
 func (this *PacketSocket) Close() error {
+	return this.CloseWithReason(C_CLOSE_REASON_NONE)
+}
+
+// 以指定原因关闭 socket：只有第1次关闭生效
+func (this *PacketSocket) CloseWithReason(reason CloseReason) error {
+	this.mutex.Lock()
+	if this.closed {
+		this.mutex.Unlock()
+
+		return nil
+	}
+
+	this.closed = true
+	this.closeReason = reason
+	for _, pkt := range this.sendQueue {
+		pkt.Release()
+	}
+	this.sendQueue = nil
+	this.mutex.Unlock()
+
+	this.cond.Broadcast()
+
+	// 对端不读取数据时，阻塞中的写入及关闭帧会一直等待
+	this.socket.SetWriteDeadline(time.Now().Add(C_CLOSE_WRITE_TIME))
+
 	return this.socket.Close()
 }
 
@@ -211,6 +319,11 @@ func (this *PacketSocket) SetRecvDeadline(deadline time.Time) error {
 	return this.socket.SetReadDeadline(deadline)
 }
 
+// 设置写超时
+func (this *PacketSocket) SetSendDeadline(deadline time.Time) error {
+	return this.socket.SetWriteDeadline(deadline)
+}
+
 // 获取客户端 ip 地址
 func (this *PacketSocket) RemoteAddr() net.Addr {
 	return this.socket.RemoteAddr()
@@ -0,0 +1,26 @@
+// /////////////////////////////////////////////////////////////////////////////
+// app 需要的协议
+
+package protocol
+
+// /////////////////////////////////////////////////////////////////////////////
+// app
+
+// 服务器->客户端 消息处理失败
+type ErrorRes struct {
+	Mid  uint16 // 出错的消息id
+	Code uint32 // 错误码
+	Msg  string // 错误描述
+}
+
+// 服务器->前端服务器 组播消息（rpc）
+type GroupPushReq struct {
+	SesIds []int64 // 接收消息的客户端 session id
+	Mid    uint16  // 消息id
+	Data   []byte  // 消息内容（已编码）
+}
+
+// 前端服务器->服务器 组播消息回复（rpc）
+type GroupPushRes struct {
+	Missing []int64 // 已不存在的客户端 session id
+}
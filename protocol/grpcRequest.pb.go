@@ -20,12 +20,15 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// rpc 请求
 type GrpcRequest struct {
-	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Acceptor             uint32   `protobuf:"varint,2,opt,name=acceptor,proto3" json:"acceptor,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Route                string       `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	Payload              []byte       `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	Session              *GrpcSession `protobuf:"bytes,3,opt,name=session,proto3" json:"session,omitempty"`
+	Source               string       `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
 }
 
 func (m *GrpcRequest) Reset()         { *m = GrpcRequest{} }
@@ -53,33 +56,122 @@ func (m *GrpcRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_GrpcRequest proto.InternalMessageInfo
 
-func (m *GrpcRequest) GetKey() string {
+func (m *GrpcRequest) GetRoute() string {
 	if m != nil {
-		return m.Key
+		return m.Route
 	}
 	return ""
 }
 
-func (m *GrpcRequest) GetAcceptor() uint32 {
+func (m *GrpcRequest) GetPayload() []byte {
 	if m != nil {
-		return m.Acceptor
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *GrpcRequest) GetSession() *GrpcSession {
+	if m != nil {
+		return m.Session
+	}
+	return nil
+}
+
+func (m *GrpcRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+// rpc 请求携带的客户端 session 上下文
+type GrpcSession struct {
+	Frontend             string            `protobuf:"bytes,1,opt,name=frontend,proto3" json:"frontend,omitempty"`
+	Id                   int64             `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Uid                  string            `protobuf:"bytes,3,opt,name=uid,proto3" json:"uid,omitempty"`
+	Data                 map[string]string `protobuf:"bytes,4,rep,name=data,proto3" json:"data,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *GrpcSession) Reset()         { *m = GrpcSession{} }
+func (m *GrpcSession) String() string { return proto.CompactTextString(m) }
+func (*GrpcSession) ProtoMessage()    {}
+func (*GrpcSession) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b182e69d70999653, []int{1}
+}
+
+func (m *GrpcSession) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GrpcSession.Unmarshal(m, b)
+}
+func (m *GrpcSession) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GrpcSession.Marshal(b, m, deterministic)
+}
+func (m *GrpcSession) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GrpcSession.Merge(m, src)
+}
+func (m *GrpcSession) XXX_Size() int {
+	return xxx_messageInfo_GrpcSession.Size(m)
+}
+func (m *GrpcSession) XXX_DiscardUnknown() {
+	xxx_messageInfo_GrpcSession.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GrpcSession proto.InternalMessageInfo
+
+func (m *GrpcSession) GetFrontend() string {
+	if m != nil {
+		return m.Frontend
+	}
+	return ""
+}
+
+func (m *GrpcSession) GetId() int64 {
+	if m != nil {
+		return m.Id
 	}
 	return 0
 }
 
+func (m *GrpcSession) GetUid() string {
+	if m != nil {
+		return m.Uid
+	}
+	return ""
+}
+
+func (m *GrpcSession) GetData() map[string]string {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*GrpcRequest)(nil), "protocol.GrpcRequest")
+	proto.RegisterType((*GrpcSession)(nil), "protocol.GrpcSession")
+	proto.RegisterMapType((map[string]string)(nil), "protocol.GrpcSession.DataEntry")
 }
 
 func init() { proto.RegisterFile("grpcRequest.proto", fileDescriptor_b182e69d70999653) }
 
 var fileDescriptor_b182e69d70999653 = []byte{
-	// 98 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x4c, 0x2f, 0x2a, 0x48,
-	0x0e, 0x4a, 0x2d, 0x2c, 0x4d, 0x2d, 0x2e, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x00,
-	0x53, 0xc9, 0xf9, 0x39, 0x4a, 0xd6, 0x5c, 0xdc, 0xee, 0x08, 0x69, 0x21, 0x01, 0x2e, 0xe6, 0xec,
-	0xd4, 0x4a, 0x09, 0x46, 0x05, 0x46, 0x0d, 0xce, 0x20, 0x10, 0x53, 0x48, 0x8a, 0x8b, 0x23, 0x31,
-	0x39, 0x39, 0xb5, 0xa0, 0x24, 0xbf, 0x48, 0x82, 0x49, 0x81, 0x51, 0x83, 0x37, 0x08, 0xce, 0x4f,
-	0x62, 0x03, 0x1b, 0x63, 0x0c, 0x08, 0x00, 0x00, 0xff, 0xff, 0x35, 0x21, 0xf6, 0xbe, 0x62, 0x00,
-	0x00, 0x00,
+	// 248 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0xcd, 0x4a, 0xc4, 0x30,
+	0x10, 0xc7, 0x49, 0x5b, 0x77, 0xb7, 0x53, 0x11, 0x1d, 0x54, 0xc2, 0x5e, 0x2c, 0x7b, 0xea, 0xa9,
+	0xc2, 0xee, 0x41, 0xf1, 0xac, 0x78, 0x8f, 0x4f, 0x10, 0x9b, 0x28, 0xc5, 0xd2, 0xd4, 0x7c, 0x08,
+	0x7d, 0x01, 0xdf, 0xc9, 0xb7, 0x93, 0x7c, 0x74, 0xbd, 0x78, 0xca, 0xfc, 0x32, 0x93, 0xff, 0xfc,
+	0x08, 0x5c, 0xbc, 0xeb, 0xa9, 0x63, 0xf2, 0xd3, 0x49, 0x63, 0xdb, 0x49, 0x2b, 0xab, 0x70, 0x13,
+	0x8e, 0x4e, 0x0d, 0xbb, 0x6f, 0x02, 0xd5, 0xf3, 0x5f, 0x1f, 0x2f, 0xe1, 0x44, 0x2b, 0x67, 0x25,
+	0x25, 0x35, 0x69, 0x4a, 0x16, 0x01, 0x29, 0xac, 0x27, 0x3e, 0x0f, 0x8a, 0x0b, 0x9a, 0xd5, 0xa4,
+	0x39, 0x65, 0x0b, 0xe2, 0x2d, 0xac, 0x8d, 0x34, 0xa6, 0x57, 0x23, 0xcd, 0x6b, 0xd2, 0x54, 0xfb,
+	0xab, 0x76, 0xc9, 0x6e, 0x7d, 0xee, 0x4b, 0x6c, 0xb2, 0x65, 0x0a, 0xaf, 0x61, 0x65, 0x94, 0xd3,
+	0x9d, 0xa4, 0x45, 0xd8, 0x90, 0x68, 0xf7, 0x93, 0x44, 0xd2, 0x03, 0xdc, 0xc2, 0xe6, 0x4d, 0xab,
+	0xd1, 0xca, 0x51, 0x24, 0x97, 0x23, 0xe3, 0x19, 0x64, 0x7d, 0x34, 0xc9, 0x59, 0xd6, 0x0b, 0x3c,
+	0x87, 0xdc, 0xf5, 0x22, 0x08, 0x94, 0xcc, 0x97, 0x78, 0x80, 0x42, 0x70, 0xcb, 0x69, 0x51, 0xe7,
+	0x4d, 0xb5, 0xbf, 0xf9, 0xd7, 0xa9, 0x7d, 0xe4, 0x96, 0x3f, 0x8d, 0x56, 0xcf, 0x2c, 0x0c, 0x6f,
+	0xef, 0xa0, 0x3c, 0x5e, 0xf9, 0xcc, 0x0f, 0x39, 0xa7, 0xd5, 0xbe, 0xf4, 0x5f, 0xf3, 0xc5, 0x07,
+	0x27, 0xc3, 0xe2, 0x92, 0x45, 0x78, 0xc8, 0xee, 0xc9, 0xeb, 0x2a, 0xc4, 0x1f, 0x7e, 0x07, 0x00,
+	0x57, 0x9a, 0x5b, 0x44, 0x6a, 0x01, 0x00, 0x00,
 }
@@ -20,9 +20,11 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// rpc 回复
 type GrpcResponse struct {
 	Code                 uint32   `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
-	Heartbeat            uint32   `protobuf:"varint,2,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	Msg                  string   `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	Payload              []byte   `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -60,11 +62,18 @@ func (m *GrpcResponse) GetCode() uint32 {
 	return 0
 }
 
-func (m *GrpcResponse) GetHeartbeat() uint32 {
+func (m *GrpcResponse) GetMsg() string {
 	if m != nil {
-		return m.Heartbeat
+		return m.Msg
 	}
-	return 0
+	return ""
+}
+
+func (m *GrpcResponse) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
 }
 
 func init() {
@@ -74,12 +83,12 @@ func init() {
 func init() { proto.RegisterFile("grpcResponse.proto", fileDescriptor_65954beacfae9d1d) }
 
 var fileDescriptor_65954beacfae9d1d = []byte{
-	// 98 bytes of a gzipped FileDescriptorProto
+	// 112 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x4a, 0x2f, 0x2a, 0x48,
 	0x0e, 0x4a, 0x2d, 0x2e, 0xc8, 0xcf, 0x2b, 0x4e, 0xd5, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2,
-	0x00, 0x53, 0xc9, 0xf9, 0x39, 0x4a, 0x0e, 0x5c, 0x3c, 0xee, 0x48, 0xf2, 0x42, 0x42, 0x5c, 0x2c,
-	0xc9, 0xf9, 0x29, 0xa9, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0xbc, 0x41, 0x60, 0xb6, 0x90, 0x0c, 0x17,
-	0x67, 0x46, 0x6a, 0x62, 0x51, 0x49, 0x52, 0x6a, 0x62, 0x89, 0x04, 0x13, 0x58, 0x02, 0x21, 0x90,
-	0xc4, 0x06, 0x36, 0xcb, 0x18, 0x10, 0x00, 0x00, 0xff, 0xff, 0x49, 0x72, 0xb6, 0xd2, 0x68, 0x00,
-	0x00, 0x00,
+	0x00, 0x53, 0xc9, 0xf9, 0x39, 0x4a, 0x7e, 0x5c, 0x3c, 0xee, 0x48, 0xf2, 0x42, 0x42, 0x5c, 0x2c,
+	0xc9, 0xf9, 0x29, 0xa9, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0xbc, 0x41, 0x60, 0xb6, 0x90, 0x00, 0x17,
+	0x73, 0x6e, 0x71, 0xba, 0x04, 0x93, 0x02, 0xa3, 0x06, 0x67, 0x10, 0x88, 0x29, 0x24, 0xc1, 0xc5,
+	0x5e, 0x90, 0x58, 0x99, 0x93, 0x9f, 0x98, 0x22, 0xc1, 0xac, 0xc0, 0xa8, 0xc1, 0x13, 0x04, 0xe3,
+	0x26, 0xb1, 0x81, 0x4d, 0x36, 0x06, 0x0c, 0x00, 0xf8, 0xb9, 0x8f, 0x57, 0x76, 0x00, 0x00, 0x00,
 }
@@ -16,8 +16,19 @@ const (
 
 // sco 框架消息 (101-)
 const (
-	C_PKT_ID_HEARTBEAT uint16 = iota + 101 // 心跳消息
-	C_PKT_ID_DATA                          // 通用消息
+	C_PKT_ID_HEARTBEAT     uint16 = iota + 101 // 心跳消息
+	C_PKT_ID_DATA                              // 通用消息
+	C_PKT_ID_ERROR                             // 错误消息（消息被过滤器中断等）
+	C_PKT_ID_FORWARD                           // 前端服务器 -> 后端服务器：转发客户端消息
+	C_PKT_ID_BACKWARD                          // 后端服务器 -> 前端服务器：回复/推送客户端消息
+	C_PKT_ID_SHUTDOWN                          // 服务器 -> 客户端：服务器即将关闭，不再处理新消息
+	C_PKT_ID_KICK                              // 服务器 -> 客户端：踢下线，随后关闭连接
+	C_PKT_ID_ACK                               // 客户端 -> 服务器：确认已收到的消息数量（会话恢复使用）
+	C_PKT_ID_SESSION_SYNC                      // 前端服务器 -> 后端服务器：同步客户端 session 信息
+	C_PKT_ID_SESSION_CLOSE                     // 前端服务器 -> 后端服务器：客户端 session 已关闭
+	C_PKT_ID_SESSION_KICK                      // 后端服务器 -> 前端服务器：将客户端踢下线
+	C_PKT_ID_HEARTBEAT_ACK                     // 心跳回复：原样返回心跳中的时间戳，用于计算往返时间
+	C_PKT_ID_CLOSE                             // 服务器 -> 客户端：连接即将关闭，携带关闭原因
 )
 
 // 通用消息码(1-1000)
@@ -30,4 +41,25 @@ const (
 const (
 	C_CODE_SHAKE_KEY_ERROR      uint32 = iota + 1001 // 握手 key 消息错误 1001
 	C_CODE_SHAKE_ACCEPTOR_ERROR                      // 网络方式错误 1002
+	C_CODE_RPC_ROUTE_ERROR                           // rpc 路由不存在 1003
+	C_CODE_KICK_RELOGIN                              // 重复登录，被踢下线 1004
 )
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 是否是会话恢复需要计数的消息：握手、心跳、心跳回复、停服通知、踢下线通知、关闭通知、确认消息之外的所有消息
+//
+// 客户端统计收到的此类消息数量，通过 C_PKT_ID_ACK 确认，并在恢复握手时发送给服务器
+func IsResumeMsg(mid uint16) bool {
+	if mid < C_MID_SCO {
+		return false
+	}
+
+	switch mid {
+	case C_PKT_ID_HEARTBEAT, C_PKT_ID_HEARTBEAT_ACK, C_PKT_ID_SHUTDOWN, C_PKT_ID_KICK, C_PKT_ID_CLOSE, C_PKT_ID_ACK:
+		return false
+	}
+
+	return true
+}
@@ -10,15 +10,50 @@ package protocol
 type HandshakeReq struct {
 	Key      string // 通信key
 	Acceptor uint32 // 1=tcp;2=websocket;3=;通信方式
+	Token    string // 会话恢复令牌：上次握手返回的 Token，""=新建会话
+	RecvSeq  uint64 // 会话恢复：上次连接中已收到的消息数量（见 IsResumeMsg）
 }
 
 // 服务器->客户端握手结果(握手成功)
 type HandshakeOk struct {
-	Code      uint32 // 握手结果
-	Heartbeat uint32 // 心跳时间
+	Code        uint32 // 握手结果
+	Heartbeat   uint32 // 心跳间隔，单位：秒（兼容旧客户端，不足1秒按1秒）。0=不发送心跳
+	HeartbeatMs uint32 // 心跳间隔，单位：毫秒。0=服务器不支持，使用 Heartbeat
+	TimeoutMs   uint32 // 心跳超时，单位：毫秒：超过此时间未收到任何消息，服务器关闭连接。0=心跳间隔的2倍
+	Token       string // 会话恢复令牌，""=服务器不支持会话恢复
+	Resumed     bool   // 是否恢复了上次的会话：false=新建会话
 }
 
 // 服务器->客户端握手结果（失败）
 type HandshakeFail struct {
 	Code uint32 // 握手结果
 }
+
+// 服务器->客户端踢下线通知
+type KickNotify struct {
+	Reason uint32 // 踢下线原因
+}
+
+// 服务器->客户端关闭通知
+type CloseNotify struct {
+	Reason uint32 // 关闭原因，见 network.CloseReason
+}
+
+// 前端服务器->后端服务器 同步客户端 session 信息
+type SessionSync struct {
+	Frontend string            // 前端服务器名字
+	SesId    int64             // 客户端在前端服务器上的 session id
+	Uid      string            // 绑定的用户 id
+	Attrs    map[string]string // 需要同步的属性
+}
+
+// 前端服务器->后端服务器 客户端 session 已关闭
+type SessionClose struct {
+	SesId int64 // 客户端在前端服务器上的 session id
+}
+
+// 后端服务器->前端服务器 将客户端踢下线
+type SessionKick struct {
+	SesId  int64  // 客户端在前端服务器上的 session id
+	Reason uint32 // 踢下线原因
+}
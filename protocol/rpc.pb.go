@@ -1,137 +0,0 @@
-// Code generated by protoc-gen-go. DO NOT EDIT.
-// source: rpc.proto
-
-package protocol
-
-import (
-	fmt "fmt"
-	proto "github.com/golang/protobuf/proto"
-	math "math"
-)
-
-// Reference imports to suppress errors if they are not otherwise used.
-var _ = proto.Marshal
-var _ = fmt.Errorf
-var _ = math.Inf
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the proto package it is being compiled against.
-// A compilation error at this line likely means your copy of the
-// proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
-
-// client->server 请求
-type RpcRequest struct {
-	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Acceptor             uint32   `protobuf:"varint,2,opt,name=acceptor,proto3" json:"acceptor,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
-}
-
-func (m *RpcRequest) Reset()         { *m = RpcRequest{} }
-func (m *RpcRequest) String() string { return proto.CompactTextString(m) }
-func (*RpcRequest) ProtoMessage()    {}
-func (*RpcRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{0}
-}
-
-func (m *RpcRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_RpcRequest.Unmarshal(m, b)
-}
-func (m *RpcRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_RpcRequest.Marshal(b, m, deterministic)
-}
-func (m *RpcRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_RpcRequest.Merge(m, src)
-}
-func (m *RpcRequest) XXX_Size() int {
-	return xxx_messageInfo_RpcRequest.Size(m)
-}
-func (m *RpcRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_RpcRequest.DiscardUnknown(m)
-}
-
-var xxx_messageInfo_RpcRequest proto.InternalMessageInfo
-
-func (m *RpcRequest) GetKey() string {
-	if m != nil {
-		return m.Key
-	}
-	return ""
-}
-
-func (m *RpcRequest) GetAcceptor() uint32 {
-	if m != nil {
-		return m.Acceptor
-	}
-	return 0
-}
-
-// server->client 回复
-type RpcResponse struct {
-	Code                 uint32   `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
-	Heartbeat            uint32   `protobuf:"varint,2,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
-}
-
-func (m *RpcResponse) Reset()         { *m = RpcResponse{} }
-func (m *RpcResponse) String() string { return proto.CompactTextString(m) }
-func (*RpcResponse) ProtoMessage()    {}
-func (*RpcResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{1}
-}
-
-func (m *RpcResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_RpcResponse.Unmarshal(m, b)
-}
-func (m *RpcResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_RpcResponse.Marshal(b, m, deterministic)
-}
-func (m *RpcResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_RpcResponse.Merge(m, src)
-}
-func (m *RpcResponse) XXX_Size() int {
-	return xxx_messageInfo_RpcResponse.Size(m)
-}
-func (m *RpcResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_RpcResponse.DiscardUnknown(m)
-}
-
-var xxx_messageInfo_RpcResponse proto.InternalMessageInfo
-
-func (m *RpcResponse) GetCode() uint32 {
-	if m != nil {
-		return m.Code
-	}
-	return 0
-}
-
-func (m *RpcResponse) GetHeartbeat() uint32 {
-	if m != nil {
-		return m.Heartbeat
-	}
-	return 0
-}
-
-func init() {
-	proto.RegisterType((*RpcRequest)(nil), "protocol.RpcRequest")
-	proto.RegisterType((*RpcResponse)(nil), "protocol.RpcResponse")
-}
-
-func init() { proto.RegisterFile("rpc.proto", fileDescriptor_77a6da22d6a3feb1) }
-
-var fileDescriptor_77a6da22d6a3feb1 = []byte{
-	// 140 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2c, 0x2a, 0x48, 0xd6,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x00, 0x53, 0xc9, 0xf9, 0x39, 0x4a, 0x56, 0x5c, 0x5c,
-	0x41, 0x05, 0xc9, 0x41, 0xa9, 0x85, 0xa5, 0xa9, 0xc5, 0x25, 0x42, 0x02, 0x5c, 0xcc, 0xd9, 0xa9,
-	0x95, 0x12, 0x8c, 0x0a, 0x8c, 0x1a, 0x9c, 0x41, 0x20, 0xa6, 0x90, 0x14, 0x17, 0x47, 0x62, 0x72,
-	0x72, 0x6a, 0x41, 0x49, 0x7e, 0x91, 0x04, 0x93, 0x02, 0xa3, 0x06, 0x6f, 0x10, 0x9c, 0xaf, 0x64,
-	0xcf, 0xc5, 0x0d, 0xd6, 0x5b, 0x5c, 0x90, 0x9f, 0x57, 0x9c, 0x2a, 0x24, 0xc4, 0xc5, 0x92, 0x9c,
-	0x9f, 0x92, 0x0a, 0xd6, 0xcd, 0x1b, 0x04, 0x66, 0x0b, 0xc9, 0x70, 0x71, 0x66, 0xa4, 0x26, 0x16,
-	0x95, 0x24, 0xa5, 0x26, 0x96, 0x40, 0xf5, 0x23, 0x04, 0x92, 0xd8, 0xc0, 0xce, 0x30, 0x06, 0x04,
-	0x00, 0x00, 0xff, 0xff, 0x46, 0x4e, 0x5c, 0x7e, 0x9a, 0x00, 0x00, 0x00,
-}
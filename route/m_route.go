@@ -0,0 +1,51 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 常量-接口-types
+
+package route
+
+import (
+	"github.com/zpab123/sco/config"  // 配置管理
+	"github.com/zpab123/sco/session" // 会话
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 常量
+
+// route 常量
+const (
+	C_HASH_REPLICAS = 100 // 一致性哈希：每个服务器的虚拟节点数量
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 接口
+
+// 服务器选择器：从同类型的服务器列表中，选择1个服务器
+type ISelector interface {
+	Select(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo // 选择服务器，servers 不为空
+}
+
+// 需要释放的选择器：选择结果使用完毕后，需要调用 Release（例如最少连接选择器）
+type IReleaser interface {
+	Release(server *config.TServerInfo) // 释放1次选择结果
+}
+
+// 选择函数：用户自定义的选择器
+type SelectorFunc func(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo
+
+// 选择服务器 [ISelector 接口]
+func (this SelectorFunc) Select(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo {
+	return this(servers, ctx)
+}
+
+// 一致性哈希 key 函数：根据路由上下文，获取哈希 key
+type KeyFunc func(ctx *TRouteCtx) string
+
+// /////////////////////////////////////////////////////////////////////////////
+// TRouteCtx 对象
+
+// 路由上下文
+type TRouteCtx struct {
+	Session   *session.ClientSession // 客户端 session，rpc 调用时为 nil
+	SessionId int64                  // 客户端 session id，0=无
+	Key       string                 // 路由 key，例如 uid、房间id（一致性哈希使用）
+}
@@ -0,0 +1,79 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 服务器路由
+
+package route
+
+import (
+	"sync"
+
+	"github.com/zpab123/sco/config" // 配置管理
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// Router 对象
+
+// 服务器路由：为每种服务器类型配置选择器，未配置的类型使用轮询选择器
+type Router struct {
+	mutex     sync.RWMutex         // selectors 读写锁
+	selectors map[string]ISelector // 服务器类型 -> 选择器
+}
+
+// 新建1个 Router
+func NewRouter() *Router {
+	r := &Router{
+		selectors: map[string]ISelector{},
+	}
+
+	return r
+}
+
+// 设置服务器类型对应的选择器
+func (this *Router) SetSelector(serverType string, sel ISelector) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.selectors[serverType] = sel
+}
+
+// 获取服务器类型对应的选择器，未设置时创建轮询选择器
+func (this *Router) GetSelector(serverType string) ISelector {
+	this.mutex.RLock()
+	sel, ok := this.selectors[serverType]
+	this.mutex.RUnlock()
+
+	if ok {
+		return sel
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if sel, ok = this.selectors[serverType]; !ok {
+		sel = NewRoundRobinSelector()
+		this.selectors[serverType] = sel
+	}
+
+	return sel
+}
+
+// 从 servers 中为 serverType 类型选择1个服务器
+//
+// 返回 nil=servers 为空
+func (this *Router) Select(serverType string, servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	if nil == ctx {
+		ctx = &TRouteCtx{}
+	}
+
+	return this.GetSelector(serverType).Select(servers, ctx)
+}
+
+// 释放1次选择结果（选择器未实现 IReleaser 时，不做处理）
+func (this *Router) Release(serverType string, server *config.TServerInfo) {
+	if r, ok := this.GetSelector(serverType).(IReleaser); ok {
+		r.Release(server)
+	}
+}
@@ -0,0 +1,198 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 常用服务器选择器
+
+package route
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zpab123/sco/config" // 配置管理
+	"github.com/zpab123/syncutil"   // 原子变量
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// RoundRobinSelector 对象
+
+// 轮询选择器
+type RoundRobinSelector struct {
+	index syncutil.AtomicUint32 // 选择次数
+}
+
+// 新建1个 RoundRobinSelector
+func NewRoundRobinSelector() *RoundRobinSelector {
+	return &RoundRobinSelector{}
+}
+
+// 选择服务器 [ISelector 接口]
+func (this *RoundRobinSelector) Select(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo {
+	idx := this.index.Add(1) - 1
+
+	return servers[idx%uint32(len(servers))]
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// RandomSelector 对象
+
+// 随机选择器
+type RandomSelector struct {
+}
+
+// 新建1个 RandomSelector
+func NewRandomSelector() *RandomSelector {
+	return &RandomSelector{}
+}
+
+// 选择服务器 [ISelector 接口]
+func (this *RandomSelector) Select(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo {
+	return servers[rand.Intn(len(servers))]
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// LeastConnSelector 对象
+
+// 最少连接选择器：选择当前使用数最少的服务器，使用完毕后需要 Release
+type LeastConnSelector struct {
+	mutex  sync.Mutex     // counts 互斥锁
+	counts map[string]int // 服务器名字 -> 使用数
+}
+
+// 新建1个 LeastConnSelector
+func NewLeastConnSelector() *LeastConnSelector {
+	sel := &LeastConnSelector{
+		counts: map[string]int{},
+	}
+
+	return sel
+}
+
+// 选择服务器 [ISelector 接口]
+func (this *LeastConnSelector) Select(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	best := servers[0]
+	for _, info := range servers[1:] {
+		if this.counts[info.Name] < this.counts[best.Name] {
+			best = info
+		}
+	}
+
+	this.counts[best.Name]++
+
+	return best
+}
+
+// 释放1次选择结果 [IReleaser 接口]
+func (this *LeastConnSelector) Release(server *config.TServerInfo) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.counts[server.Name] > 0 {
+		this.counts[server.Name]--
+	}
+}
+
+// 获取服务器当前使用数
+func (this *LeastConnSelector) GetCount(name string) int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.counts[name]
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// HashSelector 对象
+
+// 一致性哈希选择器：相同 key 总是选择相同的服务器，服务器增减时只影响少量 key
+type HashSelector struct {
+	keyFunc KeyFunc                        // 哈希 key 函数
+	mutex   sync.Mutex                     // 哈希环互斥锁
+	sign    string                         // 当前哈希环对应的服务器名字签名
+	ring    []uint32                       // 哈希环（有序）
+	nodes   map[uint32]string              // 哈希值 -> 服务器名字
+	infos   map[string]*config.TServerInfo // 服务器名字 -> 最新的服务器信息
+}
+
+// 新建1个 HashSelector
+//
+// keyFunc=哈希 key 函数，nil=使用 TRouteCtx.Key，为空时使用 session id
+func NewHashSelector(keyFunc KeyFunc) *HashSelector {
+	if nil == keyFunc {
+		keyFunc = defaultKey
+	}
+
+	sel := &HashSelector{
+		keyFunc: keyFunc,
+	}
+
+	return sel
+}
+
+// 选择服务器 [ISelector 接口]
+func (this *HashSelector) Select(servers []*config.TServerInfo, ctx *TRouteCtx) *config.TServerInfo {
+	hash := crc32.ChecksumIEEE([]byte(this.keyFunc(ctx)))
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.build(servers)
+
+	idx := sort.Search(len(this.ring), func(i int) bool {
+		return this.ring[i] >= hash
+	})
+	if idx == len(this.ring) {
+		idx = 0
+	}
+
+	return this.infos[this.nodes[this.ring[idx]]]
+}
+
+// 更新服务器信息；服务器名字列表变化时，重建哈希环
+//
+// 哈希环只与服务器名字有关：同名服务器地址变化时，key 仍选择该服务器，并返回其最新信息
+func (this *HashSelector) build(servers []*config.TServerInfo) {
+	// 最新信息与签名
+	this.infos = make(map[string]*config.TServerInfo, len(servers))
+	names := make([]string, 0, len(servers))
+	for _, info := range servers {
+		this.infos[info.Name] = info
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	sign := strings.Join(names, ",")
+
+	if sign == this.sign {
+		return
+	}
+
+	// 重建
+	this.sign = sign
+	this.ring = make([]uint32, 0, len(servers)*C_HASH_REPLICAS)
+	this.nodes = make(map[uint32]string, len(servers)*C_HASH_REPLICAS)
+
+	for _, info := range servers {
+		for i := 0; i < C_HASH_REPLICAS; i++ {
+			hash := crc32.ChecksumIEEE([]byte(info.Name + "#" + strconv.Itoa(i)))
+			this.ring = append(this.ring, hash)
+			this.nodes[hash] = info.Name
+		}
+	}
+
+	sort.Slice(this.ring, func(i, j int) bool {
+		return this.ring[i] < this.ring[j]
+	})
+}
+
+// 默认哈希 key：TRouteCtx.Key，为空时使用 session id
+func defaultKey(ctx *TRouteCtx) string {
+	if "" != ctx.Key {
+		return ctx.Key
+	}
+
+	return strconv.FormatInt(ctx.SessionId, 10)
+}
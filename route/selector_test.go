@@ -0,0 +1,150 @@
+package route
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zpab123/sco/config" // 配置管理
+)
+
+// 创建 n 个测试用服务器信息
+func newTestServers(n int) []*config.TServerInfo {
+	servers := make([]*config.TServerInfo, 0, n)
+	for i := 0; i < n; i++ {
+		servers = append(servers, &config.TServerInfo{
+			Name: fmt.Sprintf("room_%d", i+1),
+			Host: "127.0.0.1",
+			Port: uint(7000 + i),
+		})
+	}
+
+	return servers
+}
+
+// 各选择器的分布：每个服务器都会被选中，且选择次数不低于 min
+func TestSelectorDistribution(t *testing.T) {
+	const servers, selects = 4, 4000
+
+	tests := []struct {
+		name string                 // 用例名字
+		sel  ISelector              // 选择器
+		min  int                    // 每个服务器最少的选择次数
+		ctx  func(i int) *TRouteCtx // 第 i 次选择的路由上下文
+	}{
+		{"round robin", NewRoundRobinSelector(), selects / servers, nil},
+		{"random", NewRandomSelector(), selects / servers / 2, nil},
+		{"hash by key", NewHashSelector(nil), selects / servers / 3, func(i int) *TRouteCtx {
+			return &TRouteCtx{Key: fmt.Sprintf("uid_%d", i)}
+		}},
+		{"hash by session", NewHashSelector(nil), selects / servers / 3, func(i int) *TRouteCtx {
+			return &TRouteCtx{SessionId: int64(i + 1)}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := newTestServers(servers)
+			counts := map[string]int{}
+
+			for i := 0; i < selects; i++ {
+				ctx := &TRouteCtx{}
+				if nil != tt.ctx {
+					ctx = tt.ctx(i)
+				}
+
+				counts[tt.sel.Select(list, ctx).Name]++
+			}
+
+			for _, info := range list {
+				if counts[info.Name] < tt.min {
+					t.Errorf("服务器选择次数过少。server=%s，got=%d，min=%d，counts=%v", info.Name, counts[info.Name], tt.min, counts)
+				}
+			}
+		})
+	}
+}
+
+// 最少连接选择器：选择使用数最少的服务器，Release 后可再次被选中
+func TestLeastConnSelector(t *testing.T) {
+	sel := NewLeastConnSelector()
+	list := newTestServers(3)
+
+	// 依次选中3个不同的服务器
+	picked := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		picked[sel.Select(list, &TRouteCtx{}).Name] = true
+	}
+
+	if 3 != len(picked) {
+		t.Fatalf("应依次选择不同的服务器。got=%v", picked)
+	}
+
+	// 释放1个后，下次选择该服务器
+	sel.Release(list[1])
+	if got := sel.Select(list, &TRouteCtx{}); got != list[1] {
+		t.Errorf("应选择使用数最少的服务器。got=%s，want=%s", got.Name, list[1].Name)
+	}
+
+	if got := sel.GetCount(list[1].Name); 1 != got {
+		t.Errorf("使用数错误。got=%d，want=1", got)
+	}
+}
+
+// 一致性哈希选择器：相同 key 总是选择相同的服务器；服务器离开时，只有它上面的 key 重新选择
+func TestHashSelectorSticky(t *testing.T) {
+	sel := NewHashSelector(nil)
+	list := newTestServers(4)
+
+	const keys = 1000
+	before := map[string]string{}
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("uid_%d", i)
+		name := sel.Select(list, &TRouteCtx{Key: key}).Name
+
+		// 重复选择结果不变
+		if again := sel.Select(list, &TRouteCtx{Key: key}).Name; again != name {
+			t.Fatalf("相同 key 选择了不同的服务器。key=%s，first=%s，again=%s", key, name, again)
+		}
+
+		before[key] = name
+	}
+
+	// room_2 离开
+	removed := list[1].Name
+	rest := []*config.TServerInfo{list[0], list[2], list[3]}
+	for key, name := range before {
+		got := sel.Select(rest, &TRouteCtx{Key: key}).Name
+		if name != removed && got != name {
+			t.Errorf("服务器离开后，其他服务器上的 key 不应变化。key=%s，before=%s，after=%s", key, name, got)
+		}
+
+		if got == removed {
+			t.Errorf("选择了已离开的服务器。key=%s", key)
+		}
+	}
+}
+
+// 一致性哈希选择器：同名服务器地址变化时，仍选择该服务器，并返回其最新信息
+func TestHashSelectorAddressChange(t *testing.T) {
+	sel := NewHashSelector(nil)
+	list := newTestServers(3)
+	ctx := &TRouteCtx{Key: "uid_1"}
+
+	old := sel.Select(list, ctx)
+
+	// 复制列表，替换为新地址
+	moved := make([]*config.TServerInfo, 0, len(list))
+	var want *config.TServerInfo
+	for _, info := range list {
+		if info.Name == old.Name {
+			info = &config.TServerInfo{Name: info.Name, Host: "127.0.0.2", Port: info.Port + 100}
+			want = info
+		}
+
+		moved = append(moved, info)
+	}
+
+	if got := sel.Select(moved, ctx); got != want {
+		t.Errorf("应返回最新的服务器信息。got=%+v，want=%+v", *got, *want)
+	}
+}
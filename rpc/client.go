@@ -0,0 +1,248 @@
+// /////////////////////////////////////////////////////////////////////////////
+// rpc 客户端
+
+package rpc
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"                       // 异常
+	"github.com/zpab123/sco/config"               // 配置管理
+	"github.com/zpab123/sco/discovery"            // 服务发现
+	"github.com/zpab123/sco/network"              // 网络
+	"github.com/zpab123/sco/protocol"             // 通信协议
+	"github.com/zpab123/sco/route"                // 路由
+	"github.com/zpab123/sco/scoerr"               // 异常
+	"github.com/zpab123/zaplog"                   // log 日志库
+	"google.golang.org/grpc"                      // grpc
+	"google.golang.org/grpc/credentials/insecure" // grpc 非加密连接
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// RpcClient 对象
+
+// rpc 客户端：维护到集群中其他服务器的 grpc 连接池
+type RpcClient struct {
+	source    string                      // 本服务器名字
+	option    *TRpcOpt                    // 配置参数
+	router    *route.Router               // 服务器路由
+	discovery discovery.IDiscovery        // 服务发现，nil=使用 servers.json
+	mutex     sync.Mutex                  // conns 互斥锁
+	conns     map[string]*grpc.ClientConn // rpc 地址 -> grpc 连接
+}
+
+// 新建1个 RpcClient
+//
+// source=本服务器名字；router=服务器路由，nil=所有服务器类型使用轮询选择
+func NewRpcClient(source string, opt *TRpcOpt, router *route.Router) *RpcClient {
+	if nil == opt {
+		opt = NewTRpcOpt()
+	}
+
+	if nil == router {
+		router = route.NewRouter()
+	}
+
+	rc := &RpcClient{
+		source: source,
+		option: opt,
+		router: router,
+		conns:  map[string]*grpc.ClientConn{},
+	}
+
+	return rc
+}
+
+// 设置服务发现：rpc 调用时，从服务发现中选择服务器（需在调用前设置）
+//
+// 服务器离开集群时，关闭到它的 grpc 连接
+func (this *RpcClient) SetDiscovery(d discovery.IDiscovery) {
+	this.discovery = d
+
+	if nil != d {
+		d.AddListener(this.onClusterEvent)
+	}
+}
+
+// 调用1个远程方法
+//
+// route=路由，格式：服务器类型.服务名.方法名；根据路由中的服务器类型，在服务发现（未设置时为 servers.json）中选择服务器
+func (this *RpcClient) Call(ctx context.Context, route string, payload []byte) ([]byte, error) {
+	// 服务器类型
+	serverType := GetServerType(route)
+	list := this.getServers(serverType)
+	if len(list) == 0 {
+		return nil, errors.Errorf("rpc 调用失败：没有 %s 类型的服务器。route=%s", serverType, route)
+	}
+
+	// 选择服务器
+	info := this.router.Select(serverType, list, newRouteCtx(ctx))
+	if nil == info {
+		return nil, errors.Errorf("rpc 调用失败：%s 类型的服务器选择器没有选择服务器。route=%s", serverType, route)
+	}
+	defer this.router.Release(serverType, info)
+
+	return this.CallServer(ctx, info, route, payload)
+}
+
+// 调用1个远程方法，并完成请求/回复的编解码
+//
+// req=请求结构体指针；res=回复结构体指针，可以为 nil
+func (this *RpcClient) Invoke(ctx context.Context, route string, req interface{}, res interface{}) error {
+	payload, err := encode(req)
+	if nil != err {
+		return errors.Wrap(err, "编码 rpc 请求失败")
+	}
+
+	data, err := this.Call(ctx, route, payload)
+	if nil != err {
+		return err
+	}
+
+	if nil == res {
+		return nil
+	}
+
+	return decode(data, res)
+}
+
+// 调用指定服务器的远程方法，并完成请求/回复的编解码
+//
+// req=请求结构体指针；res=回复结构体指针，可以为 nil
+func (this *RpcClient) InvokeServer(ctx context.Context, info *config.TServerInfo, route string, req interface{}, res interface{}) error {
+	payload, err := encode(req)
+	if nil != err {
+		return errors.Wrap(err, "编码 rpc 请求失败")
+	}
+
+	data, err := this.CallServer(ctx, info, route, payload)
+	if nil != err {
+		return err
+	}
+
+	if nil == res {
+		return nil
+	}
+
+	return decode(data, res)
+}
+
+// 调用指定服务器的远程方法
+func (this *RpcClient) CallServer(ctx context.Context, info *config.TServerInfo, route string, payload []byte) ([]byte, error) {
+	// 连接
+	conn, err := this.getConn(GetRpcAddr(info))
+	if nil != err {
+		return nil, err
+	}
+
+	// 超时
+	if this.option.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, this.option.Timeout)
+		defer cancel()
+	}
+
+	// 请求
+	req := &protocol.GrpcRequest{
+		Route:   route,
+		Payload: payload,
+		Session: GetSession(ctx),
+		Source:  this.source,
+	}
+
+	res, err := protocol.NewScoGrpcClient(conn).Call(ctx, req)
+	if nil != err {
+		return nil, err
+	}
+
+	if res.Code != protocol.C_CODE_OK {
+		return nil, scoerr.NewCodeError(res.Code, res.Msg)
+	}
+
+	return res.Payload, nil
+}
+
+// 关闭所有连接
+func (this *RpcClient) Close() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for addr, conn := range this.conns {
+		conn.Close()
+
+		delete(this.conns, addr)
+	}
+}
+
+// 获取 serverType 类型的服务器列表
+func (this *RpcClient) getServers(serverType string) []*config.TServerInfo {
+	if nil != this.discovery {
+		return this.discovery.GetServers(serverType)
+	}
+
+	return config.GetServerMap()[serverType]
+}
+
+// 根据 ctx 创建路由上下文
+func newRouteCtx(ctx context.Context) *route.TRouteCtx {
+	rctx := &route.TRouteCtx{
+		Key: GetRouteKey(ctx),
+	}
+
+	if ses := GetSession(ctx); nil != ses {
+		rctx.SessionId = ses.Id
+
+		if "" == rctx.Key {
+			rctx.Key = ses.Uid
+		}
+	}
+
+	return rctx
+}
+
+// 获取 rpc 地址对应的 grpc 连接，不存在则创建
+func (this *RpcClient) getConn(addr string) (*grpc.ClientConn, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if conn, ok := this.conns[addr]; ok {
+		return conn, nil
+	}
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return network.Dial(addr)
+	}
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithContextDialer(dialer))
+	if nil != err {
+		return nil, err
+	}
+
+	this.conns[addr] = conn
+
+	zaplog.Debugf("RpcClient 创建连接。addr=%s", addr)
+
+	return conn, nil
+}
+
+// 服务器离开集群时，关闭并移除到它的 grpc 连接
+func (this *RpcClient) onClusterEvent(evt *discovery.TEvent) {
+	if discovery.C_EVENT_REMOVE != evt.Type || nil == evt.Info {
+		return
+	}
+
+	addr := GetRpcAddr(evt.Info)
+
+	this.mutex.Lock()
+	conn, ok := this.conns[addr]
+	delete(this.conns, addr)
+	this.mutex.Unlock()
+
+	if ok {
+		conn.Close()
+
+		zaplog.Debugf("RpcClient 服务器离开集群，关闭连接。addr=%s", addr)
+	}
+}
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/zpab123/sco/config"       // 配置管理
+	"github.com/zpab123/sco/discovery"    // 服务发现
+	"google.golang.org/grpc/connectivity" // grpc 连接状态
+)
+
+// 服务器离开集群时，关闭并移除到它的连接；其他事件不影响连接
+func TestClientEvictOnRemove(t *testing.T) {
+	info := &config.TServerInfo{Name: "room_1", Host: "127.0.0.1", Port: 18651}
+
+	tests := []struct {
+		name    string // 用例名字
+		evtType uint32 // 事件类型
+		evicted bool   // 连接是否被移除
+	}{
+		{"add", discovery.C_EVENT_ADD, false},
+		{"update", discovery.C_EVENT_UPDATE, false},
+		{"remove", discovery.C_EVENT_REMOVE, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewRpcClient("gate_1", nil, nil)
+			defer c.Close()
+
+			conn, err := c.getConn(GetRpcAddr(info))
+			if nil != err {
+				t.Fatal(err)
+			}
+
+			c.onClusterEvent(&discovery.TEvent{Type: tt.evtType, ServerType: "room", Info: info})
+
+			c.mutex.Lock()
+			_, ok := c.conns[GetRpcAddr(info)]
+			c.mutex.Unlock()
+
+			if ok == tt.evicted {
+				t.Errorf("连接移除错误。evicted=%v，want=%v", !ok, tt.evicted)
+			}
+
+			if closed := connectivity.Shutdown == conn.GetState(); closed != tt.evicted {
+				t.Errorf("连接关闭错误。closed=%v，want=%v", closed, tt.evicted)
+			}
+		})
+	}
+}
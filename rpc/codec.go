@@ -0,0 +1,35 @@
+// /////////////////////////////////////////////////////////////////////////////
+// rpc 数据编解码
+
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto" // protobuf
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 编码：proto.Message 使用 protobuf，其他使用 json
+func encode(v interface{}) ([]byte, error) {
+	if pm, ok := v.(proto.Message); ok {
+		return proto.Marshal(pm)
+	}
+
+	return json.Marshal(v)
+}
+
+// 解码：proto.Message 使用 protobuf，其他使用 json
+func decode(data []byte, v interface{}) error {
+	if pm, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, pm)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,53 @@
+// /////////////////////////////////////////////////////////////////////////////
+// rpc 上下文
+
+package rpc
+
+import (
+	"context"
+
+	"github.com/zpab123/sco/protocol" // 通信协议
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 包初始化
+
+// context key
+type ctxKey int
+
+// context key
+const (
+	_CTX_KEY_SESSION   ctxKey = iota // 客户端 session 上下文
+	_CTX_KEY_ROUTE_KEY               // 路由 key
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 将客户端 session 上下文存入 ctx，rpc 调用时会随请求发送给远程服务器
+func WithSession(ctx context.Context, ses *protocol.GrpcSession) context.Context {
+	return context.WithValue(ctx, _CTX_KEY_SESSION, ses)
+}
+
+// 从 ctx 中获取客户端 session 上下文
+//
+// 返回 nil=不存在
+func GetSession(ctx context.Context) *protocol.GrpcSession {
+	ses, _ := ctx.Value(_CTX_KEY_SESSION).(*protocol.GrpcSession)
+
+	return ses
+}
+
+// 将路由 key 存入 ctx，用于一致性哈希等选择器选择服务器
+func WithRouteKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, _CTX_KEY_ROUTE_KEY, key)
+}
+
+// 从 ctx 中获取路由 key
+//
+// 返回 ""=不存在
+func GetRouteKey(ctx context.Context) string {
+	key, _ := ctx.Value(_CTX_KEY_ROUTE_KEY).(string)
+
+	return key
+}
@@ -0,0 +1,74 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 常量-接口-types
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zpab123/sco/config" // 配置管理
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 常量
+
+// rpc 常量
+const (
+	C_CMPT_NAME = "rpcserver"     // 组件名字
+	C_TIMEOUT   = 5 * time.Second // rpc 调用默认超时时间
+	C_STOP_TIME = 5 * time.Second // 未设置停止期限时，停止 rpc 服务等待请求处理完成的最长时间
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 接口
+
+// 远程方法处理函数：payload=请求数据，返回回复数据
+type HandlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+// /////////////////////////////////////////////////////////////////////////////
+// TRpcOpt 对象
+
+// rpc 配置参数
+type TRpcOpt struct {
+	Enable  bool          // 是否启动 rpc 服务
+	Timeout time.Duration // rpc 调用超时时间
+}
+
+// 创建1个新的 TRpcOpt
+func NewTRpcOpt() *TRpcOpt {
+	opt := &TRpcOpt{
+		Enable:  false,
+		Timeout: C_TIMEOUT,
+	}
+
+	return opt
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 获取服务器的 rpc 地址
+//
+// 未设置 RpcPort 时使用 Port；Port 同时被网络服务侦听时，app 启动失败
+func GetRpcAddr(info *config.TServerInfo) string {
+	port := info.RpcPort
+	if port == 0 {
+		port = info.Port
+	}
+
+	return fmt.Sprintf("%s:%d", info.Host, port)
+}
+
+// 获取路由中的服务器类型
+//
+// route=路由，格式：服务器类型.服务名.方法名
+func GetServerType(route string) string {
+	if idx := strings.Index(route, "."); idx > 0 {
+		return route[:idx]
+	}
+
+	return route
+}
@@ -0,0 +1,127 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 通过结构体方法注册远程服务
+
+package rpc
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors" // 异常
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 包初始化
+
+// 变量
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem() // context.Context 类型
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()           // error 类型
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// RpcServer 远程服务
+
+// 注册1个远程服务对象
+//
+// svc 中签名为 func(ctx context.Context, req *Req) (*Resp, error) 的导出方法，
+// 将注册为远程方法，路由为：serverType.结构体名.方法名；存在其他签名的导出方法时，返回 error
+func (this *RpcServer) Register(serverType string, svc interface{}) ([]string, error) {
+	// 参数效验
+	if "" == serverType {
+		return nil, errors.New("注册远程服务失败：参数 serverType 为空")
+	}
+
+	typ := reflect.TypeOf(svc)
+	val := reflect.ValueOf(svc)
+	if nil == svc || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("注册远程服务失败：%T 不是结构体指针", svc)
+	}
+
+	svcName := typ.Elem().Name()
+	if typ.NumMethod() == 0 {
+		return nil, errors.Errorf("注册远程服务 %s 失败：没有导出方法", svcName)
+	}
+
+	// 解析方法
+	routes := make([]string, 0, typ.NumMethod())
+	handlers := make([]HandlerFunc, 0, typ.NumMethod())
+
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i)
+
+		if err := checkRemoteMethod(method); nil != err {
+			return nil, errors.Errorf("注册远程服务 %s 失败：方法 %s 签名错误，%s。正确签名=func(context.Context, *Req) (*Resp, error)", svcName, method.Name, err)
+		}
+
+		routes = append(routes, serverType+"."+svcName+"."+method.Name)
+		handlers = append(handlers, newRemoteHandler(val.Method(i), method.Type.In(2).Elem()))
+	}
+
+	// 注册
+	for i, route := range routes {
+		this.Handle(route, handlers[i])
+	}
+
+	return routes, nil
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 检查远程方法签名：func(receiver, context.Context, *Req) (*Resp, error)
+func checkRemoteMethod(method reflect.Method) error {
+	mt := method.Type
+
+	if mt.NumIn() != 3 {
+		return errors.Errorf("参数数量=%d，应为2", mt.NumIn()-1)
+	}
+
+	if mt.In(1) != typeOfContext {
+		return errors.Errorf("第1个参数类型=%s，应为 context.Context", mt.In(1))
+	}
+
+	if mt.In(2).Kind() != reflect.Ptr {
+		return errors.Errorf("第2个参数类型=%s，应为指针", mt.In(2))
+	}
+
+	if mt.NumOut() != 2 {
+		return errors.Errorf("返回值数量=%d，应为2", mt.NumOut())
+	}
+
+	if mt.Out(0).Kind() != reflect.Ptr {
+		return errors.Errorf("第1个返回值类型=%s，应为指针", mt.Out(0))
+	}
+
+	if mt.Out(1) != typeOfError {
+		return errors.Errorf("第2个返回值类型=%s，应为 error", mt.Out(1))
+	}
+
+	return nil
+}
+
+// 根据反射结果，创建远程方法处理函数
+//
+// fn=绑定了接收者的方法；reqType=请求结构体类型
+func newRemoteHandler(fn reflect.Value, reqType reflect.Type) HandlerFunc {
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		// 解码请求
+		req := reflect.New(reqType)
+		if err := decode(payload, req.Interface()); nil != err {
+			return nil, errors.Wrap(err, "解码 rpc 请求失败")
+		}
+
+		// 调用
+		outs := fn.Call([]reflect.Value{reflect.ValueOf(ctx), req})
+		if err, _ := outs[1].Interface().(error); nil != err {
+			return nil, err
+		}
+
+		if outs[0].IsNil() {
+			return nil, nil
+		}
+
+		// 编码回复
+		return encode(outs[0].Interface())
+	}
+}
@@ -0,0 +1,231 @@
+// /////////////////////////////////////////////////////////////////////////////
+// rpc 服务组件
+
+package rpc
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/model"    // 全局模型
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/sco/scoerr"   // 异常
+	"github.com/zpab123/sco/state"    // 状态管理
+	"github.com/zpab123/syncutil"     // 原子变量
+	"github.com/zpab123/zaplog"       // log 日志库
+	"google.golang.org/grpc"          // grpc
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// RpcServer 组件
+
+// rpc 服务组件：基于 ScoGrpc 服务，接收其他服务器的 rpc 请求
+type RpcServer struct {
+	cmptName    string                 // 组件名字
+	laddr       string                 // 监听地址
+	stateMgr    *state.StateManager    // 状态管理
+	server      *grpc.Server           // grpc 服务器
+	mutex       sync.RWMutex           // handlers、deadline 读写锁
+	handlers    map[string]HandlerFunc // 路由 -> 处理函数
+	deadline    time.Time              // 停止期限：超过后强制关闭，零值=C_STOP_TIME
+	stopGroup   sync.WaitGroup         // 停止等待组
+	panicPolicy syncutil.AtomicUint32  // 处理函数出现 panic 时的处理策略
+	panicCount  syncutil.AtomicInt64   // 处理函数出现 panic 的次数
+}
+
+// 新建1个 RpcServer
+//
+// laddr=监听地址，格式 192.168.1.1:8600
+func NewRpcServer(laddr string) *RpcServer {
+	st := state.NewStateManager()
+
+	rs := &RpcServer{
+		cmptName: C_CMPT_NAME,
+		laddr:    laddr,
+		stateMgr: st,
+		handlers: map[string]HandlerFunc{},
+	}
+
+	rs.stateMgr.SetState(state.C_INIT)
+
+	return rs
+}
+
+// 启动 RpcServer [IComponent 接口]
+func (this *RpcServer) Run(ctx context.Context) error {
+	// 状态效验
+	if !this.stateMgr.CompareAndSwap(state.C_INIT, state.C_RUNING) {
+		if !this.stateMgr.CompareAndSwap(state.C_STOPED, state.C_RUNING) {
+			return errors.Errorf("RpcServer 组件启动失败，状态错误。当前状态=%d，正确状态=%d或=%d", this.stateMgr.GetState(), state.C_INIT, state.C_STOPED)
+		}
+	}
+
+	// 侦听
+	listener, err := network.Listen(this.laddr)
+	if nil != err {
+		this.stateMgr.SetState(state.C_STOPED)
+
+		return errors.Wrapf(err, "RpcServer 组件启动失败。laddr=%s", this.laddr)
+	}
+
+	// grpc 服务
+	this.server = grpc.NewServer()
+	protocol.RegisterScoGrpcServer(this.server, this)
+
+	this.stopGroup.Add(1)
+	go func() {
+		defer this.stopGroup.Done()
+
+		if err := this.server.Serve(listener); nil != err {
+			zaplog.Debugf("RpcServer 停止服务。laddr=%s，err=%s", this.laddr, err)
+		}
+	}()
+
+	this.stateMgr.SetState(state.C_WORKING)
+
+	zaplog.Infof("RpcServer 组件启动成功。laddr=%s", this.laddr)
+
+	return nil
+}
+
+// 停止 RpcServer：等待进行中的请求处理完成，超过停止期限后强制关闭 [IComponent 接口]
+func (this *RpcServer) Stop() {
+	if !this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_STOPING) {
+		zaplog.Errorf("RpcServer 组件停止失败，状态错误。当前状态=%d，正确状态=%d", this.stateMgr.GetState(), state.C_WORKING)
+
+		return
+	}
+
+	this.mutex.RLock()
+	deadline := this.deadline
+	this.mutex.RUnlock()
+
+	if deadline.IsZero() {
+		deadline = time.Now().Add(C_STOP_TIME)
+	}
+
+	// 处理函数阻塞时，GracefulStop 会一直等待；强制关闭后不再等待其返回，阻塞的处理函数返回后 goroutine 退出
+	done := make(chan struct{})
+	go func() {
+		this.server.GracefulStop()
+		close(done)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		zaplog.Warnf("RpcServer 等待请求处理超时，强制关闭。laddr=%s", this.laddr)
+
+		this.server.Stop()
+	}
+
+	this.stopGroup.Wait()
+
+	this.stateMgr.SetState(state.C_STOPED)
+
+	zaplog.Infof("RpcServer 组件停止成功")
+}
+
+// 获取组件名字 [IComponent 接口]
+func (this *RpcServer) Name() string {
+	return this.cmptName
+}
+
+// 设置停止期限：Stop 等待进行中的请求处理完成，超过期限后强制关闭（需在 Stop 之前调用）
+func (this *RpcServer) SetStopDeadline(deadline time.Time) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.deadline = deadline
+}
+
+// 设置处理函数出现 panic 时的处理策略（默认为 model.C_PANIC_DROP）
+//
+// rpc 请求没有所属的 session，C_PANIC_CLOSE 与 C_PANIC_DROP 相同：向调用方返回错误
+func (this *RpcServer) SetPanicPolicy(policy uint32) {
+	this.panicPolicy.Store(policy)
+}
+
+// 获取处理函数出现 panic 的次数
+func (this *RpcServer) GetPanicCount() int64 {
+	return this.panicCount.Load()
+}
+
+// 注册1个远程方法
+//
+// route=路由，格式：服务器类型.服务名.方法名
+func (this *RpcServer) Handle(route string, fn HandlerFunc) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, ok := this.handlers[route]; ok {
+		zaplog.Warnf("RpcServer 远程方法重复注册，新方法将覆盖旧方法。route=%s", route)
+	}
+
+	this.handlers[route] = fn
+}
+
+// 处理1个 rpc 请求 [ScoGrpcServer 接口]
+func (this *RpcServer) Call(ctx context.Context, req *protocol.GrpcRequest) (*protocol.GrpcResponse, error) {
+	res := &protocol.GrpcResponse{}
+
+	// 查找处理函数
+	this.mutex.RLock()
+	fn, ok := this.handlers[req.Route]
+	this.mutex.RUnlock()
+
+	if !ok {
+		res.Code = protocol.C_CODE_RPC_ROUTE_ERROR
+		res.Msg = errors.Errorf("rpc 路由不存在。route=%s", req.Route).Error()
+
+		return res, nil
+	}
+
+	// 处理请求
+	if nil != req.Session {
+		ctx = WithSession(ctx, req.Session)
+	}
+
+	payload, err := this.handle(ctx, req.Route, fn, req.Payload)
+	if nil != err {
+		res.Code = scoerr.GetErrorCode(err, protocol.C_CODE_ERROR)
+		res.Msg = err.Error()
+
+		return res, nil
+	}
+
+	res.Code = protocol.C_CODE_OK
+	res.Payload = payload
+
+	return res, nil
+}
+
+// 执行处理函数，并捕获处理过程中出现的 panic：panic 作为错误返回给调用方
+func (this *RpcServer) handle(ctx context.Context, route string, fn HandlerFunc, payload []byte) (res []byte, err error) {
+	defer func() {
+		r := recover()
+		if nil == r {
+			return
+		}
+
+		this.panicCount.Add(1)
+		zaplog.Errorf("RpcServer 处理请求出现 panic。route=%s，err=%v\n%s", route, r, debug.Stack())
+
+		if model.C_PANIC_CRASH == this.panicPolicy.Load() {
+			zaplog.Errorf("RpcServer 处理请求异常，panic 策略为 crash，重新抛出 panic")
+			panic(r)
+		}
+
+		res = nil
+		err = errors.Errorf("rpc 处理请求出现 panic。route=%s，err=%v", route, r)
+	}()
+
+	return fn(ctx, payload)
+}
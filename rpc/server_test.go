@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zpab123/sco/model"    // 全局模型
+	"github.com/zpab123/sco/protocol" // 通信协议
+)
+
+// 处理函数出现 panic 时，按 panic 策略返回错误或重新抛出
+func TestCallPanic(t *testing.T) {
+	tests := []struct {
+		name    string // 用例名字
+		policy  uint32 // panic 策略
+		rethrow bool   // 是否重新抛出 panic
+	}{
+		{"drop", model.C_PANIC_DROP, false},
+		{"close", model.C_PANIC_CLOSE, false},
+		{"crash", model.C_PANIC_CRASH, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRpcServer("127.0.0.1:0")
+			s.SetPanicPolicy(tt.policy)
+			s.Handle("room.R.Boom", func(ctx context.Context, payload []byte) ([]byte, error) {
+				panic("boom")
+			})
+
+			var res *protocol.GrpcResponse
+			r := func() (r interface{}) {
+				defer func() {
+					r = recover()
+				}()
+
+				res, _ = s.Call(context.Background(), &protocol.GrpcRequest{Route: "room.R.Boom"})
+
+				return nil
+			}()
+
+			if tt.rethrow {
+				if "boom" != r {
+					t.Errorf("crash 策略应重新抛出 panic。got=%v", r)
+				}
+			} else {
+				if nil != r {
+					t.Fatalf("panic 未被捕获。got=%v", r)
+				}
+
+				if protocol.C_CODE_ERROR != res.Code || "" == res.Msg {
+					t.Errorf("回复错误。code=%d，msg=%q", res.Code, res.Msg)
+				}
+			}
+
+			if 1 != s.GetPanicCount() {
+				t.Errorf("panic 统计次数错误。got=%d，want=1", s.GetPanicCount())
+			}
+		})
+	}
+}
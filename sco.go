@@ -4,19 +4,71 @@
 package sco
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/zpab123/sco/app" // 1个通用服务器库
+	"github.com/zpab123/zaplog"  // log
 )
 
 // /////////////////////////////////////////////////////////////////////////////
 // 对外 api
 
-// 创建1个新的 Application 对象
+// 创建1个新的 Application 对象，并完成初始化
 //
 // appType=server.json 中配置的类型
-func CreateApp(appType string, delegate app.IDelegate) *app.Application {
+func CreateApp(appType string, delegate app.IDelegate) (*app.Application, error) {
 	// 创建 app
-	app := app.NewApplication(appType, delegate)
-	app.Init()
+	a, err := app.NewApplication(appType, delegate)
+	if nil != err {
+		return nil, err
+	}
+
+	if err = a.Init(); nil != err {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// 运行 app 直到收到 SIGINT/SIGTERM，然后停止 app 并退出进程
+//
+// 启动失败、停止失败或停止超时，进程以非0状态码退出
+func Main(a *app.Application) {
+	if err := a.Run(); nil != err {
+		zaplog.Errorf("%s 服务器，启动失败。err=%v", a.GetName(), err)
+
+		os.Exit(1)
+	}
+
+	// 等待结束信号
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigChan
+	signal.Stop(sigChan)
+
+	zaplog.Infof("%s 服务器，收到信号 %s，正在停止 ...", a.GetName(), sig)
+
+	// 停止 app：Stop 超过 StopTimeout 后会强制关闭连接，这里再留出相同时间，防止组件停止卡死
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Stop()
+	}()
+
+	select {
+	case err := <-done:
+		if nil != err {
+			zaplog.Errorf("%s 服务器，停止失败。err=%v", a.GetName(), err)
+
+			os.Exit(1)
+		}
+	case <-time.After(2 * a.Option.StopTimeout):
+		zaplog.Errorf("%s 服务器，停止超时，强制退出", a.GetName())
+
+		os.Exit(1)
+	}
 
-	return app
+	os.Exit(0)
 }
@@ -4,6 +4,7 @@
 package scoerr
 
 import (
+	"fmt"
 	"io"
 	"net"
 
@@ -70,3 +71,38 @@ func IsConnectionError(_err interface{}) bool {
 
 	return true
 }
+
+// /////////////////////////////////////////////////////////////////////////////
+// CodeError 对象
+
+// 带有消息码的错误
+type CodeError struct {
+	Code uint32 // 消息码
+	Msg  string // 错误描述
+}
+
+// 创建1个新的 CodeError
+func NewCodeError(code uint32, msg string) *CodeError {
+	err := &CodeError{
+		Code: code,
+		Msg:  msg,
+	}
+
+	return err
+}
+
+// 错误信息 [error 接口]
+func (this *CodeError) Error() string {
+	return fmt.Sprintf("code=%d, msg=%s", this.Code, this.Msg)
+}
+
+// 获取 err 中的消息码
+//
+// err 不是 CodeError 时，返回 defCode
+func GetErrorCode(err error, defCode uint32) uint32 {
+	if ce, ok := errors.Cause(err).(*CodeError); ok {
+		return ce.Code
+	}
+
+	return defCode
+}
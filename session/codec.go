@@ -0,0 +1,65 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 推送消息编码
+
+package session
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto" // protobuf
+	"github.com/zpab123/sco/network"   // 网络
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 编码：[]byte 原样返回，proto.Message 使用 protobuf，其他使用 json
+func encodeMsg(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return m, nil
+	case proto.Message:
+		return proto.Marshal(m)
+	}
+
+	return json.Marshal(v)
+}
+
+// 创建1个消息 packet
+func newMsgPacket(mid uint16, data []byte) *network.Packet {
+	pkt := network.NewPacket(mid)
+	if len(data) > 0 {
+		pkt.AppendBytes(data)
+	}
+
+	return pkt
+}
+
+// 将同1个消息 packet 发送给多个 session：所有 session 共享1个 buffer，各持有1次引用
+//
+// 返回发送成功的 session 数量
+func sendShared(sessions []ISession, mid uint16, data []byte) int {
+	if len(sessions) == 0 {
+		return 0
+	}
+
+	pkt := newMsgPacket(mid, data)
+	defer pkt.Release()
+
+	count := 0
+	for _, ses := range sessions {
+		pkt.Retain()
+
+		if err := ses.SendPacket(pkt); nil != err {
+			pkt.Release()
+
+			continue
+		}
+
+		count++
+	}
+
+	return count
+}
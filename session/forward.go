@@ -0,0 +1,81 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 前端服务器 <-> 后端服务器 消息转发
+
+package session
+
+import (
+	"github.com/pkg/errors"          // 异常
+	"github.com/zpab123/sco/network" // 网络
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 常量
+
+const (
+	_WRAP_HEAD_LEN = 10 // 转发消息头长度：客户端 session id(8字节) + 原消息 mid(2字节)
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 将客户端 packet 封装为转发 packet
+//
+// wrapMid=转发消息id（C_PKT_ID_FORWARD 或 C_PKT_ID_BACKWARD）；clientSesId=客户端 session id
+func WrapPacket(wrapMid uint16, clientSesId int64, pkt *network.Packet) *network.Packet {
+	wp := network.NewPacket(wrapMid)
+	wp.AppendUint64(uint64(clientSesId))
+	wp.AppendUint16(pkt.GetMid())
+	wp.AppendBytes(pkt.GetBody())
+
+	return wp
+}
+
+// 将转发 packet 还原为客户端 session id 和 客户端 packet
+func UnwrapPacket(wp *network.Packet) (int64, *network.Packet, error) {
+	if wp.GetBodyLen() < _WRAP_HEAD_LEN {
+		err := errors.Errorf("解析转发消息失败：body 长度=%d，小于消息头长度=%d", wp.GetBodyLen(), _WRAP_HEAD_LEN)
+
+		return 0, nil, err
+	}
+
+	sesId := int64(wp.ReadUint64())
+	mid := wp.ReadUint16()
+	body := wp.ReadBytes(wp.GetBodyLen() - _WRAP_HEAD_LEN)
+
+	pkt := network.NewPacket(mid)
+	pkt.AppendBytes(body)
+
+	return sesId, pkt, nil
+}
+
+// 连接1个服务器，握手成功后，创建并启动 ServerSession
+//
+// addr=服务器地址，格式 192.168.1.1:8600
+func DialServerSession(addr string, mgr ISessionManage, handler IServerMsgHandler, opt *TServerSessionOpt) (*ServerSession, error) {
+	// 连接
+	socket, err := network.DialWs(addr)
+	if nil != err {
+		return nil, err
+	}
+
+	// 创建 session
+	ses, err := NewServerSession(socket, mgr, handler, opt)
+	if nil != err {
+		socket.Close()
+
+		return nil, err
+	}
+	ss := ses.(*ServerSession)
+
+	// 握手
+	if err = ss.session.scoConn.Handshake(); nil != err {
+		socket.Close()
+
+		return nil, err
+	}
+
+	// 启动
+	go ss.Run()
+
+	return ss, nil
+}
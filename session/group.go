@@ -0,0 +1,395 @@
+// /////////////////////////////////////////////////////////////////////////////
+// session 组：房间、公会、聊天频道等组播消息
+
+package session
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"     // 异常
+	"github.com/zpab123/zaplog" // 日志
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// GroupManager 对象
+
+// 组管理：创建/销毁组，本地 session 关闭时自动从所有组中移除
+type GroupManager struct {
+	source string            // 本服务器名字：本地成员的前端服务器名字
+	sesMgr *SessionManager   // 本地 session 管理，nil=没有本地成员
+	remote IGroupRemote      // 远程推送，nil=不支持远程成员
+	mutex  sync.RWMutex      // groups 读写锁
+	groups map[string]*Group // 名字 -> 组
+}
+
+// 新建1个 GroupManager
+//
+// source=本服务器名字
+func NewGroupManager(source string) *GroupManager {
+	gm := &GroupManager{
+		source: source,
+		groups: map[string]*Group{},
+	}
+
+	return gm
+}
+
+// 设置本地 session 管理：本地成员从中查找 session（需在添加成员前设置）
+func (this *GroupManager) SetSessionManager(mgr *SessionManager) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.sesMgr = mgr
+}
+
+// 设置远程推送：向其他前端服务器上的成员组播消息（需在组播前设置）
+func (this *GroupManager) SetRemote(remote IGroupRemote) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.remote = remote
+}
+
+// 获取本服务器名字
+func (this *GroupManager) GetSource() string {
+	return this.source
+}
+
+// 创建1个组
+//
+// 组已存在时返回错误
+func (this *GroupManager) Create(name string) (*Group, error) {
+	if "" == name {
+		return nil, errors.New("创建组失败：参数 name 为空")
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, ok := this.groups[name]; ok {
+		return nil, errors.Errorf("创建组失败：组已存在。name=%s", name)
+	}
+
+	g := &Group{
+		name:    name,
+		mgr:     this,
+		members: map[TMember]ISession{},
+	}
+	this.groups[name] = g
+
+	return g, nil
+}
+
+// 获取1个组，不存在则创建
+func (this *GroupManager) GetOrCreate(name string) (*Group, error) {
+	if g := this.Get(name); nil != g {
+		return g, nil
+	}
+
+	g, err := this.Create(name)
+	if nil != err {
+		// 并发创建：其他调用已创建
+		if g = this.Get(name); nil != g {
+			return g, nil
+		}
+	}
+
+	return g, err
+}
+
+// 根据名字获取组
+//
+// 返回 nil=不存在
+func (this *GroupManager) Get(name string) *Group {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.groups[name]
+}
+
+// 销毁1个组：移除所有成员，销毁后组不能再添加成员
+//
+// 返回 false=组不存在
+func (this *GroupManager) Destroy(name string) bool {
+	this.mutex.Lock()
+	g, ok := this.groups[name]
+	delete(this.groups, name)
+	this.mutex.Unlock()
+
+	if ok {
+		g.destroy()
+	}
+
+	return ok
+}
+
+// 获取组数量
+func (this *GroupManager) GetCount() int {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return len(this.groups)
+}
+
+// 本地 session 关闭，将其从所有组中移除（作为 SessionManager 关闭回调）
+func (this *GroupManager) OnSessionClose(ses ISession, reason CloseReason) {
+	m := TMember{
+		Frontend: this.source,
+		SesId:    ses.GetId(),
+	}
+
+	this.removeMember(m)
+}
+
+// 前端服务器上的客户端 session 关闭，将其从所有组中移除（作为 BackendSessionManager 关闭回调）
+//
+// 远程成员不会等到下次组播才被移除
+func (this *GroupManager) OnBackendSessionClose(bs *BackendSession) {
+	frontend := bs.GetFrontend()
+	if "" == frontend {
+		return
+	}
+
+	m := TMember{
+		Frontend: frontend,
+		SesId:    bs.GetId(),
+	}
+
+	this.removeMember(m)
+}
+
+// 将1个成员从所有组中移除
+func (this *GroupManager) removeMember(m TMember) {
+	this.mutex.RLock()
+	list := make([]*Group, 0, len(this.groups))
+	for _, g := range this.groups {
+		list = append(list, g)
+	}
+	this.mutex.RUnlock()
+
+	for _, g := range list {
+		g.RemoveMember(m)
+	}
+}
+
+// 获取本地 session
+func (this *GroupManager) getSession(sesId int64) ISession {
+	this.mutex.RLock()
+	mgr := this.sesMgr
+	this.mutex.RUnlock()
+
+	if nil == mgr {
+		return nil
+	}
+
+	return mgr.GetSession(sesId)
+}
+
+// 获取远程推送
+func (this *GroupManager) getRemote() IGroupRemote {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.remote
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// Group 对象
+
+// session 组：成员可以是本服务器的 session，也可以是其他前端服务器上的 session
+type Group struct {
+	name      string               // 组名字
+	mgr       *GroupManager        // 所属组管理
+	mutex     sync.RWMutex         // members 读写锁
+	members   map[TMember]ISession // 成员 -> 本地 session；远程成员为 nil
+	destroyed bool                 // 是否已销毁
+}
+
+// 获取组名字
+func (this *Group) Name() string {
+	return this.name
+}
+
+// 添加1个本地 session
+func (this *Group) Add(ses ISession) error {
+	if nil == ses {
+		return errors.Errorf("组[%s]添加成员失败：参数 ses=nil", this.name)
+	}
+
+	m := TMember{
+		Frontend: this.mgr.source,
+		SesId:    ses.GetId(),
+	}
+
+	return this.add(m, ses)
+}
+
+// 添加1个成员：前端服务器为本服务器时，为本地成员，否则为远程成员
+//
+// 本地成员的 session 需存在；远程成员由前端服务器通过 rpc 推送，session 关闭时，
+// 前端服务器的关闭通知到达后立即移除（客户端消息曾转发到本服务器时），否则在下次组播时移除
+func (this *Group) AddMember(m TMember) error {
+	if m.Frontend == "" || m.Frontend == this.mgr.source {
+		ses := this.mgr.getSession(m.SesId)
+		if nil == ses {
+			return errors.Errorf("组[%s]添加成员失败：session 不存在。sesId=%d", this.name, m.SesId)
+		}
+
+		return this.Add(ses)
+	}
+
+	return this.add(m, nil)
+}
+
+// 移除1个本地 session
+//
+// 返回 false=不是组成员
+func (this *Group) Remove(sesId int64) bool {
+	m := TMember{
+		Frontend: this.mgr.source,
+		SesId:    sesId,
+	}
+
+	return this.RemoveMember(m)
+}
+
+// 移除1个成员
+//
+// 返回 false=不是组成员
+func (this *Group) RemoveMember(m TMember) bool {
+	if "" == m.Frontend {
+		m.Frontend = this.mgr.source
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	_, ok := this.members[m]
+	delete(this.members, m)
+
+	return ok
+}
+
+// 是否是组成员
+func (this *Group) Contains(m TMember) bool {
+	if "" == m.Frontend {
+		m.Frontend = this.mgr.source
+	}
+
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	_, ok := this.members[m]
+
+	return ok
+}
+
+// 获取成员数量
+func (this *Group) Count() int {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return len(this.members)
+}
+
+// 获取所有成员
+func (this *Group) Members() []TMember {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	list := make([]TMember, 0, len(this.members))
+	for m := range this.members {
+		list = append(list, m)
+	}
+
+	return list
+}
+
+// 向所有成员组播消息：消息只编码1次，本地成员共享1个 packet，每个前端服务器只调用1次 rpc
+//
+// msg=消息内容，编码方式同 SessionManager.Push；前端服务器上已不存在的远程成员，将被移除
+func (this *Group) Multicast(mid uint16, msg interface{}) error {
+	data, err := encodeMsg(msg)
+	if nil != err {
+		return errors.Wrapf(err, "组[%s]组播消息失败：编码错误。mid=%d", this.name, mid)
+	}
+
+	// 成员快照
+	this.mutex.RLock()
+	locals := make([]ISession, 0, len(this.members))
+	remotes := map[string][]int64{}
+	for m, ses := range this.members {
+		if nil != ses {
+			locals = append(locals, ses)
+		} else {
+			remotes[m.Frontend] = append(remotes[m.Frontend], m.SesId)
+		}
+	}
+	this.mutex.RUnlock()
+
+	// 本地成员
+	sendShared(locals, mid, data)
+
+	if len(remotes) == 0 {
+		return nil
+	}
+
+	// 远程成员：每个前端服务器并发推送1次
+	remote := this.mgr.getRemote()
+	if nil == remote {
+		return errors.Errorf("组[%s]组播消息失败：存在远程成员，但未设置远程推送。mid=%d", this.name, mid)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(remotes))
+	for frontend, ids := range remotes {
+		wg.Add(1)
+
+		go func(frontend string, ids []int64) {
+			defer wg.Done()
+
+			missing, err := remote.PushRemote(frontend, ids, mid, data)
+			if nil != err {
+				errs <- errors.Wrapf(err, "组[%s]向前端服务器 %s 推送消息失败", this.name, frontend)
+
+				return
+			}
+
+			for _, id := range missing {
+				this.RemoveMember(TMember{Frontend: frontend, SesId: id})
+			}
+
+			if len(missing) > 0 {
+				zaplog.Debugf("组[%s]移除已离线的远程成员。frontend=%s，sesIds=%v", this.name, frontend, missing)
+			}
+		}(frontend, ids)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// 添加1个成员
+func (this *Group) add(m TMember, ses ISession) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.destroyed {
+		return errors.Errorf("组[%s]添加成员失败：组已销毁", this.name)
+	}
+
+	this.members[m] = ses
+
+	return nil
+}
+
+// 销毁组
+func (this *Group) destroy() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.destroyed = true
+	this.members = map[TMember]ISession{}
+}
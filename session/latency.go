@@ -0,0 +1,71 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 心跳延迟统计
+
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// latency 对象
+
+// 往返时间统计：平滑算法同 TCP（RFC 6298），抖动算法同 RTP（RFC 3550）
+//
+// 会话恢复时，新旧连接的接收循环可能同时写入，使用互斥锁保护
+type latency struct {
+	mutex   sync.Mutex    // 互斥锁
+	last    time.Duration // 最近1次往返时间
+	rtt     time.Duration // 平滑往返时间
+	jitter  time.Duration // 往返时间抖动
+	samples int64         // 采样次数
+}
+
+// 添加1次采样
+func (this *latency) add(sample time.Duration) {
+	if sample < 0 {
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	// 第1次采样
+	if this.samples == 0 {
+		this.last = sample
+		this.rtt = sample
+		this.jitter = sample / 2
+		this.samples = 1
+
+		return
+	}
+
+	// rtt += (sample - rtt) / 8
+	this.rtt += (sample - this.rtt) / 8
+
+	// jitter += (|sample - last| - jitter) / 16
+	d := sample - this.last
+	if d < 0 {
+		d = -d
+	}
+	this.jitter += (d - this.jitter) / 16
+
+	this.last = sample
+	this.samples++
+}
+
+// 获取统计结果
+func (this *latency) get() TLatency {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	l := TLatency{
+		Last:    this.last,
+		Rtt:     this.rtt,
+		Jitter:  this.jitter,
+		Samples: this.samples,
+	}
+
+	return l
+}
@@ -4,8 +4,10 @@
 package session
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/zpab123/sco/model"   // 全局模型
 	"github.com/zpab123/sco/network" // 网络库
 )
 
@@ -13,7 +15,11 @@ import (
 // 常量
 
 const (
-	C_HEARTBEAT = 0 * time.Second // session 默认心跳周期
+	C_PANIC_POLICY      = model.C_PANIC_DROP // 消息处理出现 panic 时的默认处理策略
+	C_KICK_FLUSH_TIME   = 3 * time.Second    // 踢下线时，等待踢下线通知发送完成的最长时间
+	C_RESUME_TIME       = 0 * time.Second    // 会话恢复默认等待时间：0=不支持恢复
+	C_RESUME_BUFFER     = 256                // 会话恢复默认最多缓存的未确认消息数量
+	C_CLOSE_NOTIFY_TIME = 1 * time.Second    // 关闭前发送关闭通知时，等待通知发送完成的最长时间
 )
 
 // session 状态
@@ -25,6 +31,21 @@ const (
 	C_SES_STATE_STOPED                // 停止完成
 )
 
+// session 关闭原因：与连接关闭原因相同，由 PacketSocket、ScoConn 检测到的原因会传递给 session
+type CloseReason = network.CloseReason
+
+// session 关闭原因
+const (
+	C_CLOSE_REASON_NONE     = network.C_CLOSE_REASON_NONE     // 无：session 尚未关闭
+	C_CLOSE_REASON_SERVER   = network.C_CLOSE_REASON_SERVER   // 服务器主动关闭
+	C_CLOSE_REASON_CLIENT   = network.C_CLOSE_REASON_CLIENT   // 对端关闭连接
+	C_CLOSE_REASON_TIMEOUT  = network.C_CLOSE_REASON_TIMEOUT  // 心跳超时
+	C_CLOSE_REASON_ERROR    = network.C_CLOSE_REASON_ERROR    // 协议错误或接收数据出现错误
+	C_CLOSE_REASON_SHUTDOWN = network.C_CLOSE_REASON_SHUTDOWN // 服务器停服
+	C_CLOSE_REASON_KICK     = network.C_CLOSE_REASON_KICK     // 被踢下线（重复登录等）
+	C_CLOSE_REASON_OVERFLOW = network.C_CLOSE_REASON_OVERFLOW // 发送溢出：对端读取过慢
+)
+
 // /////////////////////////////////////////////////////////////////////////////
 // 接口
 
@@ -32,14 +53,19 @@ const (
 type ISession interface {
 	Run() error
 	Stop() error
+	Shutdown(deadline time.Time) error    // 优雅关闭：等待发送队列刷新完成后关闭，超过 deadline 后强制关闭
+	SendShutdown() error                  // 发送停服通知
+	SendPacket(pkt *network.Packet) error // 发送1个 packet 消息：返回错误时，pkt 仍由调用者持有，需调用者释放
 	GetId() int64
 	SetId(v int64)
+	GetCloseReason() CloseReason // 获取关闭原因
+	GetLatency() TLatency        // 获取心跳测得的网络延迟
 }
 
 // session 管理
 type ISessionManage interface {
-	OnNewSession(ses ISession)   // 添加1个新的 session
-	OnSessionClose(ses ISession) // 某个 session 关闭
+	OnNewSession(ses ISession)                       // 添加1个新的 session
+	OnSessionClose(ses ISession, reason CloseReason) // 某个 session 关闭
 }
 
 // session 消息处理
@@ -47,6 +73,27 @@ type ISessionMsgHandler interface {
 	OnSessionMessage(ses *Session, packet *network.Packet) // 收到1个新的Packet消息
 }
 
+// uid 管理：ISessionManage 实现此接口后，ClientSession 可以绑定 uid
+type IUidManage interface {
+	Bind(ses *ClientSession, uid string) error // 将 session 与 uid 绑定
+}
+
+// 会话恢复管理：ISessionManage 实现此接口后，ClientSession 支持断线后使用令牌恢复
+type IResumeManage interface {
+	AddResume(token string, ses *ClientSession) // 保存1个会话恢复令牌
+	GetResume(token string) *ClientSession      // 根据令牌获取 session，nil=不存在
+}
+
+// session 关闭处理：ISessionMsgHandler 实现此接口后，session 关闭时收到1次通知
+type ISessionStopHandler interface {
+	OnSessionStop(ses *Session) // session 已关闭
+}
+
+// 组远程推送：向其他前端服务器上的 session 推送消息（由 app 通过 rpc 实现）
+type IGroupRemote interface {
+	PushRemote(frontend string, sesIds []int64, mid uint16, data []byte) ([]int64, error) // 返回前端服务器上已不存在的 session id
+}
+
 // 客户端消息管理
 type IClientMsgHandler interface {
 	OnClientMessage(ses *ClientSession, packet *network.Packet) // 收到1个新的客户端消息
@@ -68,8 +115,11 @@ type IMsgHandler interface {
 
 // Session 配置参数
 type TSessionOpt struct {
-	Heartbeat  time.Duration        // 心跳周期
-	ScoConnOpt *network.TScoConnOpt // ScoConn 配置参数
+	PanicPolicy  uint32               // 消息处理出现 panic 时的处理策略
+	ResumeTime   time.Duration        // 会话恢复等待时间：连接断开后挂起 session，超时后关闭。0=不支持恢复
+	ResumeBuffer int                  // 会话恢复：最多缓存的未确认消息数量，超过后不能恢复
+	NotifyClose  bool                 // 服务器主动关闭时，是否先向对端发送关闭原因（C_PKT_ID_CLOSE）
+	ScoConnOpt   *network.TScoConnOpt // ScoConn 配置参数
 }
 
 // 创建1个新的 TSessionOpts
@@ -79,8 +129,10 @@ func NewTSessionOpt() *TSessionOpt {
 
 	// 创建 TServerSessionOpt
 	opt := &TSessionOpt{
-		Heartbeat:  C_HEARTBEAT,
-		ScoConnOpt: sc,
+		PanicPolicy:  C_PANIC_POLICY,
+		ResumeTime:   C_RESUME_TIME,
+		ResumeBuffer: C_RESUME_BUFFER,
+		ScoConnOpt:   sc,
 	}
 
 	return opt
@@ -91,8 +143,11 @@ func NewTSessionOpt() *TSessionOpt {
 
 // ClientSession 配置参数
 type TClientSessionOpt struct {
-	Heartbeat  time.Duration        // 心跳周期
-	ScoConnOpt *network.TScoConnOpt // WorldConnection 配置参数
+	PanicPolicy  uint32               // 消息处理出现 panic 时的处理策略
+	ResumeTime   time.Duration        // 会话恢复等待时间：连接断开后，客户端在此时间内使用令牌重连，可恢复 session。0=不支持恢复
+	ResumeBuffer int                  // 会话恢复：最多缓存的未确认消息数量，超过后不能恢复
+	NotifyClose  bool                 // 服务器主动关闭时，是否先向客户端发送关闭原因（C_PKT_ID_CLOSE）
+	ScoConnOpt   *network.TScoConnOpt // WorldConnection 配置参数
 }
 
 // 创建1个新的 TClientSessionOpt
@@ -102,8 +157,10 @@ func NewTClientSessionOpt() *TClientSessionOpt {
 
 	// 创建 TClientSessionOpt
 	opt := &TClientSessionOpt{
-		Heartbeat:  C_HEARTBEAT,
-		ScoConnOpt: sc,
+		PanicPolicy:  C_PANIC_POLICY,
+		ResumeTime:   C_RESUME_TIME,
+		ResumeBuffer: C_RESUME_BUFFER,
+		ScoConnOpt:   sc,
 	}
 
 	return opt
@@ -114,8 +171,8 @@ func NewTClientSessionOpt() *TClientSessionOpt {
 
 // ServerSession 配置参数
 type TServerSessionOpt struct {
-	Heartbeat  time.Duration        // 心跳周期
-	ScoConnOpt *network.TScoConnOpt // WorldConnection 配置参数
+	PanicPolicy uint32               // 消息处理出现 panic 时的处理策略
+	ScoConnOpt  *network.TScoConnOpt // WorldConnection 配置参数
 }
 
 // 创建1个新的 TServerSessionOpt
@@ -125,9 +182,55 @@ func NewTServerSessionOpt() *TServerSessionOpt {
 
 	// 创建 TServerSessionOpt
 	opt := &TServerSessionOpt{
-		Heartbeat:  C_HEARTBEAT,
-		ScoConnOpt: sc,
+		PanicPolicy: C_PANIC_POLICY,
+		ScoConnOpt:  sc,
 	}
 
 	return opt
 }
+
+// /////////////////////////////////////////////////////////////////////////////
+// TLatency 对象
+
+// 心跳测得的网络延迟
+type TLatency struct {
+	Last    time.Duration // 最近1次往返时间
+	Rtt     time.Duration // 平滑往返时间
+	Jitter  time.Duration // 往返时间抖动
+	Samples int64         // 采样次数，0=尚未测得
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// TLatencyStats 对象
+
+// 多个 session 的网络延迟统计（只统计已测得延迟的 session）
+type TLatencyStats struct {
+	Sessions  int           // 已测得延迟的 session 数量
+	AvgRtt    time.Duration // 平滑往返时间的平均值
+	MaxRtt    time.Duration // 平滑往返时间的最大值
+	AvgJitter time.Duration // 抖动的平均值
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// TMember 对象
+
+// 组成员：session 所在的前端服务器 + session id
+type TMember struct {
+	Frontend string // session 所在的前端服务器名字
+	SesId    int64  // session 在前端服务器上的 id
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// CrashPanic 对象
+
+// panic 策略为 crash 时，上层（例如 app）捕获并统计后重新抛出的 panic
+//
+// session 收到此 panic 时不再重复统计，也不再使用自身的 panic 策略，直接重新抛出
+type CrashPanic struct {
+	Value interface{} // 原始 panic 值
+}
+
+// 错误信息 [error 接口]
+func (this *CrashPanic) Error() string {
+	return fmt.Sprintf("消息处理出现 panic，panic 策略为 crash：%v", this.Value)
+}
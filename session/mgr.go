@@ -5,8 +5,12 @@ package session
 
 import (
 	"sync"
+	"time"
 
-	"github.com/zpab123/syncutil" // 同步变量
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/syncutil"     // 同步变量
+	"github.com/zpab123/zaplog"       // 日志
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -14,14 +18,21 @@ import (
 
 // Session 管理对象
 type SessionManager struct {
-	sesMap   sync.Map             // id -> session 对象集合
-	sesIDGen syncutil.AtomicInt64 // session ID生成器
-	count    syncutil.AtomicInt64 // 记录当前在使用的会话数量
+	sesMap        sync.Map                      // id -> session 对象集合
+	sesIDGen      syncutil.AtomicInt64          // session ID生成器
+	count         syncutil.AtomicInt64          // 记录当前在使用的会话数量
+	openHandlers  []func(ISession)              // session 创建回调
+	closeHandlers []func(ISession, CloseReason) // session 关闭回调
+	uidMutex      sync.RWMutex                  // uidMap 读写锁
+	uidMap        map[string]*ClientSession     // uid -> 绑定的 session
+	resumeMap     sync.Map                      // 会话恢复令牌 -> session
 }
 
 // 创建1个 SessionManager
 func NewSessionManager() *SessionManager {
-	sesMgr := &SessionManager{}
+	sesMgr := &SessionManager{
+		uidMap: map[string]*ClientSession{},
+	}
 
 	return sesMgr
 }
@@ -29,11 +40,37 @@ func NewSessionManager() *SessionManager {
 // 收到1个新的 session [ISessionManager 接口]
 func (this *SessionManager) OnNewSession(ses ISession) {
 	this.Add(ses)
+
+	for _, fn := range this.openHandlers {
+		fn(ses)
+	}
 }
 
 // 某个 session 关闭 [ISessionManager 接口]
-func (this *SessionManager) OnSessionClose(ses ISession) {
+func (this *SessionManager) OnSessionClose(ses ISession, reason CloseReason) {
 	this.Remove(ses)
+
+	if cs, ok := ses.(*ClientSession); ok {
+		this.unbind(cs)
+
+		if token := cs.GetToken(); "" != token {
+			this.resumeMap.Delete(token)
+		}
+	}
+
+	for _, fn := range this.closeHandlers {
+		fn(ses, reason)
+	}
+}
+
+// 添加1个 session 创建回调：session 添加到管理器（分配 id）后调用（需在 session 开始工作前添加）
+func (this *SessionManager) AddOpenHandler(fn func(ISession)) {
+	this.openHandlers = append(this.openHandlers, fn)
+}
+
+// 添加1个 session 关闭回调：reason=关闭原因（需在 session 开始工作前添加）
+func (this *SessionManager) AddCloseHandler(fn func(ISession, CloseReason)) {
+	this.closeHandlers = append(this.closeHandlers, fn)
 }
 
 // 添加1个符合 ISession 接口的对象
@@ -60,11 +97,209 @@ func (this *SessionManager) Remove(ses ISession) {
 	this.count.Add(-1)
 }
 
+// 将 session 与 uid 绑定；同一 uid 已绑定其他 session 时，旧 session 被踢下线（重复登录） [IUidManage 接口]
+//
+// session 已绑定其他 uid 时，解除旧绑定
+func (this *SessionManager) Bind(ses *ClientSession, uid string) error {
+	if "" == uid {
+		return errors.New("绑定 uid 失败：参数 uid 为空")
+	}
+
+	this.uidMutex.Lock()
+	if old := ses.GetUid(); "" != old && old != uid && this.uidMap[old] == ses {
+		delete(this.uidMap, old)
+	}
+
+	prev := this.uidMap[uid]
+	this.uidMap[uid] = ses
+	ses.setUid(uid)
+	this.uidMutex.Unlock()
+
+	// 踢掉旧 session（在锁外关闭，关闭回调会再次访问 uidMap）
+	if nil != prev && prev != ses {
+		zaplog.Infof("uid=%s 重复登录，踢掉旧 session。旧 sesId=%d，新 sesId=%d", uid, prev.GetId(), ses.GetId())
+
+		prev.Kick(protocol.C_CODE_KICK_RELOGIN)
+	}
+
+	return nil
+}
+
+// 保存1个会话恢复令牌：session 关闭时删除 [IResumeManage 接口]
+func (this *SessionManager) AddResume(token string, ses *ClientSession) {
+	this.resumeMap.Store(token, ses)
+}
+
+// 根据会话恢复令牌获取 session [IResumeManage 接口]
+//
+// 返回 nil=不存在
+func (this *SessionManager) GetResume(token string) *ClientSession {
+	if v, ok := this.resumeMap.Load(token); ok {
+		return v.(*ClientSession)
+	}
+
+	return nil
+}
+
+// 根据 uid 获取 session
+//
+// 返回 nil=不存在
+func (this *SessionManager) GetSessionByUid(uid string) *ClientSession {
+	this.uidMutex.RLock()
+	defer this.uidMutex.RUnlock()
+
+	return this.uidMap[uid]
+}
+
+// 获取当前绑定了 uid 的 session 数量
+func (this *SessionManager) GetUidCount() int {
+	this.uidMutex.RLock()
+	defer this.uidMutex.RUnlock()
+
+	return len(this.uidMap)
+}
+
+// 向1个 session 推送消息
+//
+// msg=消息内容：[]byte 原样发送，proto.Message 使用 protobuf 编码，其他使用 json 编码
+func (this *SessionManager) Push(sesId int64, mid uint16, msg interface{}) error {
+	ses := this.GetSession(sesId)
+	if nil == ses {
+		return errors.Errorf("推送消息失败：session 不存在。sesId=%d", sesId)
+	}
+
+	data, err := encodeMsg(msg)
+	if nil != err {
+		return errors.Wrapf(err, "推送消息失败：编码错误。mid=%d", mid)
+	}
+
+	// 发送失败时，packet 仍由调用者持有
+	pkt := newMsgPacket(mid, data)
+	if err = ses.SendPacket(pkt); nil != err {
+		pkt.Release()
+
+		return err
+	}
+
+	return nil
+}
+
+// 向 uid 绑定的 session 推送消息
+//
+// msg=消息内容，编码方式同 Push
+func (this *SessionManager) PushByUid(uid string, mid uint16, msg interface{}) error {
+	ses := this.GetSessionByUid(uid)
+	if nil == ses {
+		return errors.Errorf("推送消息失败：uid 未绑定 session。uid=%s", uid)
+	}
+
+	data, err := encodeMsg(msg)
+	if nil != err {
+		return errors.Wrapf(err, "推送消息失败：编码错误。mid=%d", mid)
+	}
+
+	// 发送失败时，packet 仍由调用者持有
+	pkt := newMsgPacket(mid, data)
+	if err = ses.SendPacket(pkt); nil != err {
+		pkt.Release()
+
+		return err
+	}
+
+	return nil
+}
+
+// 向所有满足条件的 session 广播消息，消息只编码1次，所有 session 共享1个 packet
+//
+// filter=过滤函数，返回 true=发送，nil=发送给所有 session；msg=消息内容，编码方式同 Push；返回发送成功的 session 数量
+func (this *SessionManager) Broadcast(filter func(ISession) bool, mid uint16, msg interface{}) (int, error) {
+	data, err := encodeMsg(msg)
+	if nil != err {
+		return 0, errors.Wrapf(err, "广播消息失败：编码错误。mid=%d", mid)
+	}
+
+	var list []ISession
+	this.VisitSession(func(ses ISession) bool {
+		if nil == filter || filter(ses) {
+			list = append(list, ses)
+		}
+
+		return true
+	})
+
+	return sendShared(list, mid, data), nil
+}
+
+// 向多个 session 推送同1个消息，消息只编码1次，所有 session 共享1个 packet
+//
+// msg=消息内容，编码方式同 Push；返回不存在的 session id
+func (this *SessionManager) Multicast(sesIds []int64, mid uint16, msg interface{}) ([]int64, error) {
+	data, err := encodeMsg(msg)
+	if nil != err {
+		return nil, errors.Wrapf(err, "组播消息失败：编码错误。mid=%d", mid)
+	}
+
+	var missing []int64
+	list := make([]ISession, 0, len(sesIds))
+	for _, id := range sesIds {
+		if ses := this.GetSession(id); nil != ses {
+			list = append(list, ses)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	sendShared(list, mid, data)
+
+	return missing, nil
+}
+
+// 踢下线：向 session 发送踢下线通知后关闭连接
+//
+// reason=发送给客户端的踢下线原因码
+func (this *SessionManager) Kick(sesId int64, reason uint32) error {
+	cs, ok := this.GetSession(sesId).(*ClientSession)
+	if !ok {
+		return errors.Errorf("踢下线失败：客户端 session 不存在。sesId=%d", sesId)
+	}
+
+	return cs.Kick(reason)
+}
+
 // 获取当前 ISession 数量
 func (this *SessionManager) GetCount() int {
 	return int(this.count.Load())
 }
 
+// 获取所有 session 的网络延迟统计（只统计已测得延迟的 session）
+func (this *SessionManager) GetLatencyStats() TLatencyStats {
+	var stats TLatencyStats
+	var sumRtt, sumJitter time.Duration
+
+	this.VisitSession(func(ses ISession) bool {
+		l := ses.GetLatency()
+		if l.Samples == 0 {
+			return true
+		}
+
+		stats.Sessions++
+		sumRtt += l.Rtt
+		sumJitter += l.Jitter
+		if l.Rtt > stats.MaxRtt {
+			stats.MaxRtt = l.Rtt
+		}
+
+		return true
+	})
+
+	if stats.Sessions > 0 {
+		stats.AvgRtt = sumRtt / time.Duration(stats.Sessions)
+		stats.AvgJitter = sumJitter / time.Duration(stats.Sessions)
+	}
+
+	return stats
+}
+
 // 设置ID开始的号
 func (this *SessionManager) SetIDStart(start int64) {
 	this.sesIDGen.Store(start)
@@ -96,6 +331,58 @@ func (this *SessionManager) SessionCount() int64 {
 	return this.count.Load()
 }
 
+// 向所有连接发送停服通知
+func (this *SessionManager) NotifyShutdown() {
+	// 处理函数
+	f := func(ses ISession) bool {
+		ses.SendShutdown()
+
+		return true
+	}
+
+	// 遍历
+	this.VisitSession(f)
+}
+
+// 优雅关闭所有连接：每个连接等待发送队列刷新完成后关闭，超过 deadline 后强制关闭
+//
+// 阻塞直到所有连接关闭
+func (this *SessionManager) ShutdownAllSession(deadline time.Time) {
+	var wg sync.WaitGroup
+
+	// 处理函数
+	f := func(ses ISession) bool {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ses.Shutdown(deadline)
+		}()
+
+		return true
+	}
+
+	// 遍历
+	this.VisitSession(f)
+
+	wg.Wait()
+}
+
+// session 关闭后，解除其 uid 绑定
+func (this *SessionManager) unbind(ses *ClientSession) {
+	uid := ses.GetUid()
+	if "" == uid {
+		return
+	}
+
+	this.uidMutex.Lock()
+	if this.uidMap[uid] == ses {
+		delete(this.uidMap, uid)
+	}
+	this.uidMutex.Unlock()
+}
+
 // 关闭所有连接
 func (this *SessionManager) CloseAllSession() {
 	// 处理函数
@@ -4,6 +4,8 @@
 package session
 
 import (
+	"time"
+
 	"github.com/zpab123/sco/network" // 网络
 )
 
@@ -12,8 +14,9 @@ import (
 
 // ClientSession 消息
 type ClientMsg struct {
-	Session *ClientSession  // session 对象
-	Packet  *network.Packet // packet 数据包
+	Session  *ClientSession  // session 对象
+	Packet   *network.Packet // packet 数据包
+	RecvTime time.Time       // 收到消息的时间
 }
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -21,11 +24,14 @@ type ClientMsg struct {
 
 // ServerSession 消息
 type ServerMsg struct {
-	session *ServerSession  // session 对象
-	packet  *network.Packet // packet 数据包
+	session     *ServerSession  // session 对象
+	packet      *network.Packet // packet 数据包
+	forward     bool            // 是否是前端服务器转发的客户端消息
+	clientSesId int64           // 转发消息：客户端在前端服务器上的 session id
+	backend     *BackendSession // 转发消息：客户端 session 代理
 }
 
-// 创建1个 ClientMsg
+// 创建1个 ServerMsg
 func NewServerMsg(ses *ServerSession, pkt *network.Packet) *ServerMsg {
 	msg := &ServerMsg{
 		session: ses,
@@ -35,6 +41,21 @@ func NewServerMsg(ses *ServerSession, pkt *network.Packet) *ServerMsg {
 	return msg
 }
 
+// 创建1个前端服务器转发的 ServerMsg
+//
+// clientSesId=客户端在前端服务器上的 session id；pkt=客户端原始 packet；bs=客户端 session 代理，可以为 nil
+func NewForwardMsg(ses *ServerSession, clientSesId int64, pkt *network.Packet, bs *BackendSession) *ServerMsg {
+	msg := &ServerMsg{
+		session:     ses,
+		packet:      pkt,
+		forward:     true,
+		clientSesId: clientSesId,
+		backend:     bs,
+	}
+
+	return msg
+}
+
 // 获取 session 对象
 func (this *ServerMsg) GetSession() *ServerSession {
 	return this.session
@@ -44,3 +65,37 @@ func (this *ServerMsg) GetSession() *ServerSession {
 func (this *ServerMsg) GetPacket() *network.Packet {
 	return this.packet
 }
+
+// 是否是前端服务器转发的客户端消息
+func (this *ServerMsg) IsForward() bool {
+	return this.forward
+}
+
+// 获取客户端在前端服务器上的 session id（仅转发消息有效）
+func (this *ServerMsg) GetClientSessionId() int64 {
+	return this.clientSesId
+}
+
+// 获取客户端 session 代理（仅转发消息有效）
+//
+// 返回 nil=非转发消息
+func (this *ServerMsg) GetBackendSession() *BackendSession {
+	return this.backend
+}
+
+// 通过消息来源的 ServerSession 回复1个 packet
+//
+// 转发消息：回复将经由前端服务器，送达客户端；无论成功与否，pkt 均由此函数释放
+func (this *ServerMsg) Reply(pkt *network.Packet) error {
+	if this.forward {
+		return this.session.PushToClient(this.clientSesId, pkt)
+	}
+
+	if err := this.session.SendPacket(pkt); nil != err {
+		pkt.Release()
+
+		return err
+	}
+
+	return nil
+}
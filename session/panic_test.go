@@ -0,0 +1,181 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/model"   // 全局模型
+	"github.com/zpab123/sco/network" // 网络
+	"golang.org/x/net/websocket"     // websocket
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用对象
+
+// 新连接使用的参数
+type testPanicConf struct {
+	policy  uint32        // panic 策略
+	release chan struct{} // 关闭后断开连接
+}
+
+// 为每个新连接创建 ServerSession，由测试 goroutine 启动，测试结束后断开
+type testPanicConnMgr struct {
+	handler  IServerMsgHandler   // 消息处理
+	confs    chan testPanicConf  // 新连接使用的参数
+	sessions chan *ServerSession // 新建的服务端会话
+}
+
+func (this *testPanicConnMgr) OnNewWsConn(wsconn *websocket.Conn) {
+	wsconn.PayloadType = websocket.BinaryFrame
+	conf := <-this.confs
+
+	opt := NewTServerSessionOpt()
+	opt.PanicPolicy = conf.policy
+	ses, err := NewServerSession(&network.Socket{Conn: wsconn}, NewSessionManager(), this.handler, opt)
+	if nil != err {
+		wsconn.Close()
+
+		return
+	}
+
+	this.sessions <- ses.(*ServerSession)
+	<-conf.release
+}
+
+// 收到内容为 panic 的消息时，以 value 抛出 panic；其他消息记录到 bodys
+type testPanicHandler struct {
+	value interface{} // panic 值
+	bodys chan string // 收到的其他消息
+}
+
+func (this *testPanicHandler) OnServerMessage(ses *ServerSession, packet *network.Packet) {
+	body := packet.ReadString()
+	if "panic" == body {
+		panic(this.value)
+	}
+
+	this.bodys <- body
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 消息处理出现 panic 时，按 panic 策略丢弃消息、关闭 session 或重新抛出
+func TestPanicPolicy(t *testing.T) {
+	boom := errors.New("boom")
+	crash := &CrashPanic{Value: "boom"}
+
+	tests := []struct {
+		name    string      // 用例名字
+		policy  uint32      // panic 策略
+		value   interface{} // 消息处理抛出的 panic 值
+		closed  bool        // session 是否关闭
+		rethrow bool        // 是否重新抛出 panic
+		count   int64       // panic 统计次数
+	}{
+		{"drop", model.C_PANIC_DROP, "boom", false, false, 1},
+		{"close", model.C_PANIC_CLOSE, "boom", true, false, 1},
+		{"crash string", model.C_PANIC_CRASH, "boom", true, true, 1},
+		{"crash error", model.C_PANIC_CRASH, boom, true, true, 1},
+		{"crash by app", model.C_PANIC_DROP, crash, true, true, 0}, // 上层已处理：不受 session 策略影响，不重复统计
+	}
+
+	addr := "127.0.0.1:18632"
+	handler := &testPanicHandler{bodys: make(chan string, 1)}
+	mgr := &testPanicConnMgr{
+		handler:  handler,
+		confs:    make(chan testPanicConf, 1),
+		sessions: make(chan *ServerSession, 1),
+	}
+
+	acceptor, err := network.NewWsAcceptor(addr, mgr)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if err = acceptor.Run(); nil != err {
+		t.Fatal(err)
+	}
+	defer acceptor.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.value = tt.value
+			release := make(chan struct{})
+			defer close(release)
+			mgr.confs <- testPanicConf{policy: tt.policy, release: release}
+
+			// 在可恢复的 goroutine 中启动服务端 session：crash 策略的 panic 不会导致测试进程退出
+			srvs := make(chan *ServerSession, 1)
+			result := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					result <- recover()
+				}()
+
+				srv := <-mgr.sessions
+				srvs <- srv
+				srv.Run()
+			}()
+
+			cli, err := DialServerSession(addr, NewSessionManager(), &testCounter{}, nil)
+			if nil != err {
+				t.Fatal(err)
+			}
+			defer cli.Stop()
+
+			srv := <-srvs
+			defer srv.Stop()
+
+			before := GetPanicCount()
+			for _, body := range []string{"panic", "next"} {
+				pkt := network.NewPacket(1000)
+				pkt.AppendString(body)
+				if err = cli.SendPacket(pkt); nil != err {
+					pkt.Release()
+					t.Fatal(err)
+				}
+			}
+
+			if !tt.closed {
+				// 丢弃：session 继续处理后续消息
+				select {
+				case body := <-handler.bodys:
+					if "next" != body {
+						t.Errorf("收到的消息错误。got=%q，want=%q", body, "next")
+					}
+				case r := <-result:
+					t.Fatalf("session 不应关闭。recover=%v", r)
+				case <-time.After(3 * time.Second):
+					t.Fatal("等待后续消息超时")
+				}
+			} else {
+				// 关闭或崩溃：接收线程结束，crash 策略重新抛出原始 panic 值
+				select {
+				case r := <-result:
+					var want interface{}
+					if tt.rethrow {
+						want = tt.value
+					}
+
+					if r != want {
+						t.Errorf("recover 值错误。got=%v，want=%v", r, want)
+					}
+
+					if model.C_PANIC_CLOSE == tt.policy && C_CLOSE_REASON_SERVER != srv.GetCloseReason() {
+						t.Errorf("关闭原因错误。got=%v，want=%v", srv.GetCloseReason(), C_CLOSE_REASON_SERVER)
+					}
+				case <-time.After(3 * time.Second):
+					t.Fatal("等待 session 关闭超时")
+				}
+			}
+
+			if got := GetPanicCount() - before; got != tt.count {
+				t.Errorf("panic 统计次数错误。got=%d，want=%d", got, tt.count)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/sco/state"    // 状态管理
+	"golang.org/x/net/websocket"      // websocket
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用会话恢复服务器
+
+// 为每个新连接创建支持会话恢复的 ClientSession
+type testResumeConnMgr struct {
+	mgr      *SessionManager     // session 管理
+	sessions chan *ClientSession // 收到消息的 session
+}
+
+func (this *testResumeConnMgr) OnNewWsConn(wsconn *websocket.Conn) {
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	opt := NewTClientSessionOpt()
+	opt.ResumeTime = 2 * time.Second
+	opt.ResumeBuffer = 4
+	ses, err := NewClientSession(&network.Socket{Conn: wsconn}, this.mgr, this, opt)
+	if nil != err {
+		wsconn.Close()
+
+		return
+	}
+
+	ses.Run()
+}
+
+func (this *testResumeConnMgr) OnClientMessage(ses *ClientSession, packet *network.Packet) {
+	packet.Release()
+	this.sessions <- ses
+}
+
+// 连接服务器并握手，在后台接收可恢复的消息
+func testResumeDial(t *testing.T, addr string, token string, recvSeq uint64) (*network.ScoConn, chan string) {
+	socket, err := network.DialWs(addr)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	conn := network.NewScoConn(socket, nil)
+	if err = conn.HandshakeResume(token, recvSeq); nil != err {
+		socket.Close()
+		t.Fatal(err)
+	}
+
+	msgs := make(chan string, 16)
+	go func() {
+		defer close(msgs)
+
+		for {
+			pkt, err := conn.RecvPacket()
+			if nil != pkt {
+				if protocol.IsResumeMsg(pkt.GetMid()) {
+					msgs <- string(pkt.GetBody())
+				}
+				pkt.Release()
+			}
+
+			if nil != err && nil == pkt {
+				return
+			}
+		}
+	}()
+
+	return conn, msgs
+}
+
+// 接收 n 个消息，之后 wait 时间内不应再收到消息
+func testResumeRecv(t *testing.T, msgs chan string, n int, wait time.Duration) []string {
+	got := []string{}
+	timeout := time.After(3 * time.Second)
+	for len(got) < n {
+		select {
+		case m, ok := <-msgs:
+			if !ok {
+				t.Fatalf("连接已断开。got=%v，want=%d个", got, n)
+			}
+			got = append(got, m)
+		case <-timeout:
+			t.Fatalf("接收消息超时。got=%v，want=%d个", got, n)
+		}
+	}
+
+	select {
+	case m, ok := <-msgs:
+		if ok {
+			t.Fatalf("收到多余的消息。got=%v，extra=%s", got, m)
+		}
+	case <-time.After(wait):
+	}
+
+	return got
+}
+
+// 等待 session 进入 st 状态
+func testWaitState(t *testing.T, ses *Session, st uint32) {
+	deadline := time.Now().Add(3 * time.Second)
+	for ses.stateMgr.GetState() != st {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待 session 状态超时。got=%d，want=%d", ses.stateMgr.GetState(), st)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 会话恢复：客户端确认后释放缓存；恢复时重发客户端未收到的消息（包括挂起期间发送的）；序号超出缓存范围或缓存溢出时不能恢复
+func TestResumeReplayAndAck(t *testing.T) {
+	const addr = "127.0.0.1:18633"
+
+	cm := &testResumeConnMgr{mgr: NewSessionManager(), sessions: make(chan *ClientSession, 1)}
+	acceptor, err := network.NewWsAcceptor(addr, cm)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if err = acceptor.Run(); nil != err {
+		t.Fatal(err)
+	}
+	defer acceptor.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	tests := []struct {
+		name    string   // 用例名字
+		sent    int      // 连接断开前发送的消息数量（客户端全部收到）
+		ack     uint64   // 连接断开前客户端确认的数量
+		pending int      // 挂起期间发送的消息数量
+		recvSeq uint64   // 恢复时客户端已收到的数量
+		resumed bool     // 是否恢复成功
+		replay  []string // 恢复后重发的消息
+	}{
+		{"replay unreceived", 3, 1, 1, 2, true, []string{"m3", "m4"}},
+		{"replay pending only", 2, 2, 2, 2, true, []string{"m3", "m4"}},
+		{"nothing to replay", 2, 0, 0, 2, true, []string{}},
+		{"replay all", 2, 0, 1, 0, true, []string{"m1", "m2", "m3"}},
+		{"seq before ack", 3, 2, 0, 1, false, nil},
+		{"seq after sent", 2, 0, 0, 3, false, nil},
+		{"buffer overflow", 2, 0, 3, 2, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, msgs := testResumeDial(t, addr, "", 0)
+			token := conn.GetToken()
+			if "" == token {
+				t.Fatal("握手未返回会话恢复令牌")
+			}
+
+			// 发送1个消息，获取服务端 session
+			conn.SendPacket(network.NewPacket(protocol.C_MID_SCO))
+			conn.Flush()
+
+			var ses *ClientSession
+			select {
+			case ses = <-cm.sessions:
+			case <-time.After(3 * time.Second):
+				t.Fatal("等待服务端 session 超时")
+			}
+			defer ses.Stop()
+
+			seq := 0
+			send := func() error {
+				seq++
+				pkt := newMsgPacket(protocol.C_MID_SCO, []byte(fmt.Sprintf("m%d", seq)))
+				err := ses.SendPacket(pkt)
+				if nil != err {
+					pkt.Release()
+				}
+
+				return err
+			}
+
+			for i := 0; i < tt.sent; i++ {
+				send()
+			}
+			testResumeRecv(t, msgs, tt.sent, 0)
+
+			// 确认后释放缓存
+			conn.SendAck(tt.ack)
+			conn.Flush()
+
+			deadline := time.Now().Add(3 * time.Second)
+			for {
+				ses.session.mutex.Lock()
+				ackSeq, buffered := ses.session.ackSeq, len(ses.session.outBuf)
+				ses.session.mutex.Unlock()
+
+				if ackSeq == tt.ack && buffered == tt.sent-int(tt.ack) {
+					break
+				}
+
+				if time.Now().After(deadline) {
+					t.Fatalf("确认后缓存错误。ackSeq=%d，buffered=%d，want ackSeq=%d", ackSeq, buffered, tt.ack)
+				}
+
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			// 断开连接：session 挂起，挂起期间的消息缓存
+			conn.Close()
+			testWaitState(t, ses.session, state.C_SUSPENDED)
+
+			var overflow error
+			for i := 0; i < tt.pending; i++ {
+				if err := send(); nil != err {
+					overflow = err
+				}
+			}
+
+			if (tt.sent-int(tt.ack)+tt.pending > 4) != (nil != overflow) {
+				t.Fatalf("缓存溢出的错误不符合预期。err=%v", overflow)
+			}
+
+			// 缓存溢出后 session 在后台关闭
+			if nil != overflow {
+				testWaitState(t, ses.session, state.C_CLOSED)
+			}
+
+			// 恢复
+			conn2, msgs2 := testResumeDial(t, addr, token, tt.recvSeq)
+			defer conn2.Close()
+
+			if conn2.IsResumed() != tt.resumed {
+				t.Fatalf("恢复结果错误。got=%v，want=%v", conn2.IsResumed(), tt.resumed)
+			}
+
+			if !tt.resumed {
+				// 不能恢复：旧 session 关闭，新连接为新 session
+				testWaitState(t, ses.session, state.C_CLOSED)
+
+				return
+			}
+
+			go func() {
+				for nil == conn2.Flush() {
+				}
+			}()
+
+			got := testResumeRecv(t, msgs2, len(tt.replay), 100*time.Millisecond)
+			if fmt.Sprint(got) != fmt.Sprint(tt.replay) {
+				t.Errorf("重发的消息错误。got=%v，want=%v", got, tt.replay)
+			}
+
+			// 恢复后的消息正常收发
+			conn2.SendPacket(network.NewPacket(protocol.C_MID_SCO))
+			select {
+			case s := <-cm.sessions:
+				if s != ses {
+					t.Error("恢复后的消息应由原 session 处理")
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("恢复后等待消息超时")
+			}
+		})
+	}
+}
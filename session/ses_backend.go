@@ -0,0 +1,301 @@
+// /////////////////////////////////////////////////////////////////////////////
+// 后端服务器上的客户端 session 代理
+
+package session
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 协议
+	"github.com/zpab123/zaplog"       // 日志
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// BackendSession 对象
+
+// 后端服务器上的客户端 session 代理：保存前端服务器同步过来的客户端信息，推送/踢下线经由来源前端服务器送达客户端
+type BackendSession struct {
+	link     *ServerSession    // 来源前端服务器的连接
+	sesId    int64             // 客户端在前端服务器上的 session id
+	mutex    sync.RWMutex      // frontend、uid、attrs 读写锁
+	frontend string            // 前端服务器名字，""=尚未同步
+	uid      string            // 绑定的用户 id，""=未绑定
+	attrs    map[string]string // 同步的属性
+}
+
+// 获取来源前端服务器的连接
+func (this *BackendSession) GetLink() *ServerSession {
+	return this.link
+}
+
+// 获取客户端在前端服务器上的 session id
+func (this *BackendSession) GetId() int64 {
+	return this.sesId
+}
+
+// 获取前端服务器名字
+//
+// 返回 ""=前端服务器尚未同步
+func (this *BackendSession) GetFrontend() string {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.frontend
+}
+
+// 获取绑定的 uid
+//
+// 返回 ""=未绑定
+func (this *BackendSession) GetUid() string {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.uid
+}
+
+// 获取1个同步的属性
+//
+// 返回 ""=不存在
+func (this *BackendSession) Get(key string) string {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.attrs[key]
+}
+
+// 获取所有同步属性的副本
+func (this *BackendSession) GetAttrs() map[string]string {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	attrs := make(map[string]string, len(this.attrs))
+	for k, v := range this.attrs {
+		attrs[k] = v
+	}
+
+	return attrs
+}
+
+// 向客户端推送1条消息
+//
+// msg=消息内容，编码方式同 SessionManager.Push
+func (this *BackendSession) Push(mid uint16, msg interface{}) error {
+	data, err := encodeMsg(msg)
+	if nil != err {
+		return errors.Wrapf(err, "BackendSession 推送消息失败：编码错误。sesId=%d，mid=%d", this.sesId, mid)
+	}
+
+	return this.link.PushToClient(this.sesId, newMsgPacket(mid, data))
+}
+
+// 通知前端服务器将客户端踢下线
+//
+// reason=踢下线原因，发送给客户端
+func (this *BackendSession) Kick(reason uint32) error {
+	kick := protocol.SessionKick{
+		SesId:  this.sesId,
+		Reason: reason,
+	}
+
+	data, err := json.Marshal(&kick)
+	if nil != err {
+		return errors.Wrapf(err, "BackendSession 踢下线失败：编码错误。sesId=%d", this.sesId)
+	}
+
+	pkt := newMsgPacket(protocol.C_PKT_ID_SESSION_KICK, data)
+	if err = this.link.SendPacket(pkt); nil != err {
+		pkt.Release()
+
+		return err
+	}
+
+	return nil
+}
+
+// 更新同步信息
+func (this *BackendSession) update(info *protocol.SessionSync) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.frontend = info.Frontend
+	this.uid = info.Uid
+	this.attrs = info.Attrs
+	if nil == this.attrs {
+		this.attrs = map[string]string{}
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// BackendSessionManager 对象
+
+// BackendSession 索引
+type backendKey struct {
+	link  *ServerSession // 来源前端服务器的连接
+	sesId int64          // 客户端在前端服务器上的 session id
+}
+
+// 后端服务器上的客户端 session 代理管理：处理前端服务器的同步/关闭通知，前端服务器连接断开时移除其所有代理
+type BackendSessionManager struct {
+	mutex         sync.RWMutex                   // sessions、uidMap 读写锁
+	sessions      map[backendKey]*BackendSession // 索引 -> 代理
+	uidMap        map[string]*BackendSession     // uid -> 最近同步的代理
+	closeHandlers []func(*BackendSession)        // 客户端 session 关闭回调
+}
+
+// 新建1个 BackendSessionManager
+func NewBackendSessionManager() *BackendSessionManager {
+	bm := &BackendSessionManager{
+		sessions: map[backendKey]*BackendSession{},
+		uidMap:   map[string]*BackendSession{},
+	}
+
+	return bm
+}
+
+// 添加1个客户端 session 关闭回调：收到前端服务器的关闭通知，移除代理后调用（需在前端服务器连接之前添加）
+//
+// 前端服务器连接断开时，客户端不一定已断开，不调用此回调
+func (this *BackendSessionManager) AddCloseHandler(fn func(*BackendSession)) {
+	this.closeHandlers = append(this.closeHandlers, fn)
+}
+
+// 获取转发消息对应的代理，不存在则创建（尚未同步的代理只能推送和踢下线）
+func (this *BackendSessionManager) Get(link *ServerSession, sesId int64) *BackendSession {
+	key := backendKey{
+		link:  link,
+		sesId: sesId,
+	}
+
+	this.mutex.RLock()
+	bs := this.sessions[key]
+	this.mutex.RUnlock()
+
+	if nil != bs {
+		return bs
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if bs = this.sessions[key]; nil == bs {
+		bs = &BackendSession{
+			link:  link,
+			sesId: sesId,
+			attrs: map[string]string{},
+		}
+		this.sessions[key] = bs
+	}
+
+	return bs
+}
+
+// 根据 uid 获取代理
+//
+// 返回 nil=不存在
+func (this *BackendSessionManager) GetByUid(uid string) *BackendSession {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return this.uidMap[uid]
+}
+
+// 获取代理数量
+func (this *BackendSessionManager) GetCount() int {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	return len(this.sessions)
+}
+
+// 处理前端服务器的同步通知
+func (this *BackendSessionManager) OnSync(link *ServerSession, pkt *network.Packet) error {
+	var info protocol.SessionSync
+	if err := json.Unmarshal(pkt.GetBody(), &info); nil != err {
+		return errors.Wrap(err, "同步客户端 session 失败：解码错误")
+	}
+
+	bs := this.Get(link, info.SesId)
+	old := bs.GetUid()
+	bs.update(&info)
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	// 前端服务器连接已关闭
+	if this.sessions[backendKey{link: link, sesId: info.SesId}] != bs {
+		return nil
+	}
+
+	if "" != old && this.uidMap[old] == bs {
+		delete(this.uidMap, old)
+	}
+
+	if "" != info.Uid {
+		this.uidMap[info.Uid] = bs
+	}
+
+	return nil
+}
+
+// 处理前端服务器的关闭通知
+func (this *BackendSessionManager) OnClose(link *ServerSession, pkt *network.Packet) error {
+	var cls protocol.SessionClose
+	if err := json.Unmarshal(pkt.GetBody(), &cls); nil != err {
+		return errors.Wrap(err, "移除客户端 session 代理失败：解码错误")
+	}
+
+	key := backendKey{
+		link:  link,
+		sesId: cls.SesId,
+	}
+
+	this.mutex.Lock()
+	bs, ok := this.sessions[key]
+	if ok {
+		this.remove(key, bs)
+	}
+	this.mutex.Unlock()
+
+	if ok {
+		for _, fn := range this.closeHandlers {
+			fn(bs)
+		}
+	}
+
+	return nil
+}
+
+// 前端服务器连接关闭，移除其所有代理（作为 SessionManager 关闭回调）
+func (this *BackendSessionManager) OnSessionClose(ses ISession, reason CloseReason) {
+	link, ok := ses.(*ServerSession)
+	if !ok {
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	count := 0
+	for key, bs := range this.sessions {
+		if key.link == link {
+			this.remove(key, bs)
+			count++
+		}
+	}
+
+	if count > 0 {
+		zaplog.Debugf("前端服务器连接关闭，移除 %d 个客户端 session 代理。link=%d，reason=%s", count, link.GetId(), reason)
+	}
+}
+
+// 移除1个代理（需持有写锁）
+func (this *BackendSessionManager) remove(key backendKey, bs *BackendSession) {
+	delete(this.sessions, key)
+
+	if uid := bs.GetUid(); "" != uid && this.uidMap[uid] == bs {
+		delete(this.uidMap, uid)
+	}
+}
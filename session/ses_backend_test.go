@@ -0,0 +1,187 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zpab123/sco/protocol" // 通信协议
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用操作
+
+// 前端服务器的通知或连接断开
+type testBackendOp struct {
+	kind  string // sync=同步通知，close=关闭通知，drop=前端服务器连接关闭
+	link  int    // 前端服务器连接序号
+	sesId int64  // 客户端 session id
+	uid   string // 同步的 uid
+}
+
+// 代理的索引：前端服务器连接序号 + 客户端 session id
+type testBackendKey struct {
+	link  int   // 前端服务器连接序号
+	sesId int64 // 客户端 session id
+}
+
+// 执行1个操作
+func (this testBackendOp) apply(t *testing.T, bm *BackendSessionManager, links []*ServerSession) {
+	var data []byte
+	switch this.kind {
+	case "sync":
+		data, _ = json.Marshal(&protocol.SessionSync{
+			Frontend: "gate_1",
+			SesId:    this.sesId,
+			Uid:      this.uid,
+			Attrs:    map[string]string{"uid": this.uid},
+		})
+		pkt := newMsgPacket(protocol.C_PKT_ID_SESSION_SYNC, data)
+		defer pkt.Release()
+
+		if err := bm.OnSync(links[this.link], pkt); nil != err {
+			t.Fatal(err)
+		}
+	case "close":
+		data, _ = json.Marshal(&protocol.SessionClose{SesId: this.sesId})
+		pkt := newMsgPacket(protocol.C_PKT_ID_SESSION_CLOSE, data)
+		defer pkt.Release()
+
+		if err := bm.OnClose(links[this.link], pkt); nil != err {
+			t.Fatal(err)
+		}
+	case "drop":
+		bm.OnSessionClose(links[this.link], C_CLOSE_REASON_CLIENT)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+// 同步通知创建代理并更新 uid 索引；关闭通知移除代理并调用关闭回调；前端服务器连接断开时只移除其代理，不调用关闭回调
+func TestBackendSessionManager(t *testing.T) {
+	tests := []struct {
+		name   string                    // 用例名字
+		ops    []testBackendOp           // 依次执行的操作
+		count  int                       // 剩余的代理数量
+		uids   map[string]testBackendKey // 存在的 uid 索引
+		gone   []string                  // 不存在的 uid 索引
+		closed []int64                   // 调用关闭回调的 session id
+	}{
+		{
+			name:  "sync",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}},
+			count: 1,
+			uids:  map[string]testBackendKey{"u1": {0, 1}},
+		},
+		{
+			name:  "uid changed",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}, {"sync", 0, 1, "u2"}},
+			count: 1,
+			uids:  map[string]testBackendKey{"u2": {0, 1}},
+			gone:  []string{"u1"},
+		},
+		{
+			name:  "unbind",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}, {"sync", 0, 1, ""}},
+			count: 1,
+			gone:  []string{"u1"},
+		},
+		{
+			name:  "same id on different links",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}, {"sync", 1, 1, "u2"}},
+			count: 2,
+			uids:  map[string]testBackendKey{"u1": {0, 1}, "u2": {1, 1}},
+		},
+		{
+			name:   "close",
+			ops:    []testBackendOp{{"sync", 0, 1, "u1"}, {"sync", 0, 2, "u2"}, {"close", 0, 1, ""}},
+			count:  1,
+			uids:   map[string]testBackendKey{"u2": {0, 2}},
+			gone:   []string{"u1"},
+			closed: []int64{1},
+		},
+		{
+			name:  "close unknown",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}, {"close", 1, 1, ""}, {"close", 0, 9, ""}},
+			count: 1,
+			uids:  map[string]testBackendKey{"u1": {0, 1}},
+		},
+		{
+			name:   "uid relogin keeps newer",
+			ops:    []testBackendOp{{"sync", 0, 1, "u1"}, {"sync", 1, 5, "u1"}, {"close", 0, 1, ""}},
+			count:  1,
+			uids:   map[string]testBackendKey{"u1": {1, 5}},
+			closed: []int64{1},
+		},
+		{
+			name:  "link drop",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}, {"sync", 0, 2, "u2"}, {"sync", 1, 3, "u3"}, {"drop", 0, 0, ""}},
+			count: 1,
+			uids:  map[string]testBackendKey{"u3": {1, 3}},
+			gone:  []string{"u1", "u2"},
+		},
+		{
+			name:  "sync after link drop",
+			ops:   []testBackendOp{{"sync", 0, 1, "u1"}, {"drop", 0, 0, ""}, {"sync", 1, 1, "u1"}},
+			count: 1,
+			uids:  map[string]testBackendKey{"u1": {1, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := []*ServerSession{{}, {}}
+			bm := NewBackendSessionManager()
+
+			var closed []int64
+			bm.AddCloseHandler(func(bs *BackendSession) {
+				closed = append(closed, bs.GetId())
+			})
+
+			for _, op := range tt.ops {
+				op.apply(t, bm, links)
+			}
+
+			if got := bm.GetCount(); got != tt.count {
+				t.Errorf("代理数量错误。got=%d，want=%d", got, tt.count)
+			}
+
+			for uid, key := range tt.uids {
+				bs := bm.GetByUid(uid)
+				if nil == bs {
+					t.Errorf("uid 索引不存在。uid=%s", uid)
+
+					continue
+				}
+
+				if bs.GetLink() != links[key.link] || bs.GetId() != key.sesId {
+					t.Errorf("uid 索引错误。uid=%s，got sesId=%d，want=%+v", uid, bs.GetId(), key)
+				}
+
+				if "gate_1" != bs.GetFrontend() || uid != bs.Get("uid") {
+					t.Errorf("同步信息错误。uid=%s，frontend=%s，attrs=%v", uid, bs.GetFrontend(), bs.GetAttrs())
+				}
+
+				if bm.Get(links[key.link], key.sesId) != bs {
+					t.Errorf("Get 返回的代理与 uid 索引不一致。uid=%s", uid)
+				}
+			}
+
+			for _, uid := range tt.gone {
+				if bs := bm.GetByUid(uid); nil != bs {
+					t.Errorf("uid 索引应已移除。uid=%s，sesId=%d", uid, bs.GetId())
+				}
+			}
+
+			if len(closed) != len(tt.closed) {
+				t.Fatalf("关闭回调错误。got=%v，want=%v", closed, tt.closed)
+			}
+
+			for i := range closed {
+				if closed[i] != tt.closed[i] {
+					t.Errorf("关闭回调错误。got=%v，want=%v", closed, tt.closed)
+				}
+			}
+		})
+	}
+}
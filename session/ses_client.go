@@ -4,9 +4,16 @@
 package session
 
 import (
-	"github.com/pkg/errors"          // 异常
-	"github.com/zpab123/sco/network" // 网络
-	"github.com/zpab123/syncutil"    // 原子变量
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/syncutil"     // 原子变量
+	"github.com/zpab123/zaplog"       // 日志
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -17,11 +24,20 @@ import (
 
 // 面向客户端的 session 对象
 type ClientSession struct {
-	option      *TClientSessionOpt   // 配置参数
-	sesssionMgr ISessionManage       // sessiong 管理对象
-	sessionId   syncutil.AtomicInt64 // session ID
-	session     *Session             // session 对象
-	msgHandler  IClientMsgHandler    // 消息处理器
+	option      *TClientSessionOpt     // 配置参数
+	sesssionMgr ISessionManage         // sessiong 管理对象
+	sessionId   syncutil.AtomicInt64   // session ID
+	session     *Session               // session 对象
+	msgHandler  IClientMsgHandler      // 消息处理器
+	attrMutex   sync.RWMutex           // uid、attrs 与 会话恢复信息 读写锁
+	uid         string                 // 绑定的用户 id，""=未绑定
+	attrs       map[string]interface{} // 自定义属性
+	version     uint64                 // uid、attrs 版本：每次变化加1
+	registered  bool                   // 是否已添加到 session 管理对象（握手成功后添加）
+	token       string                 // 会话恢复令牌，""=不支持恢复
+	resumeTo    *ClientSession         // 会话恢复：本连接将移交给的 session
+	resumeSeq   uint64                 // 会话恢复：客户端已收到的消息数量
+	resumeDone  chan struct{}          // 会话恢复：移交后连接的结束通知，nil=尚未移交
 }
 
 // 创建1个新的 ClientSession 对象
@@ -52,6 +68,7 @@ func NewClientSession(socket network.ISocket, mgr ISessionManage, handler IClien
 		option:      opt,
 		sesssionMgr: mgr,
 		msgHandler:  handler,
+		attrs:       map[string]interface{}{},
 	}
 
 	// 创建 session
@@ -59,8 +76,11 @@ func NewClientSession(socket network.ISocket, mgr ISessionManage, handler IClien
 		opt = NewTClientSessionOpt()
 	}
 	sesOpt := &TSessionOpt{
-		Heartbeat:  opt.Heartbeat,
-		ScoConnOpt: opt.ScoConnOpt,
+		PanicPolicy:  opt.PanicPolicy,
+		ResumeTime:   opt.ResumeTime,
+		ResumeBuffer: opt.ResumeBuffer,
+		NotifyClose:  opt.NotifyClose,
+		ScoConnOpt:   opt.ScoConnOpt,
 	}
 
 	var ses *Session
@@ -71,31 +91,261 @@ func NewClientSession(socket network.ISocket, mgr ISessionManage, handler IClien
 	}
 
 	cs.session = ses
+	ses.scoConn.SetShakeHandler(cs)
 
 	return cs, nil
 }
 
-// 启动 session
+// 启动 session：握手成功后添加到 session 管理对象
 func (this *ClientSession) Run() error {
-	if this.sesssionMgr != nil {
-		// 将 session 添加到管理器, 在线程处理前添加到管理器(分配id), 避免ID还未分配,就开始使用id的竞态问题
-		this.sesssionMgr.OnNewSession(this)
-	}
-
 	err := this.session.Run()
 
+	// 连接已移交给恢复的 session：继续在当前 goroutine 中接收数据（websocket 连接需在此 goroutine 中读取）
+	this.attrMutex.RLock()
+	target, done := this.resumeTo, this.resumeDone
+	this.attrMutex.RUnlock()
+
+	if nil != done {
+		target.session.recvLoop(this.session.scoConn, done)
+	}
+
 	return err
 }
 
 // 关闭 session
 func (this *ClientSession) Stop() error {
-	err := this.session.Stop()
+	return this.session.Stop()
+}
+
+// 优雅关闭 session：等待发送队列刷新完成后关闭，超过 deadline 后强制关闭
+func (this *ClientSession) Shutdown(deadline time.Time) error {
+	return this.session.Shutdown(deadline)
+}
+
+// 发送停服通知
+func (this *ClientSession) SendShutdown() error {
+	return this.session.SendShutdown()
+}
+
+// 踢下线：发送踢下线通知，在后台等待发送完成后关闭 session
+//
+// reason=发送给客户端的踢下线原因码
+func (this *ClientSession) Kick(reason uint32) error {
+	return this.session.Kick(reason)
+}
+
+// 以指定原因关闭 session
+func (this *ClientSession) StopWithReason(reason CloseReason) error {
+	return this.session.StopWithReason(reason)
+}
+
+// 将 session 与 uid 绑定；同一 uid 已绑定其他 session 时，旧 session 被踢下线
+//
+// 需要 session 管理对象实现 IUidManage 接口
+func (this *ClientSession) Bind(uid string) error {
+	if m, ok := this.sesssionMgr.(IUidManage); ok {
+		return m.Bind(this, uid)
+	}
+
+	return errors.New("ClientSession 绑定 uid 失败：session 管理对象不支持 uid 绑定")
+}
+
+// 获取绑定的 uid
+//
+// 返回 ""=未绑定
+func (this *ClientSession) GetUid() string {
+	this.attrMutex.RLock()
+	defer this.attrMutex.RUnlock()
+
+	return this.uid
+}
+
+// 设置1个属性
+func (this *ClientSession) Set(key string, value interface{}) {
+	this.attrMutex.Lock()
+	defer this.attrMutex.Unlock()
+
+	this.attrs[key] = value
+	this.version++
+}
+
+// 获取1个属性
+//
+// 返回 nil=不存在
+func (this *ClientSession) Get(key string) interface{} {
+	this.attrMutex.RLock()
+	defer this.attrMutex.RUnlock()
+
+	return this.attrs[key]
+}
+
+// 移除1个属性
+func (this *ClientSession) Remove(key string) {
+	this.attrMutex.Lock()
+	defer this.attrMutex.Unlock()
+
+	delete(this.attrs, key)
+	this.version++
+}
+
+// 获取所有属性的副本
+func (this *ClientSession) GetAttrs() map[string]interface{} {
+	this.attrMutex.RLock()
+	defer this.attrMutex.RUnlock()
+
+	attrs := make(map[string]interface{}, len(this.attrs))
+	for k, v := range this.attrs {
+		attrs[k] = v
+	}
+
+	return attrs
+}
+
+// 设置绑定的 uid（由 session 管理对象调用）
+func (this *ClientSession) setUid(uid string) {
+	this.attrMutex.Lock()
+	defer this.attrMutex.Unlock()
+
+	this.uid = uid
+	this.version++
+}
+
+// 获取需要同步到后端服务器的信息：uid、指定 keys 的属性 以及 当前版本
+//
+// 属性值为 string 时原样同步，其他编码方式同 SessionManager.Push；不存在或编码失败的属性不同步
+func (this *ClientSession) GetSyncInfo(keys []string) (string, map[string]string, uint64) {
+	this.attrMutex.RLock()
+	defer this.attrMutex.RUnlock()
+
+	attrs := make(map[string]string, len(keys))
+	for _, k := range keys {
+		v, ok := this.attrs[k]
+		if !ok {
+			continue
+		}
+
+		if s, ok := v.(string); ok {
+			attrs[k] = s
+
+			continue
+		}
+
+		data, err := encodeMsg(v)
+		if nil != err {
+			zaplog.Warnf("ClientSession %d 同步属性失败：编码错误。key=%s，err=%v", this.GetId(), k, err)
+
+			continue
+		}
+		attrs[k] = string(data)
+	}
+
+	return this.uid, attrs, this.version
+}
+
+// 获取关闭原因
+func (this *ClientSession) GetCloseReason() CloseReason {
+	return this.session.GetCloseReason()
+}
+
+// 获取心跳测得的网络延迟 [ISession 接口]
+func (this *ClientSession) GetLatency() TLatency {
+	return this.session.GetLatency()
+}
+
+// 获取会话恢复令牌
+//
+// 返回 ""=不支持恢复
+func (this *ClientSession) GetToken() string {
+	this.attrMutex.RLock()
+	defer this.attrMutex.RUnlock()
+
+	return this.token
+}
+
+// 收到握手请求：携带令牌时尝试恢复 session，否则添加到 session 管理对象 [IShakeHandler 接口]
+func (this *ClientSession) OnHandshake(req *protocol.HandshakeReq, res *protocol.HandshakeOk) {
+	mgr, _ := this.sesssionMgr.(IResumeManage)
+	resumable := this.session.option.ResumeTime > 0 && nil != mgr
+
+	// 恢复 session
+	if resumable && "" != req.Token {
+		if target := mgr.GetResume(req.Token); nil != target && target != this {
+			if err := target.session.prepareResume(req.RecvSeq); nil == err {
+				this.attrMutex.Lock()
+				this.resumeTo = target
+				this.resumeSeq = req.RecvSeq
+				this.attrMutex.Unlock()
+
+				res.Token = req.Token
+				res.Resumed = true
+
+				return
+			} else {
+				zaplog.Debugf("ClientSession 恢复失败，关闭旧 session，创建新 session。sesId=%d，err=%s", target.GetId(), err)
+
+				target.StopWithReason(C_CLOSE_REASON_CLIENT)
+			}
+		}
+	}
+
+	// 新 session：在处理消息前添加到管理器(分配id)，避免ID还未分配，就开始使用id的竞态问题
+	this.attrMutex.Lock()
+	this.registered = true
+	if resumable {
+		this.token = newToken()
+	}
+	token := this.token
+	this.attrMutex.Unlock()
 
 	if this.sesssionMgr != nil {
-		this.sesssionMgr.OnSessionClose(this)
+		this.sesssionMgr.OnNewSession(this)
 	}
 
-	return err
+	if "" != token {
+		mgr.AddResume(token, this)
+		res.Token = token
+	}
+}
+
+// 握手完成：将连接移交给恢复的 session [IShakeHandler 接口]
+func (this *ClientSession) OnHandshakeAck() {
+	this.attrMutex.RLock()
+	target, seq := this.resumeTo, this.resumeSeq
+	this.attrMutex.RUnlock()
+
+	if nil == target {
+		return
+	}
+
+	done, err := target.session.resume(this.session.scoConn, seq)
+	if nil != err {
+		zaplog.Debugf("ClientSession 恢复失败，关闭连接。sesId=%d，err=%s", target.GetId(), err)
+
+		this.session.Stop()
+
+		return
+	}
+
+	this.attrMutex.Lock()
+	this.resumeDone = done
+	this.attrMutex.Unlock()
+
+	this.session.handoff()
+}
+
+// session 已关闭，通知 session 管理对象 [ISessionStopHandler 接口]
+//
+// 未完成握手的连接不通知；恢复中的连接断开时，恢复的 session 重新挂起
+func (this *ClientSession) OnSessionStop(ses *Session) {
+	this.attrMutex.RLock()
+	registered, target := this.registered, this.resumeTo
+	this.attrMutex.RUnlock()
+
+	if registered && this.sesssionMgr != nil {
+		this.sesssionMgr.OnSessionClose(this, ses.GetCloseReason())
+	} else if nil != target {
+		target.session.abortResume()
+	}
 }
 
 // 获取 session ID
@@ -108,9 +358,25 @@ func (this *ClientSession) SetId(v int64) {
 	this.sessionId.Store(v)
 }
 
+// 发送1个 packet 消息
+func (this *ClientSession) SendPacket(pkt *network.Packet) error {
+	return this.session.SendPacket(pkt)
+}
+
 // session 消息处理
 func (this *ClientSession) OnSessionMessage(ses *Session, packet *network.Packet) {
 	if this.msgHandler != nil {
 		this.msgHandler.OnClientMessage(this, packet)
 	}
 }
+
+// /////////////////////////////////////////////////////////////////////////////
+// 私有 api
+
+// 生成1个会话恢复令牌
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -4,9 +4,12 @@
 package session
 
 import (
-	"github.com/pkg/errors"          // 异常
-	"github.com/zpab123/sco/network" // 网络
-	"github.com/zpab123/syncutil"    // 原子变量
+	"time"
+
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/syncutil"     // 原子变量
 )
 
 // /////////////////////////////////////////////////////////////////////////////
@@ -60,13 +63,17 @@ func NewServerSession(socket network.ISocket, mgr ISessionManage, handler IServe
 	}
 
 	sesOpt := &TSessionOpt{
-		Heartbeat:  opt.Heartbeat,
-		ScoConnOpt: opt.ScoConnOpt,
+		PanicPolicy: opt.PanicPolicy,
+		ScoConnOpt:  opt.ScoConnOpt,
 	}
 
 	var ses *Session
 	ses, err = NewSession(socket, ss, sesOpt)
 
+	if nil != err {
+		return nil, err
+	}
+
 	ss.session = ses
 
 	return ss, nil
@@ -86,13 +93,34 @@ func (this *ServerSession) Run() (err error) {
 
 // 关闭 session
 func (this *ServerSession) Stop() (err error) {
-	err = this.session.Stop()
+	return this.session.Stop()
+}
+
+// 优雅关闭 session：等待发送队列刷新完成后关闭，超过 deadline 后强制关闭
+func (this *ServerSession) Shutdown(deadline time.Time) (err error) {
+	return this.session.Shutdown(deadline)
+}
+
+// 发送停服通知
+func (this *ServerSession) SendShutdown() error {
+	return this.session.SendShutdown()
+}
+
+// 获取关闭原因
+func (this *ServerSession) GetCloseReason() CloseReason {
+	return this.session.GetCloseReason()
+}
+
+// 获取心跳测得的网络延迟 [ISession 接口]
+func (this *ServerSession) GetLatency() TLatency {
+	return this.session.GetLatency()
+}
 
+// session 已关闭，通知 session 管理对象 [ISessionStopHandler 接口]
+func (this *ServerSession) OnSessionStop(ses *Session) {
 	if this.sesssionMgr != nil {
-		this.sesssionMgr.OnSessionClose(this)
+		this.sesssionMgr.OnSessionClose(this, ses.GetCloseReason())
 	}
-
-	return
 }
 
 // 获取 session ID
@@ -105,6 +133,32 @@ func (this *ServerSession) SetId(v int64) {
 	this.sessionId.Store(v)
 }
 
+// 发送1个 packet 消息
+func (this *ServerSession) SendPacket(pkt *network.Packet) error {
+	return this.session.SendPacket(pkt)
+}
+
+// 发送通用消息
+func (this *ServerSession) SendData(data []byte) {
+	this.session.SendData(data)
+}
+
+// 通过前端服务器，向客户端推送1个 packet
+//
+// clientSesId=客户端在前端服务器上的 session id；无论成功与否，pkt 均由此函数释放
+func (this *ServerSession) PushToClient(clientSesId int64, pkt *network.Packet) error {
+	wp := WrapPacket(protocol.C_PKT_ID_BACKWARD, clientSesId, pkt)
+	pkt.Release()
+
+	if err := this.SendPacket(wp); nil != err {
+		wp.Release()
+
+		return err
+	}
+
+	return nil
+}
+
 // session 消息处理
 func (this *ServerSession) OnSessionMessage(ses *Session, packet *network.Packet) {
 	if this.msgHandler != nil {
@@ -4,31 +4,57 @@
 package session
 
 import (
+	"runtime/debug"
+	"sync"
 	"time"
 
-	"github.com/pkg/errors"          // 异常
-	"github.com/zpab123/sco/network" // 网络
-	"github.com/zpab123/sco/scoerr"  // 异常
-	"github.com/zpab123/sco/state"   // 状态管理
-	"github.com/zpab123/zaplog"      // 日志
+	"github.com/pkg/errors"           // 异常
+	"github.com/zpab123/sco/model"    // 全局模型
+	"github.com/zpab123/sco/network"  // 网络
+	"github.com/zpab123/sco/protocol" // 通信协议
+	"github.com/zpab123/sco/scoerr"   // 异常
+	"github.com/zpab123/sco/state"    // 状态管理
+	"github.com/zpab123/syncutil"     // 原子变量
+	"github.com/zpab123/zaplog"       // 日志
 )
 
 // /////////////////////////////////////////////////////////////////////////////
 // 包初始化
 
+// 变量
+var (
+	panicCount syncutil.AtomicInt64 // 消息处理出现 panic 的次数
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 对外 api
+
+// 获取所有 Session 消息处理出现 panic 的总次数
+func GetPanicCount() int64 {
+	return panicCount.Load()
+}
+
 // /////////////////////////////////////////////////////////////////////////////
 // Session 对象
 
 // 面向服务器连接的 session 对象
 type Session struct {
-	option       *TSessionOpt        // 配置参数
-	stateMgr     *state.StateManager // 状态管理
-	scoConn      *network.ScoConn    // sco 引擎连接对象
-	msgHandler   ISessionMsgHandler  // 消息处理器
-	ticker       *time.Ticker        // 心跳计时器
-	timeOut      time.Duration       // 心跳超时时间
-	lastRecvTime time.Time           // 上次接收消息的时间
-	lastSendTime time.Time           // 上次发送消息的时间
+	option       *TSessionOpt          // 配置参数
+	stateMgr     *state.StateManager   // 状态管理
+	mutex        sync.Mutex            // scoConn、done 与 发送缓存 互斥锁
+	scoConn      *network.ScoConn      // sco 引擎连接对象
+	done         chan struct{}         // 当前连接结束通知：连接断开、移交或 session 关闭时关闭
+	msgHandler   ISessionMsgHandler    // 消息处理器
+	lastRecvTime syncutil.AtomicInt64  // 上次接收消息的时间：UnixNano
+	lastSendTime syncutil.AtomicInt64  // 上次发送消息的时间：UnixNano
+	latency      latency               // 心跳测得的网络延迟
+	closeReason  syncutil.AtomicUint32 // 关闭原因
+	lostReason   CloseReason           // 会话恢复：连接断开的原因，等待超时后作为关闭原因
+	resumeTimer  *time.Timer           // 会话恢复：等待恢复计时器
+	resumable    bool                  // 会话恢复：发送缓存是否完整，溢出后不能恢复
+	outSeq       uint64                // 会话恢复：已发送的可恢复消息数量
+	ackSeq       uint64                // 会话恢复：客户端已确认的消息数量
+	outBuf       []*network.Packet     // 会话恢复：已发送、未确认的消息，第1个的序号为 ackSeq+1
 }
 
 // 创建1个新的 Session 对象
@@ -63,7 +89,7 @@ func NewSession(socket network.ISocket, handler ISessionMsgHandler, opt *TSessio
 		stateMgr:   st,
 		scoConn:    wc,
 		msgHandler: handler,
-		timeOut:    opt.Heartbeat * 2,
+		resumable:  opt.ResumeTime > 0,
 	}
 
 	// 修改为初始化状态
@@ -84,90 +110,494 @@ func (this *Session) Run() (err error) {
 	}
 	// 变量重置？ 状态? 发送队列？
 
-	// 开启发送 goroutine
-	go this.sendLoop()
+	this.mutex.Lock()
+	conn := this.scoConn
+	done := this.startHeartbeat(conn)
+	this.mutex.Unlock()
 
-	// 计时器 goroutine
-	if this.timeOut > 0 {
-		this.ticker = time.NewTicker(this.option.Heartbeat)
-		go this.mainLoop()
-	}
+	// 开启发送 goroutine
+	go this.sendLoop(conn)
 
 	// 改变状态： 工作中
 	this.stateMgr.SetState(state.C_WORKING)
 
 	// 接收循环，这里不能 go this.recvLoop()，会导致 websocket 连接直接断开
-	this.recvLoop()
+	this.recvLoop(conn, done)
 
 	return
 }
 
 // 关闭 session [ISession 接口]
 func (this *Session) Stop() (err error) {
-	// 状态改变为关闭中
-	if !this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_CLOSEING) {
-		err = errors.Errorf("Session %s 关闭失败，状态错误。当前状态=%d, 正确状态=%d", this, this.stateMgr.GetState(), state.C_WORKING)
+	return this.stop(C_CLOSE_REASON_SERVER)
+}
 
-		return
-	}
+// 以指定原因关闭 session
+func (this *Session) StopWithReason(reason CloseReason) error {
+	return this.stop(reason)
+}
 
-	// 关闭连接
-	err = this.scoConn.Close()
-	if nil != err {
-		err = errors.Errorf("Session %s 关闭失败。错误=%s", this, err)
+// 获取关闭原因
+func (this *Session) GetCloseReason() CloseReason {
+	return CloseReason(this.closeReason.Load())
+}
 
-		return
+// 优雅关闭 session：等待发送队列刷新完成后关闭，超过 deadline 后强制关闭
+func (this *Session) Shutdown(deadline time.Time) error {
+	return this.flushAndStop(C_CLOSE_REASON_SHUTDOWN, deadline)
+}
+
+// 踢下线：发送踢下线通知，在后台等待发送完成后关闭 session，不阻塞调用者
+//
+// reason=发送给客户端的踢下线原因码
+func (this *Session) Kick(reason uint32) error {
+	this.lastSendTime.Store(time.Now().UnixNano())
+
+	if err := this.getConn().SendKick(reason); nil != err {
+		this.stop(C_CLOSE_REASON_KICK)
+
+		return err
 	}
 
-	// 状态: 关闭完成
-	this.stateMgr.SetState(state.C_CLOSED)
+	go this.flushAndStop(C_CLOSE_REASON_KICK, time.Now().Add(C_KICK_FLUSH_TIME))
 
-	return
+	return nil
+}
+
+// 获取心跳测得的网络延迟 [ISession 接口]
+//
+// 对端回复心跳后才有数据
+func (this *Session) GetLatency() TLatency {
+	return this.latency.get()
 }
 
 // 打印信息
 func (this *Session) String() string {
-	return this.scoConn.String()
+	return this.getConn().String()
 }
 
 // 发送心跳消息
 func (this *Session) SendHeartbeat() error {
-	this.lastSendTime = time.Now()
+	this.lastSendTime.Store(time.Now().UnixNano())
+
+	return this.getConn().SendHeartbeat()
+}
+
+// 发送停服通知
+func (this *Session) SendShutdown() error {
+	this.lastSendTime.Store(time.Now().UnixNano())
 
-	return this.scoConn.SendHeartbeat()
+	return this.getConn().SendShutdown()
 }
 
 // 发送通用消息
 func (this *Session) SendData(data []byte) {
-	this.lastSendTime = time.Now()
+	pkt := network.NewPacket(protocol.C_PKT_ID_DATA)
+	pkt.AppendBytes(data)
+
+	if nil != this.SendPacket(pkt) {
+		pkt.Release()
+	}
+}
+
+// 发送1个 packet 消息
+//
+// 支持会话恢复时，消息缓存到客户端确认为止；session 挂起期间，消息只缓存，恢复后重发。
+// 返回错误时，pkt 仍由调用者持有，需调用者释放
+func (this *Session) SendPacket(pkt *network.Packet) error {
+	this.lastSendTime.Store(time.Now().UnixNano())
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !this.resumable || !protocol.IsResumeMsg(pkt.GetMid()) {
+		return this.scoConn.SendPacket(pkt)
+	}
+
+	// 缓存溢出：不再支持恢复
+	if len(this.outBuf) >= this.option.ResumeBuffer {
+		this.dropResume()
+
+		// 未缓存、未发送：pkt 仍由调用者持有
+		if this.stateMgr.GetState() != state.C_WORKING {
+			go this.stop(C_CLOSE_REASON_OVERFLOW)
+
+			return errors.Errorf("Session %s 发送 Packet 失败：连接已断开，未确认的消息超过 %d 个", this.scoConn, this.option.ResumeBuffer)
+		}
+
+		return this.scoConn.SendPacket(pkt)
+	}
+
+	// 缓存持有调用者的引用
+	this.outBuf = append(this.outBuf, pkt)
+	this.outSeq++
+
+	if this.stateMgr.GetState() != state.C_WORKING {
+		return nil
+	}
+
+	// 发送队列持有1次引用；发送失败时连接正在断开，消息已缓存，恢复后重发，不返回错误
+	pkt.Retain()
+	if err := this.scoConn.SendPacket(pkt); nil != err {
+		pkt.Release()
+	}
+
+	return nil
+}
+
+// 等待发送队列刷新完成后关闭 session，超过 deadline 后强制关闭
+func (this *Session) flushAndStop(reason CloseReason, deadline time.Time) error {
+	// 连接已断开：直接关闭
+	if this.stateMgr.GetState() != state.C_WORKING {
+		return this.stop(reason)
+	}
+
+	conn := this.getConn()
+
+	// 对端不读取数据时，写入会一直阻塞；超过 deadline 后让写入失败，保证能够关闭
+	conn.SetSendDeadline(deadline)
+
+	if err := conn.WaitFlush(deadline); nil != err {
+		zaplog.Warnf("Session %s 等待发送完成超时，强制关闭。err=%s", this, err)
+	}
+
+	return this.stop(reason)
+}
+
+// 关闭 session，并通知消息处理器（只关闭1次）
+func (this *Session) stop(reason CloseReason) error {
+	return this.stopFrom(reason, state.C_WORKING, state.C_SUSPENDED, state.C_RESUMING)
+}
+
+// 当前状态为 states 之一时，关闭 session，并通知消息处理器
+func (this *Session) stopFrom(reason CloseReason, states ...uint32) (err error) {
+	// 状态改变为关闭中
+	from := state.C_INVALID
+	for _, st := range states {
+		if this.stateMgr.CompareAndSwap(st, state.C_CLOSEING) {
+			from = st
+
+			break
+		}
+	}
+
+	if state.C_INVALID == from {
+		err = errors.Errorf("Session %s 关闭失败，状态错误。当前状态=%d, 正确状态=%v", this, this.stateMgr.GetState(), states)
+
+		return
+	}
+
+	this.closeReason.Store(uint32(reason))
+
+	// 停止当前连接的 goroutine，释放发送缓存
+	this.mutex.Lock()
+	conn := this.scoConn
+	this.closeDone()
+	this.dropResume()
+	this.mutex.Unlock()
+
+	// 关闭连接（挂起时连接已关闭）
+	if state.C_WORKING == from {
+		this.notifyClose(conn, reason)
+
+		if e := conn.CloseWithReason(reason); nil != e {
+			err = errors.Errorf("Session %s 关闭失败。错误=%s", this, e)
+		}
+	}
+
+	zaplog.Debugf("Session %s 关闭。reason=%s", conn, reason)
+
+	// 状态: 关闭完成
+	this.stateMgr.SetState(state.C_CLOSED)
+
+	// 通知
+	if h, ok := this.msgHandler.(ISessionStopHandler); ok {
+		h.OnSessionStop(this)
+	}
+
+	return
+}
+
+// 向对端发送关闭原因，并等待发送完成（需开启 NotifyClose；对端已关闭连接时不发送）
+func (this *Session) notifyClose(conn *network.ScoConn, reason CloseReason) {
+	if !this.option.NotifyClose || C_CLOSE_REASON_CLIENT == reason {
+		return
+	}
+
+	if err := conn.SendClose(reason); nil != err {
+		return
+	}
+
+	deadline := time.Now().Add(C_CLOSE_NOTIFY_TIME)
+	conn.SetSendDeadline(deadline)
+	conn.WaitFlush(deadline)
+}
+
+// 连接断开：支持会话恢复时挂起 session，否则关闭 session
+func (this *Session) lose(conn *network.ScoConn, done chan struct{}, reason CloseReason) {
+	// 连接已被移交、挂起或 session 已关闭
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	// 对端关闭或心跳超时，可能是网络波动
+	if (C_CLOSE_REASON_CLIENT == reason || C_CLOSE_REASON_TIMEOUT == reason) && this.suspend(conn, reason) {
+		return
+	}
+
+	this.stop(reason)
+}
+
+// 挂起 session：关闭连接，等待客户端使用令牌恢复，超过 ResumeTime 后关闭 session
+//
+// 返回 false=不支持恢复
+func (this *Session) suspend(conn *network.ScoConn, reason CloseReason) bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !this.resumable || conn != this.scoConn {
+		return false
+	}
+
+	if !this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_SUSPENDED) {
+		return false
+	}
+
+	this.closeDone()
+	conn.Close()
+
+	this.lostReason = reason
+	this.resumeTimer = time.AfterFunc(this.option.ResumeTime, this.expire)
+
+	zaplog.Debugf("Session %s 连接断开，挂起等待恢复。reason=%d，未确认消息=%d", conn, reason, len(this.outBuf))
+
+	return true
+}
+
+// 等待恢复超时，关闭 session
+func (this *Session) expire() {
+	this.mutex.Lock()
+	reason := this.lostReason
+	this.mutex.Unlock()
+
+	if nil == this.stopFrom(reason, state.C_SUSPENDED) {
+		zaplog.Debugf("Session %s 等待恢复超时，关闭", this)
+	}
+}
+
+// 准备恢复 session：客户端使用令牌重连，握手返回之前调用
+//
+// session 仍在工作中时（服务器尚未发现连接断开），先挂起；recvSeq=客户端已收到的消息数量
+func (this *Session) prepareResume(recvSeq uint64) error {
+	// 旧连接可能已失效，但尚未检测到
+	if this.stateMgr.GetState() == state.C_WORKING {
+		this.suspend(this.getConn(), C_CLOSE_REASON_CLIENT)
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if !this.stateMgr.CompareAndSwap(state.C_SUSPENDED, state.C_RESUMING) {
+		return errors.Errorf("Session %s 恢复失败，状态错误。当前状态=%d，正确状态=%d", this.scoConn, this.stateMgr.GetState(), state.C_SUSPENDED)
+	}
+
+	this.resumeTimer.Stop()
+
+	// 客户端确认的数量需在缓存范围内
+	if recvSeq < this.ackSeq || recvSeq > this.outSeq {
+		this.stateMgr.SetState(state.C_SUSPENDED)
+		this.resumeTimer.Reset(this.option.ResumeTime)
+
+		return errors.Errorf("Session %s 恢复失败，消息序号错误。客户端已收到=%d，缓存范围=[%d,%d]", this.scoConn, recvSeq, this.ackSeq, this.outSeq)
+	}
+
+	return nil
+}
+
+// 恢复 session：新连接进入工作状态后调用，重发客户端未收到的消息，并启动心跳
+//
+// 返回当前连接的结束通知
+func (this *Session) resume(conn *network.ScoConn, recvSeq uint64) (chan struct{}, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.stateMgr.GetState() != state.C_RESUMING {
+		return nil, errors.Errorf("Session %s 恢复失败，状态错误。当前状态=%d，正确状态=%d", conn, this.stateMgr.GetState(), state.C_RESUMING)
+	}
+
+	// 重发未收到的消息
+	this.ack(recvSeq)
+	for _, pkt := range this.outBuf {
+		pkt.Retain()
+
+		if err := conn.SendPacket(pkt); nil != err {
+			pkt.Release()
+		}
+	}
+
+	// 新连接的发送循环由原 session 启动，这里只启动心跳
+	this.scoConn = conn
+	done := this.startHeartbeat(conn)
+
+	this.stateMgr.SetState(state.C_WORKING)
+
+	zaplog.Debugf("Session %s 恢复成功。重发消息=%d", conn, len(this.outBuf))
+
+	return done, nil
+}
+
+// 恢复失败（新连接在进入工作状态前断开）：重新挂起，等待下次恢复
+func (this *Session) abortResume() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.stateMgr.CompareAndSwap(state.C_RESUMING, state.C_SUSPENDED) {
+		this.resumeTimer.Reset(this.option.ResumeTime)
+	}
+}
+
+// 连接已移交给其他 session（会话恢复）：停止当前连接的心跳，不关闭连接
+func (this *Session) handoff() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.stateMgr.CompareAndSwap(state.C_WORKING, state.C_CLOSED) {
+		this.closeDone()
+	}
+}
+
+// 客户端确认已收到 seq 个消息，释放已确认的缓存（需持有 mutex）
+func (this *Session) ack(seq uint64) {
+	if seq <= this.ackSeq || seq > this.outSeq {
+		return
+	}
+
+	n := int(seq - this.ackSeq)
+	for i := 0; i < n; i++ {
+		this.outBuf[i].Release()
+		this.outBuf[i] = nil
+	}
+
+	this.outBuf = this.outBuf[n:]
+	this.ackSeq = seq
+}
+
+// 不再支持恢复：释放发送缓存（需持有 mutex）
+func (this *Session) dropResume() {
+	if nil != this.resumeTimer {
+		this.resumeTimer.Stop()
+	}
+
+	for _, pkt := range this.outBuf {
+		pkt.Release()
+	}
+
+	this.outBuf = nil
+	this.resumable = false
+}
+
+// 启动连接的心跳循环（需持有 mutex）
+//
+// 返回当前连接的结束通知
+func (this *Session) startHeartbeat(conn *network.ScoConn) chan struct{} {
+	done := make(chan struct{})
+	this.done = done
+
+	// 超时从心跳启动时开始计算
+	now := time.Now().UnixNano()
+	this.lastRecvTime.Store(now)
+	this.lastSendTime.Store(now)
+
+	// 计时器 goroutine：心跳配置来自连接（作为客户端时，由服务器在握手时下发）
+	if conn.GetHeartbeat() > 0 {
+		ticker := time.NewTicker(conn.GetHeartbeat())
+		go this.mainLoop(conn, ticker, done)
+	}
+
+	return done
+}
+
+// 通知当前连接结束（需持有 mutex）
+func (this *Session) closeDone() {
+	if nil == this.done {
+		return
+	}
+
+	select {
+	case <-this.done:
+	default:
+		close(this.done)
+	}
+}
 
-	this.scoConn.SendData(data)
+// 获取当前连接
+func (this *Session) getConn() *network.ScoConn {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.scoConn
 }
 
 // 接收线程
-func (this *Session) recvLoop() {
-	this.lastSendTime = time.Now()
+func (this *Session) recvLoop(conn *network.ScoConn, done chan struct{}) {
+	reason := C_CLOSE_REASON_CLIENT
 
 	defer func() {
-		this.Stop()
+		// 除连接错误外的 panic（消息处理 panic 且策略为 crash）：重新抛出
+		if err := recover(); nil != err {
+			if e, ok := err.(error); !ok || !scoerr.IsConnectionError(e) {
+				zaplog.Errorf("Session %s 接收线程出现 panic，重新抛出。err=%v", this, err)
+				panic(err)
+			}
+		}
 
-		if err := recover(); nil != err && !scoerr.IsConnectionError(err.(error)) {
-			zaplog.TraceError("Session %s 接收数据出现错误：%s", this, err.(error))
-		} else {
-			zaplog.Debugf("Session %s 断开连接", this)
+		zaplog.Debugf("Session %s 断开连接", this)
+
+		// 连接已被本端关闭：使用关闭时的原因（协议错误、发送溢出等），而不是随后的接收错误
+		if r := conn.GetCloseReason(); C_CLOSE_REASON_NONE != r {
+			reason = r
 		}
+
+		this.lose(conn, done, reason)
 	}()
 
 	for {
+		// 连接已结束：移交给其他 session、挂起或 session 已关闭
+		select {
+		case <-done:
+			return
+		default:
+		}
+
 		// 接收消息
-		pkt, err := this.scoConn.RecvPacket()
+		pkt, err := conn.RecvPacket()
 
 		// 消息处理
 		if nil != pkt {
-			this.lastRecvTime = time.Now()
+			this.lastRecvTime.Store(time.Now().UnixNano())
+
+			// 框架消息：不交给消息处理器
+			switch pkt.GetMid() {
+			case protocol.C_PKT_ID_ACK: // 客户端确认
+				this.onAck(pkt)
+
+				continue
+			case protocol.C_PKT_ID_HEARTBEAT: // 心跳
+				this.onHeartbeat(conn, pkt)
+
+				continue
+			case protocol.C_PKT_ID_HEARTBEAT_ACK: // 心跳回复
+				this.onHeartbeatAck(pkt)
+
+				continue
+			}
+
+			if this.msgHandler != nil && !this.handlePacket(pkt) {
+				reason = C_CLOSE_REASON_SERVER
 
-			if this.msgHandler != nil {
-				this.msgHandler.OnSessionMessage(this, pkt) // 这里还需要增加异常处理
+				break
 			}
 
 			continue
@@ -175,21 +605,95 @@ func (this *Session) recvLoop() {
 
 		// 错误处理
 		if nil != err && !scoerr.IsTimeoutError(err) {
-			if scoerr.IsConnectionError(err) {
-				break
-			} else {
-				panic(err)
+			if !scoerr.IsConnectionError(err) {
+				reason = C_CLOSE_REASON_ERROR
+				zaplog.TraceError("Session %s 接收数据出现错误：%s", this, err)
 			}
+
+			break
+		}
+	}
+}
+
+// 处理1个 packet 消息，并捕获处理过程中出现的 panic
+//
+// 返回 false=需要关闭 session
+func (this *Session) handlePacket(pkt *network.Packet) (ok bool) {
+	defer func() {
+		err := recover()
+		if nil == err {
+			return
+		}
+
+		// 上层已按 crash 策略处理：直接重新抛出
+		if _, ok := err.(*CrashPanic); ok {
+			panic(err)
+		}
+
+		panicCount.Add(1)
+		zaplog.Errorf("Session %s 处理消息出现 panic。mid=%d，err=%v\n%s", this, pkt.GetMid(), err, debug.Stack())
+
+		switch this.option.PanicPolicy {
+		case model.C_PANIC_CLOSE:
+			ok = false
+		case model.C_PANIC_CRASH:
+			zaplog.Errorf("Session %s 消息处理异常，panic 策略为 crash，重新抛出 panic", this)
+			panic(err)
+		default:
+			ok = true
 		}
+	}()
+
+	this.msgHandler.OnSessionMessage(this, pkt)
+
+	return true
+}
+
+// 处理客户端确认消息
+func (this *Session) onAck(pkt *network.Packet) {
+	defer pkt.Release()
+
+	if pkt.GetBodyLen() < 8 {
+		return
+	}
+
+	seq := pkt.ReadUint64()
+
+	this.mutex.Lock()
+	this.ack(seq)
+	this.mutex.Unlock()
+}
+
+// 处理对端心跳：原样返回时间戳
+func (this *Session) onHeartbeat(conn *network.ScoConn, pkt *network.Packet) {
+	defer pkt.Release()
+
+	// 旧版本心跳不携带时间戳
+	if pkt.GetBodyLen() < 8 {
+		return
+	}
+
+	conn.SendHeartbeatAck(pkt.ReadUint64())
+}
+
+// 处理心跳回复：计算往返时间
+func (this *Session) onHeartbeatAck(pkt *network.Packet) {
+	defer pkt.Release()
+
+	if pkt.GetBodyLen() < 8 {
+		return
 	}
+
+	sent := int64(pkt.ReadUint64())
+	this.latency.add(time.Duration(time.Now().UnixNano() - sent))
 }
 
-// 发送线程
-func (this *Session) sendLoop() {
+// 发送线程：连接关闭后 Flush 返回错误，goroutine 退出
+func (this *Session) sendLoop(conn *network.ScoConn) {
 	var err error
 
 	for {
-		err = this.scoConn.Flush() // 刷新缓冲区
+		err = conn.Flush() // 刷新缓冲区
 
 		if nil != err {
 			break
@@ -197,16 +701,20 @@ func (this *Session) sendLoop() {
 	}
 }
 
-// 主循环
-func (this *Session) mainLoop() {
+// 主循环：当前连接结束（done 关闭）或 接收超时后退出
+func (this *Session) mainLoop(conn *network.ScoConn, ticker *time.Ticker, done chan struct{}) {
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-this.ticker.C:
-			if this.checkRecvTime() { // 检查接收是否超时
+		case <-done:
+			return
+		case <-ticker.C:
+			if this.checkRecvTime(conn, done) { // 检查接收是否超时
 				return
 			}
 
-			if err := this.checkSendTime(); nil != err { // 检查发送是否超时
+			if err := this.checkSendTime(conn); nil != err { // 检查发送是否超时
 				return
 			}
 		}
@@ -214,14 +722,13 @@ func (this *Session) mainLoop() {
 
 }
 
-// 检查接收是否超时（这里会导致连接断开，2个线程同时访问时间变量，是否需要加锁？）
-func (this *Session) checkRecvTime() bool {
-	if time.Now().After(this.lastRecvTime.Add(this.timeOut)) {
+// 检查接收是否超时：超时后连接断开
+func (this *Session) checkRecvTime(conn *network.ScoConn, done chan struct{}) bool {
+	last := time.Unix(0, this.lastRecvTime.Load())
+	if time.Since(last) > conn.GetTimeout() {
 		zaplog.Errorf("Session %s 接收消息超时，关闭连接", this)
 
-		this.ticker.Stop()
-
-		this.Stop()
+		this.lose(conn, done, C_CLOSE_REASON_TIMEOUT)
 
 		return true
 	}
@@ -230,11 +737,12 @@ func (this *Session) checkRecvTime() bool {
 }
 
 // 检查发送是否超时
-func (this *Session) checkSendTime() error {
+func (this *Session) checkSendTime(conn *network.ScoConn) error {
 	var err error
-	if time.Now().After(this.lastSendTime.Add(this.option.Heartbeat)) {
-
-		err = this.SendHeartbeat()
+	last := time.Unix(0, this.lastSendTime.Load())
+	if time.Since(last) >= conn.GetHeartbeat() {
+		this.lastSendTime.Store(time.Now().UnixNano())
+		err = conn.SendHeartbeat()
 	}
 
 	return err
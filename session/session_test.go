@@ -0,0 +1,187 @@
+package session
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zpab123/sco/network" // 网络
+	"golang.org/x/net/websocket"     // websocket
+)
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试用 websocket 连接管理
+
+// 为每个新连接创建 ServerSession（心跳间隔很短，保证测试期间心跳协程持续运行）
+type testConnMgr struct {
+	handler  IServerMsgHandler   // 消息处理
+	sessions chan *ServerSession // 新建的服务端会话
+}
+
+func (this *testConnMgr) OnNewWsConn(wsconn *websocket.Conn) {
+	wsconn.PayloadType = websocket.BinaryFrame
+
+	opt := NewTServerSessionOpt()
+	opt.ScoConnOpt.Heartbeat = 20 * time.Millisecond
+	ses, err := NewServerSession(&network.Socket{Conn: wsconn}, NewSessionManager(), this.handler, opt)
+	if nil != err {
+		wsconn.Close()
+
+		return
+	}
+
+	this.sessions <- ses.(*ServerSession)
+	ses.Run()
+}
+
+// 统计收到的消息数量
+type testCounter struct {
+	count int64 // 收到的消息数量
+}
+
+func (this *testCounter) OnServerMessage(ses *ServerSession, packet *network.Packet) {
+	atomic.AddInt64(&this.count, 1)
+}
+
+func (this *testCounter) get() int64 {
+	return atomic.LoadInt64(&this.count)
+}
+
+// /////////////////////////////////////////////////////////////////////////////
+// 测试
+
+const (
+	c_TEST_ADDR    = "127.0.0.1:18631" // 测试侦听地址
+	c_TEST_SENDERS = 8                 // 每端并发发送协程数量
+	c_TEST_PACKETS = 100               // 每个发送协程发送的消息数量
+)
+
+// 心跳与收发循环运行时，两端多协程并发 SendPacket 后 Stop；结束后协程数量回到基线
+//
+// 需配合 go test -race 运行
+func TestConcurrentSendAndStop(t *testing.T) {
+	srvH := &testCounter{}
+	mgr := &testConnMgr{handler: srvH, sessions: make(chan *ServerSession, 1)}
+
+	acceptor, err := network.NewWsAcceptor(c_TEST_ADDR, mgr)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if err = acceptor.Run(); nil != err {
+		t.Fatal(err)
+	}
+	defer acceptor.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	base := runtime.NumGoroutine()
+
+	for round := 0; round < 4; round++ {
+		cliH := &testCounter{}
+		cli, err := DialServerSession(c_TEST_ADDR, NewSessionManager(), cliH, nil)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		var srv *ServerSession
+		select {
+		case srv = <-mgr.sessions:
+		case <-time.After(3 * time.Second):
+			t.Fatal("等待服务端会话超时")
+		}
+
+		// 等待服务端握手完成：心跳产生 rtt 样本后，服务端才处于工作状态
+		deadline := time.Now().Add(3 * time.Second)
+		for 0 == srv.GetLatency().Samples && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		if 0 == srv.GetLatency().Samples {
+			t.Fatalf("没有 rtt 样本，心跳未运行。round=%d", round)
+		}
+
+		srvBefore := srvH.get()
+
+		// 两端并发发送，同时读取延迟统计
+		var wg sync.WaitGroup
+		for i := 0; i < c_TEST_SENDERS; i++ {
+			wg.Add(2)
+			go testSend(&wg, cli)
+			go testSend(&wg, srv)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				srv.GetLatency()
+				cli.GetLatency()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		wg.Wait()
+
+		// 等待全部消息到达
+		want := int64(c_TEST_SENDERS * c_TEST_PACKETS)
+		deadline = time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if cliH.get() >= want && srvH.get()-srvBefore >= want {
+				break
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if got := srvH.get() - srvBefore; got != want {
+			t.Errorf("服务端收到消息数量错误。round=%d，got=%d，want=%d", round, got, want)
+		}
+
+		if got := cliH.get(); got != want {
+			t.Errorf("客户端收到消息数量错误。round=%d，got=%d，want=%d", round, got, want)
+		}
+
+		// 轮流从服务端、客户端、两端同时关闭
+		switch round % 3 {
+		case 0:
+			srv.Stop()
+		case 1:
+			cli.Stop()
+		default:
+			wg.Add(2)
+			go func() { defer wg.Done(); srv.Stop() }()
+			go func() { defer wg.Done(); cli.Stop() }()
+			wg.Wait()
+		}
+	}
+
+	// 协程数量回到基线
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if runtime.NumGoroutine() <= base {
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	t.Fatalf("会话关闭后协程泄漏。got=%d，base=%d\n%s", runtime.NumGoroutine(), base, buf[:n])
+}
+
+// 发送 c_TEST_PACKETS 条消息；发送失败时由调用方释放消息
+func testSend(wg *sync.WaitGroup, ses *ServerSession) {
+	defer wg.Done()
+
+	for i := 0; i < c_TEST_PACKETS; i++ {
+		pkt := network.NewPacket(1000)
+		pkt.AppendString("ping")
+		if err := ses.SendPacket(pkt); nil != err {
+			pkt.Release()
+		}
+	}
+}
@@ -8,14 +8,16 @@ package state
 
 // 状态通用
 const (
-	C_INVALID  uint32 = iota // 无效状态
-	C_INIT                   // 初始化状态
-	C_RUNING                 // 正在启动中
-	C_WORKING                // 工作状态
-	C_CLOSEING               // 正在关闭中
-	C_CLOSED                 // 关闭完成
-	C_STOPING                // 正在停止中
-	C_STOPED                 // 停止完成
+	C_INVALID   uint32 = iota // 无效状态
+	C_INIT                    // 初始化状态
+	C_RUNING                  // 正在启动中
+	C_WORKING                 // 工作状态
+	C_CLOSEING                // 正在关闭中
+	C_CLOSED                  // 关闭完成
+	C_STOPING                 // 正在停止中
+	C_STOPED                  // 停止完成
+	C_SUSPENDED               // 已挂起：连接断开，等待恢复
+	C_RESUMING                // 正在恢复中
 )
 
 // /////////////////////////////////////////////////////////////////////////////